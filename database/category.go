@@ -0,0 +1,45 @@
+package database
+
+import (
+	"strings"
+	"time"
+)
+
+// KudoCategory struct represents a category, e.g. a company value, a shout
+// out is tagged with.
+type KudoCategory struct {
+	ID        uint `gorm:"primarykey"`
+	KudoID    uint
+	TeamID    string
+	Name      string
+	CreatedAt time.Time
+}
+
+// AddCategory tags the kudo, once per category whatever the case.
+func (k *Kudo) AddCategory(name string) {
+	if name == "" || k.HasCategory(name) {
+		return
+	}
+
+	k.Categories = append(k.Categories, &KudoCategory{TeamID: k.TeamID, Name: name})
+}
+
+func (k *Kudo) HasCategory(name string) bool {
+	for _, category := range k.Categories {
+		if strings.EqualFold(category.Name, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CategoryNames lists the kudo's categories in the order they were added.
+func (k *Kudo) CategoryNames() []string {
+	names := make([]string, 0, len(k.Categories))
+	for _, category := range k.Categories {
+		names = append(names, category.Name)
+	}
+
+	return names
+}
@@ -3,50 +3,159 @@ package database
 import (
 	"embed"
 	"fmt"
-	"log"
+	"strings"
+	"time"
 
 	"github.com/golang-migrate/migrate/v4"
+	migratedb "github.com/golang-migrate/migrate/v4/database"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
 	"github.com/golang-migrate/migrate/v4/database/sqlite3"
 	"github.com/golang-migrate/migrate/v4/source/iofs"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/pkg/errors"
+	gormpostgres "gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
-//go:embed migrations/*.sql
+//go:embed migrations/sqlite/*.sql migrations/postgres/*.sql
 var fs embed.FS
 
-var db *gorm.DB
+// Store is everything trout keeps track of, over whichever database backs it.
+// Lookups are scoped to the workspace, by team ID, apart from those the
+// background jobs make across every workspace.
+type Store interface {
+	// A second live shout out from the same reaction is turned down with
+	// ErrDuplicateKudo.
+	GetKudoByID(teamID string, kudoID int) (*Kudo, error)
+	SaveKudo(kudo *Kudo) error
+	DeleteKudo(kudo *Kudo) error
+	GetUnsharedKudos(teamID string, public bool, filter KudoFilter) ([]*Kudo, error)
+	GetReceivedKudos(teamID, userID string, offset, limit int) ([]*Kudo, error)
+	GetPendingKudosFrom(teamID, userID string, limit int) ([]*Kudo, error)
+	GetReactionKudo(teamID, channelID, sourceTS, fromUserID string) (*Kudo, error)
 
-func InitDB(dbPath string) error {
-	var err error
-	db, err = gorm.Open(sqlite.Open(dbPath), &gorm.Config{})
+	GetUser(teamID, userID string) (*User, error)
+	CreateUser(user *User) error
+	SaveUser(user *User) error
+	GetUsers(teamID string) ([]*User, error)
+
+	GetTopReceivers(teamID string, filter StatsFilter, limit int) ([]*KudoCount, error)
+	GetTopGivers(teamID string, filter StatsFilter, limit int) ([]*KudoCount, error)
+	CountReceived(teamID, userID string, filter StatsFilter) (int64, error)
+	CountGiven(teamID, userID string, filter StatsFilter, includePrivate bool) (int64, error)
+	CountGivenTo(teamID, fromUserID, toUserID string, since time.Time) (int64, error)
+	GetGivingCounts(teamID string, since time.Time, limit int) ([]*KudoCount, error)
+
+	// WithGiverLock runs fn in a transaction holding the giver's lock, so
+	// checking what they've given and saving what's next can't interleave
+	// with another of their shout outs, on this replica or another. fn reads
+	// and writes through the Store it's handed, and mustn't take the lock
+	// again.
+	WithGiverLock(teamID, userID string, fn func(Store) error) error
+
+	GetReleaseSchedules() ([]*ReleaseSchedule, error)
+	GetReleaseScheduleForChannel(teamID, channelID string) (*ReleaseSchedule, error)
+	SaveReleaseSchedule(schedule *ReleaseSchedule) error
+	DeleteReleaseSchedule(schedule *ReleaseSchedule) error
+	ClaimReleaseScheduleRun(schedule *ReleaseSchedule, t time.Time) (bool, error)
+
+	GetReleasePermissions(teamID string) ([]*ReleasePermission, error)
+	GetReleasePermission(teamID, subjectID string) (*ReleasePermission, error)
+	SaveReleasePermission(permission *ReleasePermission) error
+	DeleteReleasePermission(permission *ReleasePermission) error
+
+	CreateReleaseRun(run *ReleaseRun) error
+	SaveReleaseRun(run *ReleaseRun) error
+	GetReleaseRun(teamID string, id uint) (*ReleaseRun, error)
+	GetInterruptedReleaseRuns() ([]*ReleaseRun, error)
+	GetLatestUnfinishedReleaseRun(teamID string) (*ReleaseRun, error)
+	SaveReleaseDelivery(delivery *ReleaseDelivery) error
+	MarkDeliveryPosted(delivery *ReleaseDelivery) error
+
+	// A release claims its workspace until the given time, so only one goes
+	// out at once across every replica. Claiming is false while another
+	// owner's claim hasn't expired, the owner claiming again renews theirs.
+	ClaimRelease(teamID, owner string, until time.Time) (bool, error)
+	UnclaimRelease(teamID, owner string) error
+
+	// The points ledger is written as kudos are saved, deleted and released,
+	// these only read it.
+	GetPointsBalance(teamID, userID string) (int, error)
+	GetPointsGiven(teamID, userID string, since time.Time) (int, error)
+	GetPointEntries(teamID, userID string, limit int) ([]*PointEntry, error)
+
+	GetRewards(teamID string) ([]*Reward, error)
+	GetReward(teamID string, rewardID int) (*Reward, error)
+	SaveReward(reward *Reward) error
+	DeleteReward(reward *Reward) error
+
+	// Redemptions take their points when created and give them back if
+	// they're rejected. Creating one is false, and stores nothing, when the
+	// user's balance doesn't cover the cost.
+	CreateRedemption(redemption *Redemption) (bool, error)
+	GetRedemption(teamID string, redemptionID int) (*Redemption, error)
+	DecideRedemption(redemption *Redemption) (bool, error)
+
+	GetInstallation(teamID string) (*Installation, error)
+	SaveInstallation(installation *Installation) error
+	DeleteInstallation(installation *Installation) error
+
+	// AdoptUnscopedRecords moves shout outs and releases from before
+	// workspaces were tracked into the given workspace.
+	AdoptUnscopedRecords(teamID string) error
+}
+
+// Open connects to and migrates the database at the given URL. Postgres URLs
+// start with "postgres://", anything else is taken as a sqlite file, with or
+// without a "sqlite://" prefix.
+func Open(databaseURL string) (Store, error) {
+	var dialector gorm.Dialector
+	dialect := "sqlite"
+
+	switch {
+	case strings.HasPrefix(databaseURL, "postgres://"), strings.HasPrefix(databaseURL, "postgresql://"):
+		dialect = "postgres"
+		dialector = gormpostgres.Open(databaseURL)
+	default:
+		dialector = sqlite.Open(strings.TrimPrefix(databaseURL, "sqlite://"))
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{})
 	if err != nil {
-		log.Fatal(err)
-		return errors.Wrap(err, "failed to open sqlite DB")
+		return nil, errors.Wrapf(err, "failed to open %s DB", dialect)
 	}
 
-	runMigrations(db)
+	err = runMigrations(db, dialect)
+	if err != nil {
+		return nil, err
+	}
 
-	return nil
+	return &gormStore{db: db}, nil
 }
 
-func runMigrations(db *gorm.DB) error {
+func runMigrations(db *gorm.DB, dialect string) error {
 	sqlDb, err := db.DB()
 	if err != nil {
 		return fmt.Errorf("unable to get root db instance %s", err)
 	}
-	driver, err := sqlite3.WithInstance(sqlDb, &sqlite3.Config{})
+
+	var driver migratedb.Driver
+	switch dialect {
+	case "postgres":
+		driver, err = postgres.WithInstance(sqlDb, &postgres.Config{})
+	default:
+		driver, err = sqlite3.WithInstance(sqlDb, &sqlite3.Config{})
+	}
 	if err != nil {
-		return fmt.Errorf("creating sqlite3 db driver failed %s", err)
+		return fmt.Errorf("creating %s db driver failed %s", dialect, err)
 	}
 
-	d, err := iofs.New(fs, "migrations")
+	d, err := iofs.New(fs, "migrations/"+dialect)
 	if err != nil {
 		return fmt.Errorf("failed to read migrations %s", err)
 	}
-	m, err := migrate.NewWithInstance("iofs", d, "sqlite3", driver)
+	m, err := migrate.NewWithInstance("iofs", d, dialect, driver)
 	if err != nil {
 		return fmt.Errorf("initializing db migration failed %s", err)
 	}
@@ -0,0 +1,804 @@
+package database
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// gormStore is the Store for both sqlite and Postgres, gorm papers over the
+// differences between them.
+type gormStore struct {
+	db *gorm.DB
+
+	// giverMu stands in for postgres' advisory locks on sqlite, which only
+	// ever has the one replica.
+	giverMu sync.Mutex
+}
+
+func (s *gormStore) GetKudoByID(teamID string, kudoID int) (*Kudo, error) {
+	var kudo Kudo
+
+	result := s.db.Preload("Categories").Where("team_id = ?", teamID).First(&kudo, kudoID)
+	if result.Error != nil {
+		return nil, fmt.Errorf("could not find shout out: %v", result.Error)
+	}
+
+	return &kudo, nil
+}
+
+// SaveKudo stores the kudo along with its categories, dropping any taken off
+// a stored kudo, since every query loads them all. A new kudo's points come
+// out of the giver's allowance in the same transaction; saving it again
+// leaves that ledger entry as it was.
+func (s *gormStore) SaveKudo(kudo *Kudo) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		isNew := kudo.ID == 0
+		err := tx.Save(kudo).Error
+		if isNew && isUniqueViolation(err) {
+			kudo.ID = 0
+			return ErrDuplicateKudo
+		}
+		if err != nil {
+			return err
+		}
+
+		if isNew {
+			if kudo.Points == 0 {
+				return nil
+			}
+			return tx.Create(newGivenPointEntry(kudo)).Error
+		}
+
+		kept := []uint{}
+		for _, category := range kudo.Categories {
+			kept = append(kept, category.ID)
+		}
+		query := tx.Where("kudo_id = ?", kudo.ID)
+		if len(kept) > 0 {
+			query = query.Where("id NOT IN ?", kept)
+		}
+
+		return query.Delete(&KudoCategory{}).Error
+	})
+}
+
+// isUniqueViolation is a unique index turning down a row, on either database.
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+	}
+
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// DeleteKudo hands a pending kudo's points back to the giver. Released kudos
+// keep theirs, the recipient has already been credited.
+func (s *gormStore) DeleteKudo(kudo *Kudo) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(kudo)
+		if result.Error != nil || result.RowsAffected == 0 || !kudo.IsPending() || kudo.Points == 0 {
+			return result.Error
+		}
+
+		return tx.Create(newKudoPointEntry(kudo, kudo.FromUserID, PointsReturned, kudo.Points)).Error
+	})
+}
+
+func (s *gormStore) GetUnsharedKudos(teamID string, public bool, filter KudoFilter) ([]*Kudo, error) {
+	var kudos []*Kudo
+	query := s.db.Preload("Categories").
+		Where("team_id = ?", teamID).
+		Where("shared_at IS NULL").
+		Where("is_public = ?", public)
+
+	if filter.Pond != "" {
+		query = query.Where("pond = ?", filter.Pond)
+	}
+	if filter.ChannelID != "" {
+		query = query.Where("channel_id = ?", filter.ChannelID)
+	}
+	if filter.ToUserIDs != nil {
+		if len(filter.ToUserIDs) == 0 {
+			return []*Kudo{}, nil
+		}
+		query = query.Where("to_user_id IN ?", filter.ToUserIDs)
+	}
+
+	result := query.Order("to_user_id ASC").Find(&kudos)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return kudos, nil
+}
+
+func (s *gormStore) GetReceivedKudos(teamID, userID string, offset, limit int) ([]*Kudo, error) {
+	var kudos []*Kudo
+	result := s.db.Preload("Categories").
+		Where("team_id = ?", teamID).
+		Where("to_user_id = ?", userID).
+		Where("shared_at IS NOT NULL").
+		Order("shared_at DESC, id DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&kudos)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return kudos, nil
+}
+
+func (s *gormStore) GetPendingKudosFrom(teamID, userID string, limit int) ([]*Kudo, error) {
+	var kudos []*Kudo
+	result := s.db.Preload("Categories").
+		Where("team_id = ?", teamID).
+		Where("from_user_id = ?", userID).
+		Where("shared_at IS NULL").
+		Order("created_at ASC, id ASC").
+		Limit(limit).
+		Find(&kudos)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return kudos, nil
+}
+
+func (s *gormStore) GetReactionKudo(teamID, channelID, sourceTS, fromUserID string) (*Kudo, error) {
+	var kudos []*Kudo
+	result := s.db.Preload("Categories").
+		Where("team_id = ?", teamID).
+		Where("channel_id = ?", channelID).
+		Where("source_ts = ?", sourceTS).
+		Where("from_user_id = ?", fromUserID).
+		Where("reaction != ?", "").
+		Limit(1).
+		Find(&kudos)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if len(kudos) == 0 {
+		return nil, nil
+	}
+
+	return kudos[0], nil
+}
+
+func (s *gormStore) GetUser(teamID, userID string) (*User, error) {
+	var user User
+	result := s.db.Where("team_id = ?", teamID).
+		Where("slack_id = ?", userID).
+		First(&user)
+
+	if result.Error != nil && !errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("error querying for db user: %v", result.Error)
+	}
+
+	// No user found
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	return &user, nil
+}
+
+func (s *gormStore) CreateUser(user *User) error {
+	result := s.db.Create(user)
+	return result.Error
+}
+
+func (s *gormStore) SaveUser(user *User) error {
+	result := s.db.Save(user)
+	return result.Error
+}
+
+func (s *gormStore) GetUsers(teamID string) ([]*User, error) {
+	var users []*User
+	result := s.db.Where("team_id = ?", teamID).
+		Order("id").
+		Find(&users)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return users, nil
+}
+
+// statsQuery counts the workspace's kudos matching the filter.
+func (s *gormStore) statsQuery(teamID string, filter StatsFilter) *gorm.DB {
+	query := s.db.Model(&Kudo{}).
+		Where("team_id = ?", teamID).
+		Where("created_at >= ?", filter.Since)
+
+	if filter.Category != "" {
+		query = query.Where("id IN (?)", s.db.Model(&KudoCategory{}).
+			Select("kudo_id").
+			Where("team_id = ?", teamID).
+			Where("name = ?", filter.Category))
+	}
+
+	return query
+}
+
+func (s *gormStore) GetTopReceivers(teamID string, filter StatsFilter, limit int) ([]*KudoCount, error) {
+	var counts []*KudoCount
+	result := s.statsQuery(teamID, filter).
+		Select("to_user_id AS user_id, COUNT(*) AS count").
+		Where("shared_at IS NOT NULL").
+		Group("to_user_id").
+		Order("count DESC, to_user_id ASC").
+		Limit(limit).
+		Scan(&counts)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return counts, nil
+}
+
+func (s *gormStore) GetTopGivers(teamID string, filter StatsFilter, limit int) ([]*KudoCount, error) {
+	var counts []*KudoCount
+	result := s.statsQuery(teamID, filter).
+		Select("from_user_id AS user_id, COUNT(*) AS count").
+		Where("shared_at IS NOT NULL").
+		Where("is_anonymous = ?", false).
+		Group("from_user_id").
+		Order("count DESC, from_user_id ASC").
+		Limit(limit).
+		Scan(&counts)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return counts, nil
+}
+
+func (s *gormStore) CountReceived(teamID, userID string, filter StatsFilter) (int64, error) {
+	var count int64
+	result := s.statsQuery(teamID, filter).
+		Where("to_user_id = ?", userID).
+		Where("shared_at IS NOT NULL").
+		Count(&count)
+
+	return count, result.Error
+}
+
+func (s *gormStore) CountGiven(teamID, userID string, filter StatsFilter, includePrivate bool) (int64, error) {
+	var count int64
+	query := s.statsQuery(teamID, filter).
+		Where("from_user_id = ?", userID)
+
+	if !includePrivate {
+		query = query.Where("shared_at IS NOT NULL").
+			Where("is_anonymous = ?", false)
+	}
+
+	result := query.Count(&count)
+
+	return count, result.Error
+}
+
+// CountGivenTo counts everything from one person to another, pending and
+// anonymous included.
+func (s *gormStore) CountGivenTo(teamID, fromUserID, toUserID string, since time.Time) (int64, error) {
+	var count int64
+	result := s.db.Model(&Kudo{}).
+		Where("team_id = ?", teamID).
+		Where("from_user_id = ?", fromUserID).
+		Where("to_user_id = ?", toUserID).
+		Where("created_at >= ?", since).
+		Count(&count)
+
+	return count, result.Error
+}
+
+// GetGivingCounts ranks everyone by what they gave, pending and anonymous
+// included, for keeping an eye on quotas.
+func (s *gormStore) GetGivingCounts(teamID string, since time.Time, limit int) ([]*KudoCount, error) {
+	var counts []*KudoCount
+	result := s.db.Model(&Kudo{}).
+		Select("from_user_id AS user_id, COUNT(*) AS count").
+		Where("team_id = ?", teamID).
+		Where("created_at >= ?", since).
+		Group("from_user_id").
+		Order("count DESC, from_user_id ASC").
+		Limit(limit).
+		Scan(&counts)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return counts, nil
+}
+
+// WithGiverLock takes a postgres advisory lock, released with the
+// transaction, so every replica waits its turn for the same giver.
+func (s *gormStore) WithGiverLock(teamID, userID string, fn func(Store) error) error {
+	if s.db.Dialector.Name() != "postgres" {
+		s.giverMu.Lock()
+		defer s.giverMu.Unlock()
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if tx.Dialector.Name() == "postgres" {
+			err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", teamID+"/"+userID).Error
+			if err != nil {
+				return fmt.Errorf("failed to lock giver: %v", err)
+			}
+		}
+
+		return fn(&gormStore{db: tx})
+	})
+}
+
+func (s *gormStore) GetReleaseSchedules() ([]*ReleaseSchedule, error) {
+	var schedules []*ReleaseSchedule
+	result := s.db.Order("id ASC").Find(&schedules)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return schedules, nil
+}
+
+func (s *gormStore) GetReleaseScheduleForChannel(teamID, channelID string) (*ReleaseSchedule, error) {
+	var schedule ReleaseSchedule
+	result := s.db.Where("team_id = ?", teamID).
+		Where("channel_id = ?", channelID).
+		First(&schedule)
+
+	if result.Error != nil && !errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("error querying for release schedule: %v", result.Error)
+	}
+
+	// No schedule found
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	return &schedule, nil
+}
+
+func (s *gormStore) SaveReleaseSchedule(schedule *ReleaseSchedule) error {
+	result := s.db.Save(schedule)
+	return result.Error
+}
+
+func (s *gormStore) DeleteReleaseSchedule(schedule *ReleaseSchedule) error {
+	result := s.db.Delete(schedule)
+	return result.Error
+}
+
+// ClaimReleaseScheduleRun only updates the last run if nobody else has since
+// the schedule was loaded, so only one replica releases each run.
+func (s *gormStore) ClaimReleaseScheduleRun(schedule *ReleaseSchedule, t time.Time) (bool, error) {
+	query := s.db.Model(&ReleaseSchedule{}).Where("id = ?", schedule.ID)
+	if schedule.LastRunAt.Valid {
+		query = query.Where("last_run_at = ?", schedule.LastRunAt.Time)
+	} else {
+		query = query.Where("last_run_at IS NULL")
+	}
+
+	result := query.Updates(map[string]interface{}{"last_run_at": t, "updated_at": time.Now()})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	schedule.LastRunAt = null.TimeFrom(t)
+
+	return true, nil
+}
+
+func (s *gormStore) GetReleasePermissions(teamID string) ([]*ReleasePermission, error) {
+	var permissions []*ReleasePermission
+	result := s.db.Where("team_id = ?", teamID).
+		Order("id ASC").
+		Find(&permissions)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return permissions, nil
+}
+
+func (s *gormStore) GetReleasePermission(teamID, subjectID string) (*ReleasePermission, error) {
+	var permission ReleasePermission
+	result := s.db.Where("team_id = ?", teamID).
+		Where("subject_id = ?", subjectID).
+		First(&permission)
+
+	if result.Error != nil && !errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("error querying for release permission: %v", result.Error)
+	}
+
+	// No permission found
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	return &permission, nil
+}
+
+func (s *gormStore) SaveReleasePermission(permission *ReleasePermission) error {
+	result := s.db.Save(permission)
+	return result.Error
+}
+
+func (s *gormStore) DeleteReleasePermission(permission *ReleasePermission) error {
+	result := s.db.Delete(permission)
+	return result.Error
+}
+
+// CreateReleaseRun stores the run along with all of its pending deliveries.
+func (s *gormStore) CreateReleaseRun(run *ReleaseRun) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Omit(clause.Associations).Create(run)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		for _, delivery := range run.Deliveries {
+			delivery.ReleaseRunID = run.ID
+			result = tx.Omit(clause.Associations).Create(delivery)
+			if result.Error != nil {
+				return result.Error
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *gormStore) SaveReleaseRun(run *ReleaseRun) error {
+	result := s.db.Omit(clause.Associations).Save(run)
+	return result.Error
+}
+
+func (s *gormStore) GetReleaseRun(teamID string, id uint) (*ReleaseRun, error) {
+	var run ReleaseRun
+	result := preloadDeliveries(s.db).
+		Where("team_id = ?", teamID).
+		Where("id = ?", id).
+		First(&run)
+
+	if result.Error != nil && !errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("error querying for release run: %v", result.Error)
+	}
+
+	// No run found
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	return &run, nil
+}
+
+func (s *gormStore) GetInterruptedReleaseRuns() ([]*ReleaseRun, error) {
+	var runs []*ReleaseRun
+	result := preloadDeliveries(s.db).
+		Where("status = ?", ReleaseRunRunning).
+		Order("id ASC").
+		Find(&runs)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return runs, nil
+}
+
+func (s *gormStore) GetLatestUnfinishedReleaseRun(teamID string) (*ReleaseRun, error) {
+	var run ReleaseRun
+	result := preloadDeliveries(s.db).
+		Where("team_id = ?", teamID).
+		Where("status IN ?", []string{ReleaseRunRunning, ReleaseRunIncomplete}).
+		Order("id DESC").
+		First(&run)
+
+	if result.Error != nil && !errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("error querying for release run: %v", result.Error)
+	}
+
+	// No run found
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	return &run, nil
+}
+
+func preloadDeliveries(tx *gorm.DB) *gorm.DB {
+	return tx.Preload("Deliveries", func(tx *gorm.DB) *gorm.DB {
+		return tx.Order("id ASC")
+	}).Preload("Deliveries.Kudo.Categories")
+}
+
+func (s *gormStore) SaveReleaseDelivery(delivery *ReleaseDelivery) error {
+	result := s.db.Omit(clause.Associations).Save(delivery)
+	return result.Error
+}
+
+// MarkDeliveryPosted saves the delivery, shares its kudo and credits the
+// kudo's points to the recipient together, so a crash can't leave a posted
+// kudo looking unshared or unpaid.
+func (s *gormStore) MarkDeliveryPosted(delivery *ReleaseDelivery) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Omit(clause.Associations).Save(delivery)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		err := tx.Model(delivery.Kudo).Update("shared_at", delivery.Kudo.SharedAt).Error
+		if err != nil || delivery.Kudo.Points == 0 {
+			return err
+		}
+
+		kudo := delivery.Kudo
+		return tx.Create(newKudoPointEntry(kudo, kudo.ToUserID, PointsReceived, kudo.Points)).Error
+	})
+}
+
+// ClaimRelease takes over an expired claim, or renews the owner's own, with a
+// conditional update, and otherwise tries to insert one. Times are kept in
+// UTC so sqlite compares them as written.
+func (s *gormStore) ClaimRelease(teamID, owner string, until time.Time) (bool, error) {
+	result := s.db.Model(&ReleaseClaim{}).
+		Where("team_id = ?", teamID).
+		Where("owner = ? OR expires_at < ?", owner, time.Now().UTC()).
+		Updates(map[string]interface{}{"owner": owner, "expires_at": until.UTC()})
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.RowsAffected > 0, result.Error
+	}
+
+	result = s.db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&ReleaseClaim{TeamID: teamID, Owner: owner, ExpiresAt: until.UTC()})
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+func (s *gormStore) UnclaimRelease(teamID, owner string) error {
+	return s.db.Where("team_id = ?", teamID).
+		Where("owner = ?", owner).
+		Delete(&ReleaseClaim{}).Error
+}
+
+// GetPointsBalance sums everything the user has received, less what they've
+// spent.
+func (s *gormStore) GetPointsBalance(teamID, userID string) (int, error) {
+	return pointsBalance(s.db, teamID, userID)
+}
+
+func pointsBalance(tx *gorm.DB, teamID, userID string) (int, error) {
+	var balance int
+	result := tx.Model(&PointEntry{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("team_id = ?", teamID).
+		Where("user_id = ?", userID).
+		Where("kind NOT IN ?", allowanceKinds).
+		Scan(&balance)
+
+	return balance, result.Error
+}
+
+// GetPointsGiven is how much of their allowance the user has given out since
+// the given time, less anything returned. Returns count toward when the kudo
+// was given, deleting last month's kudo doesn't add to this month's points.
+func (s *gormStore) GetPointsGiven(teamID, userID string, since time.Time) (int, error) {
+	var given int
+	result := s.db.Model(&PointEntry{}).
+		Select("COALESCE(-SUM(point_entries.amount), 0)").
+		Joins("JOIN kudos ON kudos.id = point_entries.kudo_id").
+		Where("point_entries.team_id = ?", teamID).
+		Where("point_entries.user_id = ?", userID).
+		Where("point_entries.kind IN ?", allowanceKinds).
+		Where("kudos.created_at >= ?", since).
+		Scan(&given)
+
+	return given, result.Error
+}
+
+// GetPointEntries lists the user's latest ledger entries, newest first.
+func (s *gormStore) GetPointEntries(teamID, userID string, limit int) ([]*PointEntry, error) {
+	var entries []*PointEntry
+	result := s.db.
+		Where("team_id = ?", teamID).
+		Where("user_id = ?", userID).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(&entries)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return entries, nil
+}
+
+// GetRewards lists the catalog, cheapest first.
+func (s *gormStore) GetRewards(teamID string) ([]*Reward, error) {
+	var rewards []*Reward
+	result := s.db.Where("team_id = ?", teamID).Order("cost ASC, name ASC").Find(&rewards)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return rewards, nil
+}
+
+func (s *gormStore) GetReward(teamID string, rewardID int) (*Reward, error) {
+	var reward Reward
+	result := s.db.Where("team_id = ?", teamID).Limit(1).Find(&reward, rewardID)
+	if result.Error != nil {
+		return nil, fmt.Errorf("error querying for reward: %v", result.Error)
+	}
+
+	// No reward found
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	return &reward, nil
+}
+
+func (s *gormStore) SaveReward(reward *Reward) error {
+	return s.db.Save(reward).Error
+}
+
+// DeleteReward takes the reward out of the catalog, redemptions already made
+// keep pointing at it.
+func (s *gormStore) DeleteReward(reward *Reward) error {
+	return s.db.Delete(reward).Error
+}
+
+// CreateRedemption checks the balance in the same transaction as spending it.
+// The user's ledger is locked first, so another replica redeeming at the same
+// time waits and then sees these points gone.
+func (s *gormStore) CreateRedemption(redemption *Redemption) (bool, error) {
+	created := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var locked []uint
+		err := tx.Model(&PointEntry{}).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("team_id = ?", redemption.TeamID).
+			Where("user_id = ?", redemption.UserID).
+			Pluck("id", &locked).Error
+		if err != nil {
+			return err
+		}
+
+		balance, err := pointsBalance(tx, redemption.TeamID, redemption.UserID)
+		if err != nil || balance < redemption.Cost {
+			return err
+		}
+
+		err = tx.Omit(clause.Associations).Create(redemption).Error
+		if err != nil {
+			return err
+		}
+
+		created = true
+		return tx.Create(newRedemptionPointEntry(redemption, PointsRedeemed, -redemption.Cost)).Error
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return created, nil
+}
+
+// GetRedemption comes with its reward, even one since taken out of the
+// catalog.
+func (s *gormStore) GetRedemption(teamID string, redemptionID int) (*Redemption, error) {
+	var redemption Redemption
+	result := s.db.Preload("Reward", func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped()
+	}).Where("team_id = ?", teamID).Limit(1).Find(&redemption, redemptionID)
+	if result.Error != nil {
+		return nil, fmt.Errorf("error querying for redemption: %v", result.Error)
+	}
+
+	// No redemption found
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	return &redemption, nil
+}
+
+// DecideRedemption stores the decision, refunding the points if it was
+// rejected. It's false when the redemption had already been decided, e.g. by
+// another admin at the same time, and nothing changes.
+func (s *gormStore) DecideRedemption(redemption *Redemption) (bool, error) {
+	decided := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Redemption{}).
+			Where("id = ?", redemption.ID).
+			Where("status = ?", RedemptionPending).
+			Updates(map[string]interface{}{
+				"status":     redemption.Status,
+				"decided_by": redemption.DecidedBy,
+				"decided_at": redemption.DecidedAt,
+			})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		decided = true
+
+		if redemption.Status != RedemptionRejected {
+			return nil
+		}
+
+		return tx.Create(newRedemptionPointEntry(redemption, PointsRefunded, redemption.Cost)).Error
+	})
+
+	return decided && err == nil, err
+}
+
+func (s *gormStore) GetInstallation(teamID string) (*Installation, error) {
+	var installation Installation
+	result := s.db.Where("team_id = ?", teamID).First(&installation)
+
+	if result.Error != nil && !errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("error querying for installation: %v", result.Error)
+	}
+
+	// Not installed
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	return &installation, nil
+}
+
+// SaveInstallation replaces any earlier install into the same workspace, e.g.
+// when the app is reinstalled with new scopes.
+func (s *gormStore) SaveInstallation(installation *Installation) error {
+	result := s.db.Save(installation)
+	return result.Error
+}
+
+func (s *gormStore) DeleteInstallation(installation *Installation) error {
+	result := s.db.Delete(installation)
+	return result.Error
+}
+
+func (s *gormStore) AdoptUnscopedRecords(teamID string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().Model(&Kudo{}).
+			Where("team_id = ?", "").
+			Update("team_id", teamID)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		return tx.Model(&ReleaseRun{}).
+			Where("team_id = ?", "").
+			Update("team_id", teamID).Error
+	})
+}
@@ -0,0 +1,29 @@
+package database
+
+import (
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// Installation struct represents the app installed in one workspace, with the
+// bot token to act there.
+type Installation struct {
+	TeamID      string `gorm:"primarykey"`
+	TeamName    string
+	BotToken    string
+	BotUserID   string
+	InstalledBy string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func NewInstallationFromOAuth(resp *slack.OAuthV2Response) *Installation {
+	return &Installation{
+		TeamID:      resp.Team.ID,
+		TeamName:    resp.Team.Name,
+		BotToken:    resp.AccessToken,
+		BotUserID:   resp.BotUserID,
+		InstalledBy: resp.AuthedUser.ID,
+	}
+}
@@ -1,6 +1,9 @@
 package database
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math/rand"
 	"strings"
@@ -10,23 +13,39 @@ import (
 
 	"github.com/slack-go/slack/slackevents"
 	"gopkg.in/guregu/null.v4"
+	"gorm.io/gorm"
 )
 
+// ErrDuplicateKudo is saving a reaction that's already counted as a shout out
+// from the same person, e.g. when Slack retries the event on another replica.
+var ErrDuplicateKudo = errors.New("reaction already counted")
+
 // Kudo struct represents shout_out model.
 type Kudo struct {
-	ID          uint `gorm:"primarykey"`
-	FromUserID  string
-	ToUserID    string
-	Message     string
-	IsPublic    bool
-	IsAnonymous bool
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	SharedAt    null.Time
+	ID              uint `gorm:"primarykey"`
+	TeamID          string
+	ChannelID       string
+	Pond            string
+	FromUserID      string
+	ToUserID        string
+	Message         string
+	Entities        Entities
+	IsPublic        bool
+	IsAnonymous     bool
+	Categories      []*KudoCategory
+	SourcePermalink string
+	SourceTS        string
+	Reaction        string
+	Points          int
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	SharedAt        null.Time
+	DeletedAt       gorm.DeletedAt
 }
 
-func NewKudo(from, to, message string) *Kudo {
+func NewKudo(teamID, from, to, message string) *Kudo {
 	return &Kudo{
+		TeamID:      teamID,
 		FromUserID:  from,
 		ToUserID:    to,
 		Message:     message,
@@ -35,62 +54,85 @@ func NewKudo(from, to, message string) *Kudo {
 	}
 }
 
-func NewKudoFromMentionEvent(ev *slackevents.AppMentionEvent, userID string) (*Kudo, error) {
-	msg := strings.ReplaceAll(ev.Text, fmt.Sprintf("<@%s>", userID), "")
+// NewKudosFromMentionEvent drops the bot's own mention, the botUserID, from
+// the message. Mention events don't say which workspace they're from, the
+// Events API envelope does.
+func NewKudosFromMentionEvent(ev *slackevents.AppMentionEvent, teamID, botUserID string) ([]*Kudo, error) {
+	msg := strings.ReplaceAll(ev.Text, fmt.Sprintf("<@%s>", botUserID), "")
+
+	kudos, err := NewKudosFromText(msg, teamID, ev.User)
+	if err != nil {
+		return nil, err
+	}
 
-	return NewKudoFromText(msg, ev.User)
+	for _, kudo := range kudos {
+		kudo.ChannelID = ev.Channel
+	}
+
+	return kudos, nil
 }
 
-func NewKudoFromText(text, fromUser string) (*Kudo, error) {
+// NewKudosFromText builds one kudo per user mentioned in the text, all sharing
+// the same message. A "pond:name" in the text sends them to that pond.
+func NewKudosFromText(text, teamID, fromUser string) ([]*Kudo, error) {
+	pond, text := parser.ParsePondFromText(text)
 	text = parser.RemoveWhitespace(text)
 
-	to, err := parser.ParseRecipientFromText(text)
+	recipients, err := parser.ParseRecipientsFromText(text)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Kudo{
-		FromUserID:  fromUser,
-		ToUserID:    to,
-		Message:     text,
-		IsPublic:    true,
-		IsAnonymous: false,
-	}, nil
+	kudos := make([]*Kudo, 0, len(recipients))
+	for _, to := range recipients {
+		kudo := NewKudo(teamID, fromUser, to, text)
+		kudo.Pond = pond
+		kudos = append(kudos, kudo)
+	}
+
+	return kudos, nil
 }
 
-func GetKudoByID(kudoID int) (*Kudo, error) {
-	var kudo Kudo
+// Entities are the mentions and links a message had before it was stored as
+// plain text, kept as JSON.
+type Entities []parser.Entity
 
-	result := db.First(&kudo, kudoID)
-	if result.Error != nil {
-		return nil, fmt.Errorf("could not find shout out: %v", result.Error)
+func (e Entities) Value() (driver.Value, error) {
+	if len(e) == 0 {
+		return "", nil
 	}
 
-	return &kudo, nil
-}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
 
-func (k *Kudo) Save() error {
-	result := db.Save(k)
-	return result.Error
+	return string(data), nil
 }
 
-func GetUnsharedKudos(public bool) ([]*Kudo, error) {
-	var kudos []*Kudo
-	result := db.Where("shared_at IS NULL").
-		Where("is_public = ?", public).
-		Order("to_user_id ASC").
-		Find(&kudos)
+func (e *Entities) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	case nil:
+	default:
+		return fmt.Errorf("can't scan %T into entities", value)
+	}
 
-	if result.Error != nil {
-		return nil, result.Error
+	if len(data) == 0 {
+		*e = nil
+		return nil
 	}
 
-	return kudos, nil
+	return json.Unmarshal(data, e)
 }
 
-func (k *Kudo) MarkShared(t time.Time) error {
-	k.SharedAt = null.TimeFrom(t)
-	return k.Save()
+// IsPending is true until the kudo has been released.
+func (k *Kudo) IsPending() bool {
+	return !k.SharedAt.Valid
 }
 
 var anonymousNames = []string{
@@ -124,7 +166,7 @@ var anonymousNames = []string{
 	"Coe Warker",
 }
 
-func (k *Kudo) GetDisplayFrom(mention bool) string {
+func (k *Kudo) GetDisplayFrom(s Store, mention bool) string {
 	if k.IsAnonymous {
 		return anonymousNames[rand.Intn(len(anonymousNames))]
 	}
@@ -134,8 +176,8 @@ func (k *Kudo) GetDisplayFrom(mention bool) string {
 	}
 
 	// We expect the user to exist (no fetch from slack required)
-	fromUser, err := GetUser(k.FromUserID)
-	if err != nil {
+	fromUser, err := s.GetUser(k.TeamID, k.FromUserID)
+	if err != nil || fromUser == nil {
 		return "?"
 	}
 
@@ -0,0 +1,914 @@
+package database
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+	"gorm.io/gorm"
+)
+
+// memoryStore keeps everything in maps, for tests that don't need a database.
+// Records are copied in and out so callers can't change stored state without
+// saving, the same as with a real database.
+type memoryStore struct {
+	mu      sync.Mutex
+	giverMu sync.Mutex
+
+	kudos       map[uint]Kudo
+	users       map[uint]User
+	schedules   map[uint]ReleaseSchedule
+	permissions map[uint]ReleasePermission
+	runs        map[uint]ReleaseRun
+	deliveries  map[uint]ReleaseDelivery
+	points      []PointEntry
+	rewards     map[uint]Reward
+	redemptions map[uint]Redemption
+	claims      map[string]ReleaseClaim
+
+	installations map[string]Installation
+
+	lastID uint
+}
+
+// NewMemoryStore is an empty Store that lives only as long as the process.
+func NewMemoryStore() Store {
+	return &memoryStore{
+		kudos:       map[uint]Kudo{},
+		users:       map[uint]User{},
+		schedules:   map[uint]ReleaseSchedule{},
+		permissions: map[uint]ReleasePermission{},
+		runs:        map[uint]ReleaseRun{},
+		deliveries:  map[uint]ReleaseDelivery{},
+		points:      []PointEntry{},
+		rewards:     map[uint]Reward{},
+		redemptions: map[uint]Redemption{},
+		claims:      map[string]ReleaseClaim{},
+
+		installations: map[string]Installation{},
+	}
+}
+
+func (s *memoryStore) nextID() uint {
+	s.lastID++
+	return s.lastID
+}
+
+// timestamps fills in the ID and timestamps the way gorm would on save.
+func (s *memoryStore) timestamps(id *uint, createdAt, updatedAt *time.Time) {
+	now := time.Now()
+	if *id == 0 {
+		*id = s.nextID()
+	}
+	if createdAt.IsZero() {
+		*createdAt = now
+	}
+	*updatedAt = now
+}
+
+func (s *memoryStore) GetKudoByID(teamID string, kudoID int) (*Kudo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kudo, ok := s.liveKudo(uint(kudoID))
+	if !ok || kudo.TeamID != teamID {
+		return nil, errors.New("could not find shout out: record not found")
+	}
+
+	return kudo, nil
+}
+
+func (s *memoryStore) liveKudo(kudoID uint) (*Kudo, bool) {
+	kudo, ok := s.kudos[kudoID]
+	if !ok || kudo.DeletedAt.Valid {
+		return nil, false
+	}
+
+	return &kudo, true
+}
+
+func (s *memoryStore) SaveKudo(kudo *Kudo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	isNew := kudo.ID == 0
+	if isNew && kudo.Reaction != "" {
+		counted := s.findKudos(func(k *Kudo) bool {
+			return k.TeamID == kudo.TeamID && k.ChannelID == kudo.ChannelID && k.SourceTS == kudo.SourceTS && k.FromUserID == kudo.FromUserID && k.Reaction != ""
+		})
+		if len(counted) > 0 {
+			return ErrDuplicateKudo
+		}
+	}
+	s.timestamps(&kudo.ID, &kudo.CreatedAt, &kudo.UpdatedAt)
+	if isNew && kudo.Points != 0 {
+		s.addPointEntry(newGivenPointEntry(kudo))
+	}
+	for _, category := range kudo.Categories {
+		category.KudoID = kudo.ID
+		if category.ID == 0 {
+			category.ID = s.nextID()
+			category.CreatedAt = kudo.UpdatedAt
+		}
+	}
+	s.kudos[kudo.ID] = *kudo
+
+	return nil
+}
+
+func (s *memoryStore) DeleteKudo(kudo *Kudo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.kudos[kudo.ID]
+	if !ok {
+		return nil
+	}
+
+	if stored.DeletedAt.Valid {
+		return nil
+	}
+
+	stored.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	s.kudos[kudo.ID] = stored
+	kudo.DeletedAt = stored.DeletedAt
+
+	if stored.IsPending() && stored.Points != 0 {
+		s.addPointEntry(newKudoPointEntry(&stored, stored.FromUserID, PointsReturned, stored.Points))
+	}
+
+	return nil
+}
+
+// findKudos returns the live kudos matching the filter, ordered by ID.
+func (s *memoryStore) findKudos(match func(*Kudo) bool) []*Kudo {
+	kudos := []*Kudo{}
+	for id := range s.kudos {
+		kudo, ok := s.liveKudo(id)
+		if ok && match(kudo) {
+			kudos = append(kudos, kudo)
+		}
+	}
+
+	sort.Slice(kudos, func(i, j int) bool {
+		return kudos[i].ID < kudos[j].ID
+	})
+
+	return kudos
+}
+
+func (s *memoryStore) GetUnsharedKudos(teamID string, public bool, filter KudoFilter) ([]*Kudo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kudos := s.findKudos(func(k *Kudo) bool {
+		return k.TeamID == teamID && !k.SharedAt.Valid && k.IsPublic == public && filter.Matches(k)
+	})
+	sort.SliceStable(kudos, func(i, j int) bool {
+		return kudos[i].ToUserID < kudos[j].ToUserID
+	})
+
+	return kudos, nil
+}
+
+func (s *memoryStore) GetReceivedKudos(teamID, userID string, offset, limit int) ([]*Kudo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kudos := s.findKudos(func(k *Kudo) bool {
+		return k.TeamID == teamID && k.ToUserID == userID && k.SharedAt.Valid
+	})
+	sort.SliceStable(kudos, func(i, j int) bool {
+		if !kudos[i].SharedAt.Time.Equal(kudos[j].SharedAt.Time) {
+			return kudos[i].SharedAt.Time.After(kudos[j].SharedAt.Time)
+		}
+		return kudos[i].ID > kudos[j].ID
+	})
+
+	return page(kudos, offset, limit), nil
+}
+
+func (s *memoryStore) GetPendingKudosFrom(teamID, userID string, limit int) ([]*Kudo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kudos := s.findKudos(func(k *Kudo) bool {
+		return k.TeamID == teamID && k.FromUserID == userID && !k.SharedAt.Valid
+	})
+
+	return page(kudos, 0, limit), nil
+}
+
+func page(kudos []*Kudo, offset, limit int) []*Kudo {
+	if offset >= len(kudos) {
+		return []*Kudo{}
+	}
+	kudos = kudos[offset:]
+	if limit < len(kudos) {
+		kudos = kudos[:limit]
+	}
+
+	return kudos
+}
+
+func (s *memoryStore) GetReactionKudo(teamID, channelID, sourceTS, fromUserID string) (*Kudo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kudos := s.findKudos(func(k *Kudo) bool {
+		return k.TeamID == teamID && k.ChannelID == channelID && k.SourceTS == sourceTS && k.FromUserID == fromUserID && k.Reaction != ""
+	})
+	if len(kudos) == 0 {
+		return nil, nil
+	}
+
+	return kudos[0], nil
+}
+
+func (s *memoryStore) GetUser(teamID, userID string) (*User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.users {
+		if user.TeamID == teamID && user.SlackID == userID {
+			return &user, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (s *memoryStore) CreateUser(user *User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.timestamps(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+	s.users[user.ID] = *user
+
+	return nil
+}
+
+// SaveUser is the same as creating one in memory, both overwrite by ID.
+func (s *memoryStore) SaveUser(user *User) error {
+	return s.CreateUser(user)
+}
+
+func (s *memoryStore) GetUsers(teamID string) ([]*User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users := []*User{}
+	for _, user := range s.users {
+		if user.TeamID == teamID {
+			user := user
+			users = append(users, &user)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].ID < users[j].ID
+	})
+
+	return users, nil
+}
+
+// countKudos tallies the workspace's kudos matching the stats filter by user.
+func (s *memoryStore) countKudos(teamID string, filter StatsFilter, match func(*Kudo) bool, userID func(*Kudo) string) map[string]int64 {
+	counts := map[string]int64{}
+	for _, kudo := range s.findKudos(match) {
+		if kudo.TeamID != teamID || !filter.Matches(kudo) {
+			continue
+		}
+		counts[userID(kudo)]++
+	}
+
+	return counts
+}
+
+func leaderboard(counts map[string]int64, limit int) []*KudoCount {
+	board := []*KudoCount{}
+	for userID, count := range counts {
+		board = append(board, &KudoCount{UserID: userID, Count: count})
+	}
+
+	sort.Slice(board, func(i, j int) bool {
+		if board[i].Count != board[j].Count {
+			return board[i].Count > board[j].Count
+		}
+		return board[i].UserID < board[j].UserID
+	})
+
+	if limit < len(board) {
+		board = board[:limit]
+	}
+
+	return board
+}
+
+func (s *memoryStore) GetTopReceivers(teamID string, filter StatsFilter, limit int) ([]*KudoCount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := s.countKudos(teamID, filter, func(k *Kudo) bool {
+		return k.SharedAt.Valid
+	}, func(k *Kudo) string {
+		return k.ToUserID
+	})
+
+	return leaderboard(counts, limit), nil
+}
+
+func (s *memoryStore) GetTopGivers(teamID string, filter StatsFilter, limit int) ([]*KudoCount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := s.countKudos(teamID, filter, func(k *Kudo) bool {
+		return k.SharedAt.Valid && !k.IsAnonymous
+	}, func(k *Kudo) string {
+		return k.FromUserID
+	})
+
+	return leaderboard(counts, limit), nil
+}
+
+func (s *memoryStore) CountReceived(teamID, userID string, filter StatsFilter) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := s.countKudos(teamID, filter, func(k *Kudo) bool {
+		return k.ToUserID == userID && k.SharedAt.Valid
+	}, func(k *Kudo) string {
+		return k.ToUserID
+	})
+
+	return counts[userID], nil
+}
+
+func (s *memoryStore) CountGiven(teamID, userID string, filter StatsFilter, includePrivate bool) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := s.countKudos(teamID, filter, func(k *Kudo) bool {
+		if k.FromUserID != userID {
+			return false
+		}
+		return includePrivate || (k.SharedAt.Valid && !k.IsAnonymous)
+	}, func(k *Kudo) string {
+		return k.FromUserID
+	})
+
+	return counts[userID], nil
+}
+
+func (s *memoryStore) CountGivenTo(teamID, fromUserID, toUserID string, since time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := s.countKudos(teamID, StatsFilter{Since: since}, func(k *Kudo) bool {
+		return k.FromUserID == fromUserID && k.ToUserID == toUserID
+	}, func(k *Kudo) string {
+		return k.FromUserID
+	})
+
+	return counts[fromUserID], nil
+}
+
+func (s *memoryStore) GetGivingCounts(teamID string, since time.Time, limit int) ([]*KudoCount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := s.countKudos(teamID, StatsFilter{Since: since}, func(k *Kudo) bool {
+		return true
+	}, func(k *Kudo) string {
+		return k.FromUserID
+	})
+
+	return leaderboard(counts, limit), nil
+}
+
+// WithGiverLock lets one giver through at a time, there's only ever one
+// process to worry about. Nothing is rolled back when fn fails.
+func (s *memoryStore) WithGiverLock(teamID, userID string, fn func(Store) error) error {
+	s.giverMu.Lock()
+	defer s.giverMu.Unlock()
+
+	return fn(s)
+}
+
+func (s *memoryStore) GetReleaseSchedules() ([]*ReleaseSchedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedules := []*ReleaseSchedule{}
+	for _, schedule := range s.schedules {
+		schedule := schedule
+		schedules = append(schedules, &schedule)
+	}
+
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].ID < schedules[j].ID
+	})
+
+	return schedules, nil
+}
+
+func (s *memoryStore) GetReleaseScheduleForChannel(teamID, channelID string) (*ReleaseSchedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, schedule := range s.schedules {
+		if schedule.TeamID == teamID && schedule.ChannelID == channelID {
+			return &schedule, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (s *memoryStore) SaveReleaseSchedule(schedule *ReleaseSchedule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.timestamps(&schedule.ID, &schedule.CreatedAt, &schedule.UpdatedAt)
+	s.schedules[schedule.ID] = *schedule
+
+	return nil
+}
+
+func (s *memoryStore) DeleteReleaseSchedule(schedule *ReleaseSchedule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.schedules, schedule.ID)
+
+	return nil
+}
+
+func (s *memoryStore) ClaimReleaseScheduleRun(schedule *ReleaseSchedule, t time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.schedules[schedule.ID]
+	if !ok || stored.LastRunAt.Valid != schedule.LastRunAt.Valid {
+		return false, nil
+	}
+	if stored.LastRunAt.Valid && !stored.LastRunAt.Time.Equal(schedule.LastRunAt.Time) {
+		return false, nil
+	}
+
+	stored.LastRunAt = null.TimeFrom(t)
+	stored.UpdatedAt = time.Now()
+	s.schedules[schedule.ID] = stored
+	schedule.LastRunAt = stored.LastRunAt
+
+	return true, nil
+}
+
+func (s *memoryStore) GetReleasePermissions(teamID string) ([]*ReleasePermission, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	permissions := []*ReleasePermission{}
+	for _, permission := range s.permissions {
+		if permission.TeamID == teamID {
+			permission := permission
+			permissions = append(permissions, &permission)
+		}
+	}
+
+	sort.Slice(permissions, func(i, j int) bool {
+		return permissions[i].ID < permissions[j].ID
+	})
+
+	return permissions, nil
+}
+
+func (s *memoryStore) GetReleasePermission(teamID, subjectID string) (*ReleasePermission, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, permission := range s.permissions {
+		if permission.TeamID == teamID && permission.SubjectID == subjectID {
+			return &permission, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (s *memoryStore) SaveReleasePermission(permission *ReleasePermission) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.timestamps(&permission.ID, &permission.CreatedAt, &permission.UpdatedAt)
+	s.permissions[permission.ID] = *permission
+
+	return nil
+}
+
+func (s *memoryStore) DeleteReleasePermission(permission *ReleasePermission) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.permissions, permission.ID)
+
+	return nil
+}
+
+func (s *memoryStore) CreateReleaseRun(run *ReleaseRun) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.saveReleaseRun(run)
+	for _, delivery := range run.Deliveries {
+		delivery.ReleaseRunID = run.ID
+		s.saveReleaseDelivery(delivery)
+	}
+
+	return nil
+}
+
+func (s *memoryStore) SaveReleaseRun(run *ReleaseRun) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.saveReleaseRun(run)
+
+	return nil
+}
+
+// saveReleaseRun leaves the deliveries out, they are saved on their own.
+func (s *memoryStore) saveReleaseRun(run *ReleaseRun) {
+	s.timestamps(&run.ID, &run.CreatedAt, &run.UpdatedAt)
+
+	stored := *run
+	stored.Deliveries = nil
+	s.runs[run.ID] = stored
+}
+
+func (s *memoryStore) findReleaseRuns(statuses ...string) []*ReleaseRun {
+	runs := []*ReleaseRun{}
+	for _, run := range s.runs {
+		for _, status := range statuses {
+			if run.Status == status {
+				runs = append(runs, s.loadDeliveries(run))
+				break
+			}
+		}
+	}
+
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].ID < runs[j].ID
+	})
+
+	return runs
+}
+
+// loadDeliveries mirrors the gorm preload, deleted kudos come back as nil.
+func (s *memoryStore) loadDeliveries(run ReleaseRun) *ReleaseRun {
+	for _, delivery := range s.deliveries {
+		if delivery.ReleaseRunID != run.ID {
+			continue
+		}
+
+		delivery := delivery
+		delivery.Kudo, _ = s.liveKudo(delivery.KudoID)
+		run.Deliveries = append(run.Deliveries, &delivery)
+	}
+
+	sort.Slice(run.Deliveries, func(i, j int) bool {
+		return run.Deliveries[i].ID < run.Deliveries[j].ID
+	})
+
+	return &run
+}
+
+func (s *memoryStore) GetReleaseRun(teamID string, id uint) (*ReleaseRun, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	run, ok := s.runs[id]
+	if !ok || run.TeamID != teamID {
+		return nil, nil
+	}
+
+	return s.loadDeliveries(run), nil
+}
+
+func (s *memoryStore) GetInterruptedReleaseRuns() ([]*ReleaseRun, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.findReleaseRuns(ReleaseRunRunning), nil
+}
+
+func (s *memoryStore) GetLatestUnfinishedReleaseRun(teamID string) (*ReleaseRun, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	runs := s.findReleaseRuns(ReleaseRunRunning, ReleaseRunIncomplete)
+	for i := len(runs) - 1; i >= 0; i-- {
+		if runs[i].TeamID == teamID {
+			return runs[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (s *memoryStore) SaveReleaseDelivery(delivery *ReleaseDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.saveReleaseDelivery(delivery)
+
+	return nil
+}
+
+// saveReleaseDelivery leaves the kudo out, it is saved on its own.
+func (s *memoryStore) saveReleaseDelivery(delivery *ReleaseDelivery) {
+	s.timestamps(&delivery.ID, &delivery.CreatedAt, &delivery.UpdatedAt)
+
+	stored := *delivery
+	stored.Kudo = nil
+	s.deliveries[delivery.ID] = stored
+}
+
+func (s *memoryStore) MarkDeliveryPosted(delivery *ReleaseDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.saveReleaseDelivery(delivery)
+
+	kudo, ok := s.kudos[delivery.KudoID]
+	if ok {
+		kudo.SharedAt = delivery.Kudo.SharedAt
+		s.kudos[kudo.ID] = kudo
+	}
+
+	if delivery.Kudo.Points != 0 {
+		s.addPointEntry(newKudoPointEntry(delivery.Kudo, delivery.Kudo.ToUserID, PointsReceived, delivery.Kudo.Points))
+	}
+
+	return nil
+}
+
+func (s *memoryStore) ClaimRelease(teamID, owner string, until time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	claim, ok := s.claims[teamID]
+	if ok && claim.Owner != owner && !claim.ExpiresAt.Before(time.Now()) {
+		return false, nil
+	}
+
+	s.claims[teamID] = ReleaseClaim{TeamID: teamID, Owner: owner, ExpiresAt: until}
+
+	return true, nil
+}
+
+func (s *memoryStore) UnclaimRelease(teamID, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.claims[teamID].Owner == owner {
+		delete(s.claims, teamID)
+	}
+
+	return nil
+}
+
+func (s *memoryStore) addPointEntry(entry *PointEntry) {
+	entry.ID = s.nextID()
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	s.points = append(s.points, *entry)
+}
+
+func (s *memoryStore) GetPointsBalance(teamID, userID string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.pointsBalance(teamID, userID), nil
+}
+
+func (s *memoryStore) pointsBalance(teamID, userID string) int {
+	balance := 0
+	for _, entry := range s.points {
+		if entry.TeamID == teamID && entry.UserID == userID && !isAllowanceKind(entry.Kind) {
+			balance += entry.Amount
+		}
+	}
+
+	return balance
+}
+
+func (s *memoryStore) GetPointsGiven(teamID, userID string, since time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	given := 0
+	for _, entry := range s.points {
+		if entry.TeamID != teamID || entry.UserID != userID || !isAllowanceKind(entry.Kind) {
+			continue
+		}
+
+		// Deleted kudos are kept, so returns are dated by the kudo
+		kudo, ok := s.kudos[uint(entry.KudoID.Int64)]
+		if ok && !kudo.CreatedAt.Before(since) {
+			given -= entry.Amount
+		}
+	}
+
+	return given, nil
+}
+
+func (s *memoryStore) GetPointEntries(teamID, userID string, limit int) ([]*PointEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := []*PointEntry{}
+	for i := len(s.points) - 1; i >= 0 && len(entries) < limit; i-- {
+		entry := s.points[i]
+		if entry.TeamID == teamID && entry.UserID == userID {
+			entries = append(entries, &entry)
+		}
+	}
+
+	return entries, nil
+}
+
+func (s *memoryStore) GetRewards(teamID string) ([]*Reward, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rewards := []*Reward{}
+	for _, reward := range s.rewards {
+		if reward.TeamID == teamID && !reward.DeletedAt.Valid {
+			reward := reward
+			rewards = append(rewards, &reward)
+		}
+	}
+
+	sort.Slice(rewards, func(i, j int) bool {
+		if rewards[i].Cost != rewards[j].Cost {
+			return rewards[i].Cost < rewards[j].Cost
+		}
+		return rewards[i].Name < rewards[j].Name
+	})
+
+	return rewards, nil
+}
+
+func (s *memoryStore) GetReward(teamID string, rewardID int) (*Reward, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reward, ok := s.rewards[uint(rewardID)]
+	if !ok || reward.TeamID != teamID || reward.DeletedAt.Valid {
+		return nil, nil
+	}
+
+	return &reward, nil
+}
+
+func (s *memoryStore) SaveReward(reward *Reward) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.timestamps(&reward.ID, &reward.CreatedAt, &reward.UpdatedAt)
+	s.rewards[reward.ID] = *reward
+
+	return nil
+}
+
+func (s *memoryStore) DeleteReward(reward *Reward) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.rewards[reward.ID]
+	if !ok {
+		return nil
+	}
+
+	stored.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	s.rewards[reward.ID] = stored
+	reward.DeletedAt = stored.DeletedAt
+
+	return nil
+}
+
+func (s *memoryStore) CreateRedemption(redemption *Redemption) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.pointsBalance(redemption.TeamID, redemption.UserID) < redemption.Cost {
+		return false, nil
+	}
+
+	s.timestamps(&redemption.ID, &redemption.CreatedAt, &redemption.UpdatedAt)
+	stored := *redemption
+	stored.Reward = nil
+	s.redemptions[redemption.ID] = stored
+	s.addPointEntry(newRedemptionPointEntry(redemption, PointsRedeemed, -redemption.Cost))
+
+	return true, nil
+}
+
+func (s *memoryStore) GetRedemption(teamID string, redemptionID int) (*Redemption, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	redemption, ok := s.redemptions[uint(redemptionID)]
+	if !ok || redemption.TeamID != teamID {
+		return nil, nil
+	}
+
+	reward, ok := s.rewards[redemption.RewardID]
+	if ok {
+		redemption.Reward = &reward
+	}
+
+	return &redemption, nil
+}
+
+func (s *memoryStore) DecideRedemption(redemption *Redemption) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.redemptions[redemption.ID]
+	if !ok || !stored.IsPending() {
+		return false, nil
+	}
+
+	stored.Status = redemption.Status
+	stored.DecidedBy = redemption.DecidedBy
+	stored.DecidedAt = redemption.DecidedAt
+	stored.UpdatedAt = time.Now()
+	s.redemptions[stored.ID] = stored
+
+	if stored.Status == RedemptionRejected {
+		s.addPointEntry(newRedemptionPointEntry(&stored, PointsRefunded, stored.Cost))
+	}
+
+	return true, nil
+}
+
+func (s *memoryStore) GetInstallation(teamID string) (*Installation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	installation, ok := s.installations[teamID]
+	if !ok {
+		return nil, nil
+	}
+
+	return &installation, nil
+}
+
+func (s *memoryStore) SaveInstallation(installation *Installation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if stored, ok := s.installations[installation.TeamID]; ok {
+		installation.CreatedAt = stored.CreatedAt
+	} else if installation.CreatedAt.IsZero() {
+		installation.CreatedAt = now
+	}
+	installation.UpdatedAt = now
+	s.installations[installation.TeamID] = *installation
+
+	return nil
+}
+
+func (s *memoryStore) DeleteInstallation(installation *Installation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.installations, installation.TeamID)
+
+	return nil
+}
+
+func (s *memoryStore) AdoptUnscopedRecords(teamID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, kudo := range s.kudos {
+		if kudo.TeamID == "" {
+			kudo.TeamID = teamID
+			s.kudos[id] = kudo
+		}
+	}
+	for id, run := range s.runs {
+		if run.TeamID == "" {
+			run.TeamID = teamID
+			s.runs[id] = run
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,6 @@
+ALTER TABLE release_schedules DROP COLUMN scope_user_group_id;
+ALTER TABLE release_schedules DROP COLUMN scope_channel_id;
+ALTER TABLE release_schedules DROP COLUMN scope_pond;
+
+ALTER TABLE kudos DROP COLUMN pond;
+ALTER TABLE kudos DROP COLUMN channel_id;
@@ -0,0 +1,6 @@
+ALTER TABLE kudos ADD COLUMN channel_id VARCHAR(50) NOT NULL DEFAULT '';
+ALTER TABLE kudos ADD COLUMN pond VARCHAR(100) NOT NULL DEFAULT '';
+
+ALTER TABLE release_schedules ADD COLUMN scope_pond VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE release_schedules ADD COLUMN scope_channel_id VARCHAR(50) NOT NULL DEFAULT '';
+ALTER TABLE release_schedules ADD COLUMN scope_user_group_id VARCHAR(50) NOT NULL DEFAULT '';
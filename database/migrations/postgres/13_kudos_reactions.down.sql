@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_kudos_reaction;
+
+ALTER TABLE kudos DROP COLUMN reaction;
+ALTER TABLE kudos DROP COLUMN source_ts;
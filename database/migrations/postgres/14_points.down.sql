@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS point_entries;
+
+ALTER TABLE kudos DROP COLUMN points;
@@ -0,0 +1,4 @@
+ALTER TABLE point_entries DROP COLUMN redemption_id;
+
+DROP TABLE IF EXISTS redemptions;
+DROP TABLE IF EXISTS rewards;
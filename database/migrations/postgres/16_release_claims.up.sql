@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS release_claims (
+    team_id VARCHAR(50) PRIMARY KEY,
+    owner VARCHAR(50) NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
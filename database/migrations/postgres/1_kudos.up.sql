@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS kudos (
+    id SERIAL PRIMARY KEY,
+    from_user_id VARCHAR(50) NOT NULL,
+    is_anonymous BOOLEAN NOT NULL DEFAULT FALSE,
+    message TEXT,
+    to_user_id VARCHAR(50) NOT NULL,
+    is_public BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    shared_at TIMESTAMPTZ DEFAULT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_kudos_shared_at ON kudos (shared_at);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS release_permissions (
+    id SERIAL PRIMARY KEY,
+    team_id VARCHAR(50) NOT NULL,
+    subject_id VARCHAR(50) NOT NULL,
+    is_user_group BOOLEAN NOT NULL DEFAULT FALSE,
+    granted_by VARCHAR(50) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_release_permissions_subject ON release_permissions (team_id, subject_id);
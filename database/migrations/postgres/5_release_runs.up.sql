@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS release_runs (
+    id SERIAL PRIMARY KEY,
+    channel_id VARCHAR(50) NOT NULL,
+    released_by VARCHAR(50) NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL DEFAULT 'running',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMPTZ DEFAULT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_release_runs_status ON release_runs (status);
+
+CREATE TABLE IF NOT EXISTS release_deliveries (
+    id SERIAL PRIMARY KEY,
+    release_run_id INTEGER NOT NULL REFERENCES release_runs (id),
+    kudo_id INTEGER NOT NULL REFERENCES kudos (id),
+    is_public BOOLEAN NOT NULL DEFAULT TRUE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    thread_ts VARCHAR(50) NOT NULL DEFAULT '',
+    message_ts VARCHAR(50) NOT NULL DEFAULT '',
+    error TEXT,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_release_deliveries_run_kudo ON release_deliveries (release_run_id, kudo_id);
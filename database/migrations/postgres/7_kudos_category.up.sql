@@ -0,0 +1 @@
+ALTER TABLE kudos ADD COLUMN category VARCHAR(100) NOT NULL DEFAULT '';
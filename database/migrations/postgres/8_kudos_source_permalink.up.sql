@@ -0,0 +1 @@
+ALTER TABLE kudos ADD COLUMN source_permalink VARCHAR(255) NOT NULL DEFAULT '';
@@ -0,0 +1,17 @@
+ALTER TABLE kudos ADD COLUMN team_id VARCHAR(50) NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_kudos_team_shared_at ON kudos (team_id, shared_at);
+
+ALTER TABLE release_runs ADD COLUMN team_id VARCHAR(50) NOT NULL DEFAULT '';
+
+DROP INDEX IF EXISTS idx_slack_id;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_team_slack_id ON users (team_id, slack_id);
+
+CREATE TABLE IF NOT EXISTS installations (
+    team_id VARCHAR(50) PRIMARY KEY,
+    team_name VARCHAR(255) NOT NULL DEFAULT '',
+    bot_token VARCHAR(255) NOT NULL,
+    bot_user_id VARCHAR(50) NOT NULL DEFAULT '',
+    installed_by VARCHAR(50) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1 @@
+ALTER TABLE kudos ADD COLUMN entities TEXT NOT NULL DEFAULT '';
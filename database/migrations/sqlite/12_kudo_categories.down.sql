@@ -0,0 +1,8 @@
+ALTER TABLE kudos ADD COLUMN category VARCHAR(100) NOT NULL DEFAULT '';
+
+UPDATE kudos SET category = COALESCE(
+    (SELECT name FROM kudo_categories WHERE kudo_categories.kudo_id = kudos.id ORDER BY id LIMIT 1),
+    ''
+);
+
+DROP TABLE IF EXISTS kudo_categories;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS kudo_categories (
+    id INTEGER PRIMARY KEY,
+    kudo_id INTEGER NOT NULL REFERENCES kudos (id),
+    team_id VARCHAR(50) NOT NULL DEFAULT '',
+    name VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_kudo_categories_kudo_name ON kudo_categories (kudo_id, name);
+CREATE INDEX IF NOT EXISTS idx_kudo_categories_team_name ON kudo_categories (team_id, name);
+
+INSERT INTO kudo_categories (kudo_id, team_id, name)
+    SELECT id, team_id, category FROM kudos WHERE category != '';
+
+ALTER TABLE kudos DROP COLUMN category;
@@ -0,0 +1,5 @@
+ALTER TABLE kudos ADD COLUMN source_ts VARCHAR(50) NOT NULL DEFAULT '';
+ALTER TABLE kudos ADD COLUMN reaction VARCHAR(100) NOT NULL DEFAULT '';
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_kudos_reaction ON kudos (team_id, channel_id, source_ts, from_user_id)
+    WHERE reaction != '' AND deleted_at IS NULL;
@@ -0,0 +1,14 @@
+ALTER TABLE kudos ADD COLUMN points INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS point_entries (
+    id INTEGER PRIMARY KEY,
+    team_id VARCHAR(50) NOT NULL DEFAULT '',
+    user_id VARCHAR(50) NOT NULL,
+    kudo_id INTEGER DEFAULT NULL REFERENCES kudos (id),
+    kind VARCHAR(20) NOT NULL,
+    amount INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_point_entries_team_user ON point_entries (team_id, user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_point_entries_kudo ON point_entries (kudo_id);
@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS rewards (
+    id INTEGER PRIMARY KEY,
+    team_id VARCHAR(50) NOT NULL DEFAULT '',
+    name VARCHAR(200) NOT NULL,
+    cost INTEGER NOT NULL,
+    created_by VARCHAR(50) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP DEFAULT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_rewards_team ON rewards (team_id);
+
+CREATE TABLE IF NOT EXISTS redemptions (
+    id INTEGER PRIMARY KEY,
+    team_id VARCHAR(50) NOT NULL DEFAULT '',
+    reward_id INTEGER NOT NULL REFERENCES rewards (id),
+    user_id VARCHAR(50) NOT NULL,
+    cost INTEGER NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    decided_by VARCHAR(50) NOT NULL DEFAULT '',
+    decided_at TIMESTAMP DEFAULT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_redemptions_team_status ON redemptions (team_id, status);
+
+ALTER TABLE point_entries ADD COLUMN redemption_id INTEGER DEFAULT NULL;
@@ -10,4 +10,4 @@ CREATE TABLE IF NOT EXISTS kudos (
     shared_at DATETIME DEFAULT NULL
 );
 
-CREATE INDEX IF NOT EXISTS kudos (shared_at)
+CREATE INDEX IF NOT EXISTS idx_kudos_shared_at ON kudos (shared_at);
@@ -8,4 +8,4 @@ CREATE TABLE IF NOT EXISTS users (
     updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
 
-CREATE UNIQUE INDEX idx_slack_id ON users (slack_id)
+CREATE UNIQUE INDEX IF NOT EXISTS idx_slack_id ON users (slack_id);
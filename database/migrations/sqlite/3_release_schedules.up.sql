@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS release_schedules (
+    id INTEGER PRIMARY KEY,
+    team_id VARCHAR(50) NOT NULL,
+    channel_id VARCHAR(50) NOT NULL,
+    cron_expression VARCHAR(100) NOT NULL,
+    created_by VARCHAR(50) NOT NULL,
+    last_run_at DATETIME DEFAULT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_release_schedules_channel ON release_schedules (team_id, channel_id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS release_deliveries;
+DROP TABLE IF EXISTS release_runs;
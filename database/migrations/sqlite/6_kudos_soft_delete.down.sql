@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_kudos_deleted_at;
+ALTER TABLE kudos DROP COLUMN deleted_at;
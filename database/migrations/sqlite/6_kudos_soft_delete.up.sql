@@ -0,0 +1,3 @@
+ALTER TABLE kudos ADD COLUMN deleted_at DATETIME DEFAULT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_kudos_deleted_at ON kudos (deleted_at);
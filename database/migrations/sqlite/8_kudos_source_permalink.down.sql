@@ -0,0 +1 @@
+ALTER TABLE kudos DROP COLUMN source_permalink;
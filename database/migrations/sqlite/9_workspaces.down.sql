@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS installations;
+
+DROP INDEX IF EXISTS idx_users_team_slack_id;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_slack_id ON users (slack_id);
+
+ALTER TABLE release_runs DROP COLUMN team_id;
+
+DROP INDEX IF EXISTS idx_kudos_team_shared_at;
+ALTER TABLE kudos DROP COLUMN team_id;
@@ -0,0 +1,76 @@
+package database
+
+import (
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+// Kinds of PointEntry. Given and returned entries move the giver's monthly
+// allowance, the rest make up a balance.
+const (
+	PointsGiven    = "given"
+	PointsReturned = "returned"
+	PointsReceived = "received"
+	PointsRedeemed = "redeemed"
+	PointsRefunded = "refunded"
+)
+
+// allowanceKinds are the entries that count against what the giver can give
+// out, rather than toward their balance.
+var allowanceKinds = []string{PointsGiven, PointsReturned}
+
+// PointEntry struct represents one line in the points ledger. Balances and
+// allowances are only ever summed from these, so every change can be traced
+// back to the shout out or redemption behind it.
+type PointEntry struct {
+	ID           uint `gorm:"primarykey"`
+	TeamID       string
+	UserID       string
+	KudoID       null.Int
+	RedemptionID null.Int
+	Kind         string
+	Amount       int
+	CreatedAt    time.Time
+}
+
+func newKudoPointEntry(kudo *Kudo, userID, kind string, amount int) *PointEntry {
+	return &PointEntry{
+		TeamID: kudo.TeamID,
+		UserID: userID,
+		KudoID: null.IntFrom(int64(kudo.ID)),
+		Kind:   kind,
+		Amount: amount,
+	}
+}
+
+// newGivenPointEntry takes the kudo's points from the giver, dated when the
+// kudo was given.
+func newGivenPointEntry(kudo *Kudo) *PointEntry {
+	entry := newKudoPointEntry(kudo, kudo.FromUserID, PointsGiven, -kudo.Points)
+	entry.CreatedAt = kudo.CreatedAt
+
+	return entry
+}
+
+func newRedemptionPointEntry(redemption *Redemption, kind string, amount int) *PointEntry {
+	return &PointEntry{
+		TeamID:       redemption.TeamID,
+		UserID:       redemption.UserID,
+		RedemptionID: null.IntFrom(int64(redemption.ID)),
+		Kind:         kind,
+		Amount:       amount,
+	}
+}
+
+// isAllowanceKind is true for entries that count against the giver's
+// allowance.
+func isAllowanceKind(kind string) bool {
+	for _, k := range allowanceKinds {
+		if k == kind {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,26 @@
+package database
+
+import (
+	"time"
+)
+
+// ReleasePermission struct represents a user or user group allowed to release
+// shout outs.
+type ReleasePermission struct {
+	ID          uint `gorm:"primarykey"`
+	TeamID      string
+	SubjectID   string
+	IsUserGroup bool
+	GrantedBy   string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func NewReleasePermission(teamID, subjectID string, isUserGroup bool, grantedBy string) *ReleasePermission {
+	return &ReleasePermission{
+		TeamID:      teamID,
+		SubjectID:   subjectID,
+		IsUserGroup: isUserGroup,
+		GrantedBy:   grantedBy,
+	}
+}
@@ -0,0 +1,133 @@
+package database
+
+import (
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+const (
+	ReleaseRunRunning    = "running"
+	ReleaseRunCompleted  = "completed"
+	ReleaseRunIncomplete = "incomplete"
+)
+
+const (
+	DeliveryPending = "pending"
+	DeliveryPosted  = "posted"
+	DeliveryFailed  = "failed"
+	DeliverySkipped = "skipped"
+)
+
+// ReleaseRun struct represents one release of shout outs into a channel.
+type ReleaseRun struct {
+	ID         uint `gorm:"primarykey"`
+	TeamID     string
+	ChannelID  string
+	ReleasedBy string
+	Status     string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	FinishedAt null.Time
+	Deliveries []*ReleaseDelivery
+}
+
+// ReleaseDelivery struct represents the delivery state of one kudo in a run.
+type ReleaseDelivery struct {
+	ID           uint `gorm:"primarykey"`
+	ReleaseRunID uint
+	KudoID       uint
+	Kudo         *Kudo
+	IsPublic     bool
+	Status       string
+	ThreadTS     string
+	MessageTS    string
+	Error        null.String
+	Attempts     int
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// ReleaseClaim struct represents the release going out in a workspace, one at
+// a time. The owner renews it while releasing, once it expires another
+// release can claim the workspace.
+type ReleaseClaim struct {
+	TeamID    string `gorm:"primarykey"`
+	Owner     string
+	ExpiresAt time.Time
+}
+
+// NewReleaseRun queues a pending delivery for each kudo, in the order given.
+// The releasedBy user is empty for scheduled releases.
+func NewReleaseRun(teamID, channelID, releasedBy string, kudos []*Kudo) *ReleaseRun {
+	run := &ReleaseRun{
+		TeamID:     teamID,
+		ChannelID:  channelID,
+		ReleasedBy: releasedBy,
+		Status:     ReleaseRunRunning,
+	}
+
+	for _, kudo := range kudos {
+		run.Deliveries = append(run.Deliveries, &ReleaseDelivery{
+			KudoID:   kudo.ID,
+			Kudo:     kudo,
+			IsPublic: kudo.IsPublic,
+			Status:   DeliveryPending,
+		})
+	}
+
+	return run
+}
+
+// Finish marks the run completed, or incomplete if anything failed to deliver.
+func (r *ReleaseRun) Finish(t time.Time) {
+	r.Status = ReleaseRunCompleted
+	for _, delivery := range r.Deliveries {
+		if delivery.Status == DeliveryPending || delivery.Status == DeliveryFailed {
+			r.Status = ReleaseRunIncomplete
+			break
+		}
+	}
+	r.FinishedAt = null.TimeFrom(t)
+}
+
+func (r *ReleaseRun) FailedDeliveries() []*ReleaseDelivery {
+	return r.deliveriesWithStatus(DeliveryFailed)
+}
+
+func (r *ReleaseRun) PendingDeliveries() []*ReleaseDelivery {
+	return r.deliveriesWithStatus(DeliveryPending)
+}
+
+func (r *ReleaseRun) deliveriesWithStatus(status string) []*ReleaseDelivery {
+	deliveries := []*ReleaseDelivery{}
+	for _, delivery := range r.Deliveries {
+		if delivery.Status == status {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+
+	return deliveries
+}
+
+// MarkPosted records the delivery and shares the kudo, Store.MarkDeliveryPosted
+// saves both together.
+func (d *ReleaseDelivery) MarkPosted(messageTs, threadTs string, t time.Time) {
+	d.Status = DeliveryPosted
+	d.MessageTS = messageTs
+	d.ThreadTS = threadTs
+	d.Error = null.String{}
+	d.Attempts++
+	d.Kudo.SharedAt = null.TimeFrom(t)
+}
+
+func (d *ReleaseDelivery) MarkFailed(deliveryErr error) {
+	d.Status = DeliveryFailed
+	d.Error = null.StringFrom(deliveryErr.Error())
+	d.Attempts++
+}
+
+// MarkSkipped is for kudos shared or removed since the run was queued.
+func (d *ReleaseDelivery) MarkSkipped() {
+	d.Status = DeliverySkipped
+}
@@ -0,0 +1,38 @@
+package database
+
+import (
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+// ReleaseSchedule struct represents a recurring automatic release into a channel.
+type ReleaseSchedule struct {
+	ID             uint `gorm:"primarykey"`
+	TeamID         string
+	ChannelID      string
+	CronExpression string
+	Scope          ReleaseScope `gorm:"embedded;embeddedPrefix:scope_"`
+	CreatedBy      string
+	LastRunAt      null.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+func NewReleaseSchedule(teamID, channelID, expression, createdBy string) *ReleaseSchedule {
+	return &ReleaseSchedule{
+		TeamID:         teamID,
+		ChannelID:      channelID,
+		CronExpression: expression,
+		CreatedBy:      createdBy,
+	}
+}
+
+// LastRunOrCreated is the point from which the next run should be calculated.
+func (s *ReleaseSchedule) LastRunOrCreated() time.Time {
+	if s.LastRunAt.Valid {
+		return s.LastRunAt.Time
+	}
+
+	return s.CreatedAt
+}
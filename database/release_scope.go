@@ -0,0 +1,45 @@
+package database
+
+// ReleaseScope limits a release to part of the workspace's pending shout outs:
+// those for a pond, given in a channel, or to members of a user group. Empty
+// fields don't limit anything.
+type ReleaseScope struct {
+	Pond        string
+	ChannelID   string
+	UserGroupID string
+}
+
+func (s ReleaseScope) IsEmpty() bool {
+	return s.Pond == "" && s.ChannelID == "" && s.UserGroupID == ""
+}
+
+// KudoFilter narrows down which pending kudos are fetched. User groups live
+// in Slack, so a scope's group is looked up into ToUserIDs by the caller.
+type KudoFilter struct {
+	Pond      string
+	ChannelID string
+
+	// ToUserIDs limits recipients when not nil, an empty list matches nothing
+	ToUserIDs []string
+}
+
+// Matches applies the filter in memory, the same way the query does.
+func (f KudoFilter) Matches(kudo *Kudo) bool {
+	if f.Pond != "" && kudo.Pond != f.Pond {
+		return false
+	}
+	if f.ChannelID != "" && kudo.ChannelID != f.ChannelID {
+		return false
+	}
+	if f.ToUserIDs == nil {
+		return true
+	}
+
+	for _, userID := range f.ToUserIDs {
+		if kudo.ToUserID == userID {
+			return true
+		}
+	}
+
+	return false
+}
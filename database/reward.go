@@ -0,0 +1,82 @@
+package database
+
+import (
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+	"gorm.io/gorm"
+)
+
+// Reward struct represents an item in the catalog points can be redeemed for.
+type Reward struct {
+	ID        uint `gorm:"primarykey"`
+	TeamID    string
+	Name      string
+	Cost      int
+	CreatedBy string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt
+}
+
+func NewReward(teamID, name string, cost int, createdBy string) *Reward {
+	return &Reward{
+		TeamID:    teamID,
+		Name:      name,
+		Cost:      cost,
+		CreatedBy: createdBy,
+	}
+}
+
+// Statuses a Redemption moves through, it's decided only once.
+const (
+	RedemptionPending  = "pending"
+	RedemptionApproved = "approved"
+	RedemptionRejected = "rejected"
+)
+
+// Redemption struct represents someone cashing in points for a reward. The
+// cost is kept separately, the reward's price may change while it waits for
+// approval.
+type Redemption struct {
+	ID        uint `gorm:"primarykey"`
+	TeamID    string
+	RewardID  uint
+	Reward    *Reward
+	UserID    string
+	Cost      int
+	Status    string
+	DecidedBy string
+	DecidedAt null.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func NewRedemption(reward *Reward, userID string) *Redemption {
+	return &Redemption{
+		TeamID:   reward.TeamID,
+		RewardID: reward.ID,
+		Reward:   reward,
+		UserID:   userID,
+		Cost:     reward.Cost,
+		Status:   RedemptionPending,
+	}
+}
+
+func (r *Redemption) Approve(userID string, t time.Time) {
+	r.decide(RedemptionApproved, userID, t)
+}
+
+func (r *Redemption) Reject(userID string, t time.Time) {
+	r.decide(RedemptionRejected, userID, t)
+}
+
+func (r *Redemption) decide(status, userID string, t time.Time) {
+	r.Status = status
+	r.DecidedBy = userID
+	r.DecidedAt = null.TimeFrom(t)
+}
+
+func (r *Redemption) IsPending() bool {
+	return r.Status == RedemptionPending
+}
@@ -0,0 +1,25 @@
+package database
+
+import "time"
+
+// StatsFilter limits stats to kudos created since the given time and, when
+// set, tagged with the category.
+type StatsFilter struct {
+	Since    time.Time
+	Category string
+}
+
+// Matches applies the filter in memory, for stores without a query language.
+func (f StatsFilter) Matches(kudo *Kudo) bool {
+	if kudo.CreatedAt.Before(f.Since) {
+		return false
+	}
+
+	return f.Category == "" || kudo.HasCategory(f.Category)
+}
+
+// KudoCount is one row of a leaderboard.
+type KudoCount struct {
+	UserID string
+	Count  int64
+}
@@ -4,9 +4,7 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/pkg/errors"
 	"github.com/slack-go/slack"
-	"gorm.io/gorm"
 )
 
 // User struct represents user model.
@@ -20,12 +18,18 @@ type User struct {
 	UpdatedAt   time.Time
 }
 
-func CreateUserFromSlackUser(slackUser *slack.User, db *gorm.DB) *User {
+func NewUserFromSlackUser(slackUser *slack.User) *User {
 	var user User
 
 	user.SlackID = slackUser.ID
 	user.TeamID = slackUser.TeamID
+	user.UpdateFromSlackUser(slackUser)
 
+	return &user
+}
+
+// UpdateFromSlackUser copies the names over from the user's Slack profile.
+func (user *User) UpdateFromSlackUser(slackUser *slack.User) {
 	// Counting on one of these always being present
 	if slackUser.Profile.RealNameNormalized != "" {
 		user.RealName = slackUser.Profile.RealNameNormalized
@@ -41,46 +45,57 @@ func CreateUserFromSlackUser(slackUser *slack.User, db *gorm.DB) *User {
 	} else {
 		user.DisplayName = user.RealName
 	}
+}
 
-	db.Create(&user)
-
-	return &user
+// IsStale is true once the user was last synced with Slack more than ttl ago.
+// A zero ttl never goes stale.
+func (user *User) IsStale(ttl time.Duration, now time.Time) bool {
+	return ttl > 0 && now.Sub(user.UpdatedAt) > ttl
 }
 
-func GetOrFetchUser(userID string, fetch func(string) (*slack.User, error)) (*User, error) {
-	user, err := GetUser(userID)
+// GetOrFetchUser looks the user up in the workspace, fetching and storing them
+// the first time they're seen, and refreshing them once they're older than
+// ttl.
+func GetOrFetchUser(s Store, teamID, userID string, ttl time.Duration, fetch func(string) (*slack.User, error)) (*User, error) {
+	user, err := s.GetUser(teamID, userID)
 	if err != nil {
 		return nil, err
 	}
 
-	// If the user exists in DB already, return
-	if user != nil {
+	// If the user exists in DB already and is fresh enough, return
+	if user != nil && !user.IsStale(ttl, time.Now()) {
 		return user, nil
 	}
 
 	// Fetch user info for DB
 	slackUser, err := fetch(userID)
+	if user != nil && (err != nil || slackUser == nil) {
+		// A stale name beats failing the whole shout out
+		fmt.Printf("Failed to refresh user %s, keeping the stored one: %v\n", userID, err)
+		return user, nil
+	}
 	if err != nil || slackUser == nil {
 		return nil, fmt.Errorf("error fetching user: %v", err)
 	}
 
-	user = CreateUserFromSlackUser(slackUser, db)
-
-	return user, nil
-}
-
-func GetUser(userID string) (*User, error) {
-	var user User
-	result := db.Where("slack_id = ?", userID).First(&user)
+	if user != nil {
+		user.UpdateFromSlackUser(slackUser)
+		err = s.SaveUser(user)
+		if err != nil {
+			return nil, fmt.Errorf("error storing user: %v", err)
+		}
 
-	if result.Error != nil && !errors.Is(result.Error, gorm.ErrRecordNotFound) {
-		return nil, fmt.Errorf("error querying for db user: %v", result.Error)
+		return user, nil
 	}
 
-	// No user found
-	if result.RowsAffected == 0 {
-		return nil, nil
+	user = NewUserFromSlackUser(slackUser)
+	// Shared channel and Enterprise Grid users belong to another team, but are
+	// looked up in this one
+	user.TeamID = teamID
+	err = s.CreateUser(user)
+	if err != nil {
+		return nil, fmt.Errorf("error storing user: %v", err)
 	}
 
-	return &user, nil
+	return user, nil
 }
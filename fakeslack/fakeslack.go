@@ -0,0 +1,536 @@
+// Package fakeslack stands in for Slack in tests. It serves the Web API calls
+// the bot makes, records them, and drives Socket Mode or signed HTTP requests
+// so scripted events can be sent through the same paths as in production.
+package fakeslack
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/slack-go/slack"
+)
+
+// Timeout bounds every wait, so a broken test fails rather than hangs.
+var Timeout = 5 * time.Second
+
+// Call is one Web API request. Form and query parameters are in Values, JSON
+// requests such as views.open are left in Body. Token is the token it was
+// made with, to tell workspaces apart.
+type Call struct {
+	Method string
+	Token  string
+	Values url.Values
+	Body   []byte
+}
+
+// Decode unmarshals a JSON request body.
+func (c Call) Decode(v interface{}) error {
+	return json.Unmarshal(c.Body, v)
+}
+
+// Server is a fake Slack workspace with a single bot. More workspaces can
+// install the app over OAuth, see AddInstall.
+type Server struct {
+	BotUserID     string
+	TeamID        string
+	SigningSecret string
+
+	server *httptest.Server
+
+	mu           sync.Mutex
+	calls        []Call
+	users        map[string]slack.User
+	userGroups   map[string][]string
+	postErrors   map[string]string
+	webhooks     map[string][]slack.WebhookMessage
+	teams        map[string]string
+	installs     map[string]map[string]interface{}
+	lastTS       int
+	lastResponse int
+
+	conn      *websocket.Conn
+	connMu    sync.Mutex
+	connected chan struct{}
+	acks      map[string]chan json.RawMessage
+	envelopes int
+}
+
+func New() *Server {
+	s := &Server{
+		BotUserID:     "UBOT",
+		TeamID:        "T1",
+		SigningSecret: "fake-signing-secret",
+		users:         map[string]slack.User{},
+		userGroups:    map[string][]string{},
+		postErrors:    map[string]string{},
+		webhooks:      map[string][]slack.WebhookMessage{},
+		teams:         map[string]string{},
+		installs:      map[string]map[string]interface{}{},
+		connected:     make(chan struct{}),
+		acks:          map[string]chan json.RawMessage{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/", s.handleAPI)
+	mux.HandleFunc("/response/", s.handleWebhook)
+	mux.HandleFunc("/socket", s.handleSocket)
+	s.server = httptest.NewServer(mux)
+
+	return s
+}
+
+func (s *Server) Close() {
+	s.connMu.Lock()
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	s.connMu.Unlock()
+
+	s.server.Close()
+}
+
+// APIURL is for slack.OptionAPIURL.
+func (s *Server) APIURL() string {
+	return s.server.URL + "/api/"
+}
+
+// HTTPClient sends requests for slack.com's Web API to the fake instead, for
+// calls that can't be pointed elsewhere such as oauth.v2.access.
+func (s *Server) HTTPClient() *http.Client {
+	return &http.Client{Timeout: Timeout, Transport: redirectAPI{s.server.URL}}
+}
+
+type redirectAPI struct {
+	serverURL string
+}
+
+func (t redirectAPI) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host == "slack.com" && strings.HasPrefix(req.URL.Path, "/api/") {
+		target, err := url.Parse(t.serverURL)
+		if err != nil {
+			return nil, err
+		}
+
+		req = req.Clone(req.Context())
+		req.URL.Scheme = target.Scheme
+		req.URL.Host = target.Host
+		req.Host = target.Host
+	}
+
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// ResponseURL hands out a fresh response URL, see Webhooks.
+func (s *Server) ResponseURL() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastResponse++
+	return fmt.Sprintf("%s/response/%d", s.server.URL, s.lastResponse)
+}
+
+// AddUser makes the user available to users.info.
+func (s *Server) AddUser(user slack.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user.TeamID == "" {
+		user.TeamID = s.TeamID
+	}
+	s.users[user.ID] = user
+}
+
+// AddUserGroup makes the group's members available to usergroups.users.list.
+func (s *Server) AddUserGroup(groupID string, members ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.userGroups[groupID] = members
+}
+
+// SetPostError makes posts to the channel fail with the given Slack error, or
+// succeed again when it's empty.
+func (s *Server) SetPostError(channelID, slackErr string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if slackErr == "" {
+		delete(s.postErrors, channelID)
+		return
+	}
+	s.postErrors[channelID] = slackErr
+}
+
+// AddInstall lets oauth.v2.access exchange the code for a bot token in
+// another workspace, as if someone there had approved the install. Later calls
+// with the token are made in that workspace.
+func (s *Server) AddInstall(code, teamID, teamName, botToken string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.teams[botToken] = teamID
+	s.installs[code] = map[string]interface{}{
+		"ok":           true,
+		"access_token": botToken,
+		"token_type":   "bot",
+		"bot_user_id":  s.BotUserID,
+		"team":         map[string]string{"id": teamID, "name": teamName},
+		"authed_user":  map[string]string{"id": "UINSTALLER"},
+	}
+}
+
+// Calls returns the requests made to one API method, in order.
+func (s *Server) Calls(method string) []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	calls := []Call{}
+	for _, call := range s.calls {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+
+	return calls
+}
+
+// AwaitCalls waits for at least n requests to the API method, for work the
+// bot does after acknowledging an event.
+func (s *Server) AwaitCalls(method string, n int) []Call {
+	deadline := time.Now().Add(Timeout)
+	for {
+		calls := s.Calls(method)
+		if len(calls) >= n || time.Now().After(deadline) {
+			return calls
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Webhooks returns the messages posted to a response URL, in order.
+func (s *Server) Webhooks(responseURL string) []slack.WebhookMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]slack.WebhookMessage{}, s.webhooks[responseURL]...)
+}
+
+// AwaitWebhooks waits for at least n messages to the response URL.
+func (s *Server) AwaitWebhooks(responseURL string, n int) []slack.WebhookMessage {
+	deadline := time.Now().Add(Timeout)
+	for {
+		messages := s.Webhooks(responseURL)
+		if len(messages) >= n || time.Now().After(deadline) {
+			return messages
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (s *Server) handleAPI(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	values := r.URL.Query()
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for key, value := range form {
+			values[key] = value
+		}
+	}
+
+	token := values.Get("token")
+	if token == "" {
+		token = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+
+	call := Call{Method: strings.TrimPrefix(r.URL.Path, "/api/"), Token: token, Values: values, Body: body}
+
+	s.mu.Lock()
+	s.calls = append(s.calls, call)
+	response := s.respond(call)
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// respond answers the way Slack would, enough for the bot to carry on.
+func (s *Server) respond(call Call) map[string]interface{} {
+	switch call.Method {
+	case "auth.test":
+		teamID, ok := s.teams[call.Token]
+		if !ok {
+			teamID = s.TeamID
+		}
+		return map[string]interface{}{"ok": true, "user_id": s.BotUserID, "team_id": teamID, "bot_id": "B" + s.BotUserID}
+	case "oauth.v2.access":
+		install, ok := s.installs[call.Values.Get("code")]
+		if !ok {
+			return slackError("invalid_code")
+		}
+		delete(s.installs, call.Values.Get("code"))
+		return install
+	case "apps.connections.open":
+		return map[string]interface{}{"ok": true, "url": "ws" + strings.TrimPrefix(s.server.URL, "http") + "/socket"}
+	case "users.info":
+		user, ok := s.users[call.Values.Get("user")]
+		if !ok {
+			return slackError("user_not_found")
+		}
+		return map[string]interface{}{"ok": true, "user": user}
+	case "usergroups.users.list":
+		members, ok := s.userGroups[call.Values.Get("usergroup")]
+		if !ok {
+			return slackError("no_such_subteam")
+		}
+		return map[string]interface{}{"ok": true, "users": members}
+	case "chat.postMessage", "chat.postEphemeral":
+		channelID := call.Values.Get("channel")
+		if slackErr, ok := s.postErrors[channelID]; ok {
+			return slackError(slackErr)
+		}
+		s.lastTS++
+		ts := fmt.Sprintf("%d.000100", s.lastTS)
+		return map[string]interface{}{"ok": true, "channel": channelID, "ts": ts, "message_ts": ts}
+	case "chat.getPermalink":
+		ts := strings.ReplaceAll(call.Values.Get("message_ts"), ".", "")
+		return map[string]interface{}{"ok": true, "channel": call.Values.Get("channel"), "permalink": fmt.Sprintf("https://fake.slack.com/archives/%s/p%s", call.Values.Get("channel"), ts)}
+	case "views.open", "views.publish", "views.update", "views.push":
+		s.lastTS++
+		return map[string]interface{}{"ok": true, "view": map[string]interface{}{"id": fmt.Sprintf("V%d", s.lastTS)}}
+	}
+
+	return map[string]interface{}{"ok": true}
+}
+
+func slackError(code string) map[string]interface{} {
+	return map[string]interface{}{"ok": false, "error": code}
+}
+
+func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	var msg slack.WebhookMessage
+	err := json.NewDecoder(r.Body).Decode(&msg)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	responseURL := s.server.URL + r.URL.Path
+
+	s.mu.Lock()
+	s.webhooks[responseURL] = append(s.webhooks[responseURL], msg)
+	s.mu.Unlock()
+}
+
+// upgrader accepts any origin, the bot is the only client.
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+func (s *Server) handleSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	s.connMu.Lock()
+	s.conn = conn
+	err = conn.WriteJSON(map[string]interface{}{"type": "hello", "num_connections": 1})
+	s.connMu.Unlock()
+	if err != nil {
+		return
+	}
+	close(s.connected)
+
+	for {
+		var ack struct {
+			EnvelopeID string          `json:"envelope_id"`
+			Payload    json.RawMessage `json:"payload"`
+		}
+		err := conn.ReadJSON(&ack)
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		acked, ok := s.acks[ack.EnvelopeID]
+		s.mu.Unlock()
+		if ok {
+			acked <- ack.Payload
+		}
+	}
+}
+
+// Send delivers a Socket Mode request and returns the payload the bot
+// acknowledged it with, if any.
+func (s *Server) Send(requestType string, payload interface{}) (json.RawMessage, error) {
+	select {
+	case <-s.connected:
+	case <-time.After(Timeout):
+		return nil, errors.New("the bot never connected")
+	}
+
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	acked := make(chan json.RawMessage, 1)
+	s.mu.Lock()
+	s.envelopes++
+	envelopeID := fmt.Sprintf("envelope-%d", s.envelopes)
+	s.acks[envelopeID] = acked
+	s.mu.Unlock()
+
+	s.connMu.Lock()
+	err = s.conn.WriteJSON(map[string]interface{}{
+		"type":                     requestType,
+		"envelope_id":              envelopeID,
+		"payload":                  json.RawMessage(encoded),
+		"accepts_response_payload": requestType != "events_api",
+	})
+	s.connMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case ack := <-acked:
+		return ack, nil
+	case <-time.After(Timeout):
+		return nil, fmt.Errorf("%s request %s was never acknowledged", requestType, envelopeID)
+	}
+}
+
+// SendEvent wraps the inner event, e.g. an app_mention, in an Events API
+// callback.
+func (s *Server) SendEvent(event interface{}) error {
+	_, err := s.Send("events_api", map[string]interface{}{
+		"type":    "event_callback",
+		"team_id": s.TeamID,
+		"event":   event,
+	})
+
+	return err
+}
+
+func (s *Server) SendSlashCommand(cmd slack.SlashCommand) (json.RawMessage, error) {
+	if cmd.TeamID == "" {
+		cmd.TeamID = s.TeamID
+	}
+
+	return s.Send("slash_commands", cmd)
+}
+
+func (s *Server) SendInteraction(callback slack.InteractionCallback) (json.RawMessage, error) {
+	if callback.Team.ID == "" {
+		callback.Team.ID = s.TeamID
+	}
+
+	return s.Send("interactive", callback)
+}
+
+// Post sends a request signed with SigningSecret to the bot's HTTP endpoint,
+// returning the status and response body.
+func (s *Server) Post(endpoint, contentType string, body []byte) (int, []byte, error) {
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	Sign(req.Header, s.SigningSecret, time.Now(), body)
+
+	client := http.Client{Timeout: Timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	reply, err := io.ReadAll(resp.Body)
+
+	return resp.StatusCode, reply, err
+}
+
+// Sign adds Slack's request signature headers, see
+// https://api.slack.com/authentication/verifying-requests-from-slack
+func Sign(header http.Header, secret string, t time.Time, body []byte) {
+	timestamp := strconv.FormatInt(t.Unix(), 10)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(body)
+
+	header.Set("X-Slack-Request-Timestamp", timestamp)
+	header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+}
+
+// PostEvent wraps the inner event in an Events API callback and posts it.
+func (s *Server) PostEvent(endpoint string, event interface{}) (int, []byte, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"type":    "event_callback",
+		"team_id": s.TeamID,
+		"event":   event,
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return s.Post(endpoint, "application/json", body)
+}
+
+// PostSlashCommand posts the command form encoded, as Slack does.
+func (s *Server) PostSlashCommand(endpoint string, cmd slack.SlashCommand) (int, []byte, error) {
+	if cmd.TeamID == "" {
+		cmd.TeamID = s.TeamID
+	}
+
+	form := url.Values{
+		"command":      {cmd.Command},
+		"text":         {cmd.Text},
+		"team_id":      {cmd.TeamID},
+		"channel_id":   {cmd.ChannelID},
+		"user_id":      {cmd.UserID},
+		"response_url": {cmd.ResponseURL},
+		"trigger_id":   {cmd.TriggerID},
+	}
+
+	return s.Post(endpoint, "application/x-www-form-urlencoded", []byte(form.Encode()))
+}
+
+// PostInteraction posts the callback as the form encoded payload Slack sends.
+func (s *Server) PostInteraction(endpoint string, callback slack.InteractionCallback) (int, []byte, error) {
+	if callback.Team.ID == "" {
+		callback.Team.ID = s.TeamID
+	}
+
+	payload, err := json.Marshal(callback)
+	if err != nil {
+		return 0, nil, err
+	}
+	form := url.Values{"payload": {string(payload)}}
+
+	return s.Post(endpoint, "application/x-www-form-urlencoded", []byte(form.Encode()))
+}
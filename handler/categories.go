@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"strings"
+
+	"github.com/zerodahero/trout/parser"
+)
+
+// categoryTag is how the category is written as a hashtag, "Customer First"
+// is #customerfirst.
+func categoryTag(name string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "", "_", "").Replace(name))
+}
+
+// findCategory matches a hashtag, with or without the #, to a configured
+// category, returning "" when there's none.
+func (c *Config) findCategory(tag string) string {
+	tag = categoryTag(strings.TrimPrefix(tag, "#"))
+	for _, category := range c.Categories {
+		if categoryTag(category) == tag {
+			return category
+		}
+	}
+
+	return ""
+}
+
+// categoriesFromText is every configured category tagged in the text, other
+// hashtags are left alone.
+func (c *Config) categoriesFromText(text string) []string {
+	seen := map[string]bool{}
+	found := []string{}
+	for _, tag := range parser.ParseHashtagsFromText(text) {
+		category := c.findCategory(tag)
+		if category == "" || seen[category] {
+			continue
+		}
+		seen[category] = true
+		found = append(found, category)
+	}
+
+	return found
+}
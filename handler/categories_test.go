@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/zerodahero/trout/database"
+
+	"github.com/slack-go/slack"
+)
+
+var testCategories = []string{"Customer First", "Ownership"}
+
+func TestCategoriesFromText(t *testing.T) {
+	config := &Config{Categories: testCategories}
+
+	var tests = []struct {
+		text string
+		want []string
+	}{
+		{"thanks for the help", []string{}},
+		{"thanks #ownership", []string{"Ownership"}},
+		{"#customerfirst and #Customer-First #OWNERSHIP", []string{"Customer First", "Ownership"}},
+		{"thanks #notavalue", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got := config.categoriesFromText(tt.text)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTaggedKudoRelease(t *testing.T) {
+	fake, workspaces := setupTestSlack(t)
+	ws := testWorkspace(t, workspaces)
+	ws.Config.Categories = testCategories
+	fake.AddUser(slack.User{ID: "UGIVER", Profile: slack.UserProfile{DisplayName: "giver"}})
+	fake.AddUser(slack.User{ID: "U2", Profile: slack.UserProfile{DisplayName: "nemo"}})
+
+	kudo := database.NewKudo("T1", "UGIVER", "U2", "<@U2> shipped it on a Friday #ownership")
+	err := ws.saveKudoWithUser(kudo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := ws.Store.GetKudoByID("T1", int(kudo.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(stored.CategoryNames(), ","); got != "Ownership" {
+		t.Fatalf("got categories %q, want Ownership", got)
+	}
+
+	text := formatReleasedKudo(ws, stored, true)
+	if !strings.Contains(text, ":label: Ownership") {
+		t.Errorf("expected a category label in %q", text)
+	}
+}
+
+func TestStatsCategoryFilter(t *testing.T) {
+	var tests = []struct {
+		name string
+		text string
+		want string
+	}{
+		{"category", "stats all #ownership", "Trout stats for all time in Ownership"},
+		{"unknown category", "stats #curiosity", "#curiosity isn't one of the categories"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, workspaces := setupTestSlack(t)
+			ws := testWorkspace(t, workspaces)
+			ws.Config.Categories = testCategories
+
+			payload, err := HandleTroutCommand(ws, slack.SlashCommand{UserID: "UGIVER", ChannelID: "C1", Text: tt.text}, prepareTestKudo)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			encoded, _ := json.Marshal(payload)
+			if !strings.Contains(string(encoded), tt.want) {
+				t.Errorf("got %s, want it to contain %q", encoded, tt.want)
+			}
+		})
+	}
+}
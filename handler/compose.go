@@ -0,0 +1,315 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/zerodahero/trout/database"
+	"github.com/zerodahero/trout/parser"
+
+	"github.com/slack-go/slack"
+)
+
+const (
+	kudoComposeCallbackID = "kudo-compose"
+	composeRecipientsID   = "recipients"
+	composeMessageID      = "message"
+	composeOptionsID      = "options"
+	composeCategoryID     = "category"
+	composePondID         = "pond"
+)
+
+// kudoComposePrefill seeds the composer, e.g. from an existing message.
+type kudoComposePrefill struct {
+	RecipientIDs []string
+	Message      string
+	Permalink    string
+	ChannelID    string
+}
+
+// kudoComposeMetadata travels with the composer through to the submission.
+type kudoComposeMetadata struct {
+	Permalink string `json:"permalink,omitempty"`
+	ChannelID string `json:"channel_id,omitempty"`
+}
+
+// BuildKudoComposeModal lays out the shout out composer.
+func BuildKudoComposeModal(config *Config, prefill kudoComposePrefill) (slack.ModalViewRequest, error) {
+	encoded, err := json.Marshal(kudoComposeMetadata{Permalink: prefill.Permalink, ChannelID: prefill.ChannelID})
+	if err != nil {
+		return slack.ModalViewRequest{}, err
+	}
+
+	recipients := slack.NewOptionsMultiSelectBlockElement(
+		slack.MultiOptTypeUser,
+		&slack.TextBlockObject{
+			Type: slack.PlainTextType,
+			Text: "Who's it for?",
+		},
+		composeRecipientsID,
+	)
+	recipients.InitialUsers = prefill.RecipientIDs
+
+	message := slack.NewPlainTextInputBlockElement(
+		&slack.TextBlockObject{
+			Type: slack.PlainTextType,
+			Text: "What did they do that was so great?",
+		},
+		composeMessageID,
+	)
+	message.Multiline = true
+	message.InitialValue = truncateText(prefill.Message, maxKudoMessageInput)
+	message.MaxLength = maxKudoMessageInput
+
+	options := slack.NewCheckboxGroupsBlockElement(
+		composeOptionsID,
+		slack.NewOptionBlockObject("private", &slack.TextBlockObject{Type: slack.PlainTextType, Text: "Keep it private"}, &slack.TextBlockObject{Type: slack.PlainTextType, Text: "Sent by DM instead of posted in the channel"}),
+		slack.NewOptionBlockObject("anonymous", &slack.TextBlockObject{Type: slack.PlainTextType, Text: "Post anonymously"}, nil),
+	)
+	optionsBlock := slack.NewInputBlock(
+		composeOptionsID,
+		&slack.TextBlockObject{
+			Type: slack.PlainTextType,
+			Text: "Options",
+		},
+		options,
+	)
+	optionsBlock.Optional = true
+
+	blocks := []slack.Block{
+		slack.NewInputBlock(
+			composeRecipientsID,
+			&slack.TextBlockObject{
+				Type: slack.PlainTextType,
+				Text: "Shout out to",
+			},
+			recipients,
+		),
+		slack.NewInputBlock(
+			composeMessageID,
+			&slack.TextBlockObject{
+				Type: slack.PlainTextType,
+				Text: "Shout out",
+			},
+			message,
+		),
+		optionsBlock,
+	}
+
+	if prefill.Permalink != "" {
+		blocks = append(blocks, slack.NewContextBlock(
+			"",
+			&slack.TextBlockObject{
+				Type: slack.MarkdownType,
+				Text: "The release will link back to <" + prefill.Permalink + "|the original message>.",
+			},
+		))
+	}
+
+	if len(config.Categories) > 0 {
+		categoryOptions := make([]*slack.OptionBlockObject, 0, len(config.Categories))
+		for _, category := range config.Categories {
+			categoryOptions = append(categoryOptions, slack.NewOptionBlockObject(category, &slack.TextBlockObject{Type: slack.PlainTextType, Text: category}, nil))
+		}
+
+		categoryBlock := slack.NewInputBlock(
+			composeCategoryID,
+			&slack.TextBlockObject{
+				Type: slack.PlainTextType,
+				Text: "Categories",
+			},
+			slack.NewOptionsMultiSelectBlockElement(slack.MultiOptTypeStatic, nil, composeCategoryID, categoryOptions...),
+		)
+		categoryBlock.Hint = &slack.TextBlockObject{
+			Type: slack.PlainTextType,
+			Text: "You can also tag the message, e.g. #" + categoryTag(config.Categories[0]),
+		}
+		categoryBlock.Optional = true
+		blocks = append(blocks, categoryBlock)
+	}
+
+	if len(config.Ponds) > 0 {
+		pondOptions := make([]*slack.OptionBlockObject, 0, len(config.Ponds))
+		for _, pond := range config.Ponds {
+			pond = strings.ToLower(pond)
+			pondOptions = append(pondOptions, slack.NewOptionBlockObject(pond, &slack.TextBlockObject{Type: slack.PlainTextType, Text: pond}, nil))
+		}
+
+		pondBlock := slack.NewInputBlock(
+			composePondID,
+			&slack.TextBlockObject{
+				Type: slack.PlainTextType,
+				Text: "Pond",
+			},
+			slack.NewOptionsSelectBlockElement(slack.OptTypeStatic, nil, composePondID, pondOptions...),
+		)
+		pondBlock.Hint = &slack.TextBlockObject{Type: slack.PlainTextType, Text: "Which team's release it goes out with"}
+		pondBlock.Optional = true
+		blocks = append(blocks, pondBlock)
+	}
+
+	return slack.ModalViewRequest{
+		Type:            slack.VTModal,
+		CallbackID:      kudoComposeCallbackID,
+		PrivateMetadata: string(encoded),
+		Title: &slack.TextBlockObject{
+			Type: slack.PlainTextType,
+			Text: "Shout out",
+		},
+		Submit: &slack.TextBlockObject{
+			Type: slack.PlainTextType,
+			Text: "Send",
+		},
+		Close: &slack.TextBlockObject{
+			Type: slack.PlainTextType,
+			Text: "Cancel",
+		},
+		Blocks: slack.Blocks{BlockSet: blocks},
+	}, nil
+}
+
+func openKudoComposeModal(ws *Workspace, triggerID string, prefill kudoComposePrefill) error {
+	modal, err := BuildKudoComposeModal(ws.Config, prefill)
+	if err != nil {
+		return err
+	}
+
+	_, err = ws.API.OpenView(triggerID, modal)
+	return err
+}
+
+// HandleComposeShortcut opens the composer from the global shortcut.
+func HandleComposeShortcut(ws *Workspace, callback slack.InteractionCallback) error {
+	return openKudoComposeModal(ws, callback.TriggerID, kudoComposePrefill{})
+}
+
+// HandleMessageShortcut turns the selected message into a shout out, aimed at
+// whoever the message mentions or, failing that, its author.
+func HandleMessageShortcut(ws *Workspace, callback slack.InteractionCallback) error {
+	permalink, err := ws.API.GetPermalink(&slack.PermalinkParameters{
+		Channel: callback.Channel.ID,
+		Ts:      callback.Message.Timestamp,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get message permalink: %v", err)
+	}
+
+	// Swap mentions for names, the modal input can't render them
+	message, err := ws.plainTextMessage(callback.Message.Text)
+	if err != nil {
+		return err
+	}
+
+	mentioned, _ := parser.ParseRecipientsFromText(callback.Message.Text)
+	recipientIDs := []string{}
+	for _, userID := range mentioned {
+		if userID != callback.User.ID {
+			recipientIDs = append(recipientIDs, userID)
+		}
+	}
+	if len(recipientIDs) == 0 && callback.Message.User != "" && callback.Message.User != callback.User.ID {
+		recipientIDs = append(recipientIDs, callback.Message.User)
+	}
+
+	return openKudoComposeModal(ws, callback.TriggerID, kudoComposePrefill{
+		RecipientIDs: recipientIDs,
+		Message:      message,
+		Permalink:    permalink,
+		ChannelID:    callback.Channel.ID,
+	})
+}
+
+// HandleKudoComposeSubmission saves a kudo per selected recipient, with the
+// kudos prepared the same way as the slash command.
+func HandleKudoComposeSubmission(ws *Workspace, callback slack.InteractionCallback, prepare func(*Workspace, *database.Kudo) error) (interface{}, error) {
+	var metadata kudoComposeMetadata
+	err := json.Unmarshal([]byte(callback.View.PrivateMetadata), &metadata)
+	if err != nil {
+		return nil, fmt.Errorf("invalid compose metadata: %v", err)
+	}
+
+	values := callback.View.State.Values
+
+	pond, message := parser.ParsePondFromText(strings.TrimSpace(values[composeMessageID][composeMessageID].Value))
+	if selected := values[composePondID][composePondID].SelectedOption.Value; selected != "" {
+		pond = selected
+	}
+	if message == "" {
+		return slack.NewErrorsViewSubmissionResponse(map[string]string{
+			composeMessageID: "A shout out needs a message.",
+		}), nil
+	}
+
+	isPublic, isAnonymous := true, false
+	for _, option := range values[composeOptionsID][composeOptionsID].SelectedOptions {
+		switch option.Value {
+		case "private":
+			isPublic = false
+		case "anonymous":
+			isAnonymous = true
+		}
+	}
+
+	kudos := []*database.Kudo{}
+	for _, recipientID := range values[composeRecipientsID][composeRecipientsID].SelectedUsers {
+		kudo := database.NewKudo(ws.TeamID, callback.User.ID, recipientID, message)
+		kudo.IsPublic = isPublic
+		kudo.IsAnonymous = isAnonymous
+		for _, option := range values[composeCategoryID][composeCategoryID].SelectedOptions {
+			kudo.AddCategory(option.Value)
+		}
+		kudo.SourcePermalink = metadata.Permalink
+		kudo.ChannelID = metadata.ChannelID
+		kudo.Pond = pond
+		kudos = append(kudos, kudo)
+	}
+
+	kudos = withoutSelfShoutOuts(kudos)
+	if len(kudos) == 0 {
+		return slack.NewErrorsViewSubmissionResponse(map[string]string{
+			composeRecipientsID: "Glad to hear you're doing some great work, but I don't do self shout-outs.",
+		}), nil
+	}
+
+	denied, err := saveWithinQuota(ws, callback.User.ID, kudos, prepare)
+	if err != nil {
+		return nil, err
+	}
+	if denied != "" {
+		return slack.NewErrorsViewSubmissionResponse(map[string]string{
+			composeRecipientsID: denied,
+		}), nil
+	}
+
+	recipients := make([]string, 0, len(kudos))
+	for _, kudo := range kudos {
+		recipients = append(recipients, parser.WrapUserIdForMention(kudo.ToUserID))
+	}
+
+	return slack.NewUpdateViewSubmissionResponse(buildKudoComposeDoneModal(recipients)), nil
+}
+
+func buildKudoComposeDoneModal(recipients []string) *slack.ModalViewRequest {
+	return &slack.ModalViewRequest{
+		Type: slack.VTModal,
+		Title: &slack.TextBlockObject{
+			Type: slack.PlainTextType,
+			Text: "Shout out",
+		},
+		Close: &slack.TextBlockObject{
+			Type: slack.PlainTextType,
+			Text: "Done",
+		},
+		Blocks: slack.Blocks{BlockSet: []slack.Block{
+			slack.NewSectionBlock(
+				&slack.TextBlockObject{
+					Type: slack.MarkdownType,
+					Text: "Got it! You're awesome, thanks! Your shout out to " + strings.Join(recipients, ", ") + " will be released with the next batch.",
+				},
+				nil,
+				nil,
+			),
+		}},
+	}
+}
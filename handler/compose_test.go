@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/slack-go/slack"
+)
+
+func TestKudoComposeModalPrefill(t *testing.T) {
+	var tests = []struct {
+		name    string
+		message string
+		want    string
+	}{
+		{"short", "thanks 🐟", "thanks 🐟"},
+		{"at the limit", strings.Repeat("🐟", maxKudoMessageInput), strings.Repeat("🐟", maxKudoMessageInput)},
+		{"too long", strings.Repeat("🐟", maxKudoMessageInput+1), strings.Repeat("🐟", maxKudoMessageInput-1) + "…"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modal, err := BuildKudoComposeModal(DefaultConfig(), kudoComposePrefill{Message: tt.message})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var input *slack.PlainTextInputBlockElement
+			for _, block := range modal.Blocks.BlockSet {
+				if block, ok := block.(*slack.InputBlock); ok && block.BlockID == composeMessageID {
+					input, _ = block.Element.(*slack.PlainTextInputBlockElement)
+				}
+			}
+			if input == nil {
+				t.Fatal("expected a message input")
+			}
+
+			if input.InitialValue != tt.want {
+				t.Errorf("got %d characters, want %d", utf8.RuneCountInString(input.InitialValue), utf8.RuneCountInString(tt.want))
+			}
+			if !utf8.ValidString(input.InitialValue) || utf8.RuneCountInString(input.InitialValue) > input.MaxLength {
+				t.Errorf("expected at most %d whole characters", input.MaxLength)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"time"
+)
+
+// Config is how trout is set up, read from the environment at startup and
+// shared by every workspace. The zero value turns every optional feature off.
+type Config struct {
+	// ReleasePassword is an optional extra prompt on top of release
+	// permissions.
+	ReleasePassword string
+
+	// Categories, e.g. company values, can be picked in the composer or
+	// tagged in the message with a hashtag.
+	Categories []string
+
+	// Ponds are offered in the composer when set, shout outs can be aimed at
+	// any pond with "pond:name" either way.
+	Ponds []string
+
+	// Reactions are the emoji that count as a shout out to whoever wrote the
+	// message, with or without colons.
+	Reactions []string
+
+	// GiverQuotas cap each person's shout outs, PairQuotas what one person
+	// gives any one other person. Neither is enforced when empty.
+	GiverQuotas []Quota
+	PairQuotas  []Quota
+
+	// PointsPerKudo is what a shout out carries when the giver doesn't
+	// choose, PointsAllowance how many points each person can give out a
+	// month. With an allowance, givers pick the points with a "+5" in the
+	// message. Points are off while both are 0.
+	PointsPerKudo   int
+	PointsAllowance int
+
+	// RewardsChannelID is where redemptions wait for an admin to approve
+	// them. Rewards are off without it.
+	RewardsChannelID string
+
+	// UserTTL is how long a stored name is trusted before it's fetched again,
+	// in case the user_change event was missed. Zero keeps names until the
+	// next user_change event.
+	UserTTL time.Duration
+}
+
+// DefaultConfig is what trout runs with when nothing is set.
+func DefaultConfig() *Config {
+	return &Config{
+		Reactions: []string{"fish"},
+		UserTTL:   24 * time.Hour,
+	}
+}
+
+func (c *Config) pointsEnabled() bool {
+	return c.PointsPerKudo > 0 || c.PointsAllowance > 0
+}
+
+func (c *Config) rewardsEnabled() bool {
+	return c.RewardsChannelID != "" && c.pointsEnabled()
+}
@@ -0,0 +1,205 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/zerodahero/trout/database"
+	"github.com/zerodahero/trout/parser"
+
+	"github.com/slack-go/slack"
+)
+
+const (
+	kudoEditCallbackID  = "kudo-edit"
+	kudoEditBlockID     = "message"
+	kudoEditActionID    = "message"
+	maxKudoMessageInput = 3000
+
+	kudoPointsFixed = "Points stay as they were given. To change them, delete the shout out and give it again."
+)
+
+// kudoEditMetadata travels with the edit modal so the submission knows which
+// kudos to change and where to show the result.
+type kudoEditMetadata struct {
+	KudoIDs     []int  `json:"kudo_ids"`
+	ResponseURL string `json:"response_url,omitempty"`
+	HomePage    int    `json:"home_page,omitempty"`
+}
+
+// BuildKudoEditModal pre-fills the message of the shout out being edited. Only
+// the message can change, so one with points says they're kept.
+func BuildKudoEditModal(kudos []*database.Kudo, metadata kudoEditMetadata) (slack.ModalViewRequest, error) {
+	encoded, err := json.Marshal(metadata)
+	if err != nil {
+		return slack.ModalViewRequest{}, err
+	}
+
+	input := slack.NewPlainTextInputBlockElement(nil, kudoEditActionID)
+	input.Multiline = true
+	input.InitialValue = truncateText(kudos[0].Message, maxKudoMessageInput)
+	input.MaxLength = maxKudoMessageInput
+
+	block := slack.NewInputBlock(
+		kudoEditBlockID,
+		&slack.TextBlockObject{
+			Type: slack.PlainTextType,
+			Text: "Shout out",
+		},
+		input,
+	)
+	if kudos[0].Points > 0 {
+		block.Hint = slack.NewTextBlockObject(slack.PlainTextType, kudoPointsFixed, false, false)
+	}
+
+	return slack.ModalViewRequest{
+		Type:            slack.VTModal,
+		CallbackID:      kudoEditCallbackID,
+		PrivateMetadata: string(encoded),
+		Title: &slack.TextBlockObject{
+			Type: slack.PlainTextType,
+			Text: "Edit shout out",
+		},
+		Submit: &slack.TextBlockObject{
+			Type: slack.PlainTextType,
+			Text: "Save",
+		},
+		Close: &slack.TextBlockObject{
+			Type: slack.PlainTextType,
+			Text: "Cancel",
+		},
+		Blocks: slack.Blocks{BlockSet: []slack.Block{block}},
+	}, nil
+}
+
+func openKudoEditModal(ws *Workspace, triggerID string, kudos []*database.Kudo, metadata kudoEditMetadata) error {
+	modal, err := BuildKudoEditModal(kudos, metadata)
+	if err != nil {
+		return err
+	}
+
+	_, err = ws.API.OpenView(triggerID, modal)
+	return err
+}
+
+// HandleKudoEditSubmission saves the edited message. Problems are returned as
+// a view submission response so they show up in the modal.
+func HandleKudoEditSubmission(ws *Workspace, callback slack.InteractionCallback) (interface{}, error) {
+	var metadata kudoEditMetadata
+	err := json.Unmarshal([]byte(callback.View.PrivateMetadata), &metadata)
+	if err != nil {
+		return nil, fmt.Errorf("invalid edit metadata: %v", err)
+	}
+
+	message := strings.TrimSpace(callback.View.State.Values[kudoEditBlockID][kudoEditActionID].Value)
+	if message == "" {
+		return slack.NewErrorsViewSubmissionResponse(map[string]string{
+			kudoEditBlockID: "A shout out needs a message.",
+		}), nil
+	}
+
+	kudos := make([]*database.Kudo, 0, len(metadata.KudoIDs))
+	for _, kudoID := range metadata.KudoIDs {
+		kudo, err := ws.Store.GetKudoByID(ws.TeamID, kudoID)
+		if err != nil {
+			return nil, fmt.Errorf("could not find shout out: %v", err)
+		}
+
+		err = canChangeKudo(kudo, callback.User.ID)
+		if err != nil {
+			return slack.NewErrorsViewSubmissionResponse(map[string]string{
+				kudoEditBlockID: "Sorry, " + err.Error() + ".",
+			}), nil
+		}
+
+		kudos = append(kudos, kudo)
+	}
+
+	for _, kudo := range kudos {
+		err = editKudoMessage(ws, kudo, message)
+		if err != nil {
+			return nil, err
+		}
+
+		err = ws.Store.SaveKudo(kudo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to store kudo: %v", err)
+		}
+	}
+
+	if metadata.ResponseURL != "" {
+		reply := "Shout out updated!"
+		if kudos[0].Points > 0 {
+			reply += " " + kudoPointsFixed
+		}
+		return nil, replaceOriginal(ws, metadata.ResponseURL, BuildCommandPayloadBlocks(kudos, reply))
+	}
+
+	return nil, publishHome(ws, callback.User.ID, metadata.HomePage)
+}
+
+// editKudoMessage swaps in the new message the way saveKudoWithUser stores
+// one. The modal only edits the plain text, so the mentions kept are those
+// whose names are still in it, and a category tagged with a hashtag that's
+// been edited out goes with it. Points are fixed when the kudo is given, so
+// a +N in the new message is just text.
+func editKudoMessage(ws *Workspace, kudo *database.Kudo, message string) error {
+	name, err := ws.entityNames(kudo.Entities)
+	if err != nil {
+		return err
+	}
+
+	entities := database.Entities{}
+	for _, entity := range kudo.Entities {
+		if strings.Contains(message, entity.PlainText(name)) {
+			entities = append(entities, entity)
+		}
+	}
+	entities = append(entities, parser.ParseEntities(message)...)
+
+	untagged := map[string]bool{}
+	for _, category := range ws.Config.categoriesFromText(kudo.Message) {
+		untagged[strings.ToLower(category)] = true
+	}
+	for _, category := range ws.Config.categoriesFromText(message) {
+		delete(untagged, strings.ToLower(category))
+	}
+	categories := []*database.KudoCategory{}
+	for _, category := range kudo.Categories {
+		if !untagged[strings.ToLower(category.Name)] {
+			categories = append(categories, category)
+		}
+	}
+	kudo.Categories = categories
+	for _, category := range ws.Config.categoriesFromText(message) {
+		kudo.AddCategory(category)
+	}
+
+	kudo.Message, err = ws.plainTextMessage(message)
+	if err != nil {
+		return err
+	}
+	kudo.Entities = entities
+
+	return nil
+}
+
+// deleteKudos soft deletes the kudos after checking they can still be changed.
+func deleteKudos(ws *Workspace, kudos []*database.Kudo, userID string) error {
+	for _, kudo := range kudos {
+		err := canChangeKudo(kudo, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, kudo := range kudos {
+		err := ws.Store.DeleteKudo(kudo)
+		if err != nil {
+			return fmt.Errorf("failed to delete kudo: %v", err)
+		}
+	}
+
+	return nil
+}
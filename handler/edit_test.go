@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/zerodahero/trout/database"
+
+	"github.com/slack-go/slack"
+)
+
+func TestKudoEditSubmission(t *testing.T) {
+	var tests = []struct {
+		name           string
+		message        string
+		wantMessage    string
+		wantEntities   []string
+		wantCategories string
+	}{
+		{"keeps the mention", "nemo shipped it on a Monday #ownership", "nemo shipped it on a Monday #ownership", []string{"U2"}, "Customer First,Ownership"},
+		{"drops what was edited out", "shipped it on a Monday #customerfirst", "shipped it on a Monday #customerfirst", nil, "Customer First"},
+		{"picks up a new mention", "nemo and <@UGIVER> shipped it", "nemo and giver shipped it", []string{"U2", "UGIVER"}, "Customer First"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, workspaces := setupTestSlack(t)
+			ws := testWorkspace(t, workspaces)
+			ws.Config.Categories = testCategories
+			fake.AddUser(slack.User{ID: "UGIVER", Profile: slack.UserProfile{DisplayName: "giver"}})
+			fake.AddUser(slack.User{ID: "U2", Profile: slack.UserProfile{DisplayName: "nemo"}})
+
+			// Customer First was picked in the composer, Ownership tagged
+			kudo := database.NewKudo("T1", "UGIVER", "U2", "<@U2> shipped it on a Friday #ownership")
+			kudo.AddCategory("Customer First")
+			err := ws.saveKudoWithUser(kudo)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = HandleKudoEditSubmission(ws, slack.InteractionCallback{
+				User: slack.User{ID: "UGIVER"},
+				View: slack.View{
+					PrivateMetadata: fmt.Sprintf(`{"kudo_ids":[%d]}`, kudo.ID),
+					State: &slack.ViewState{Values: map[string]map[string]slack.BlockAction{
+						kudoEditBlockID: {kudoEditActionID: {Value: tt.message}},
+					}},
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			stored, err := ws.Store.GetKudoByID("T1", int(kudo.ID))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Message != tt.wantMessage {
+				t.Errorf("got message %q, want %q", stored.Message, tt.wantMessage)
+			}
+			var entities []string
+			for _, entity := range stored.Entities {
+				entities = append(entities, entity.ID)
+			}
+			if strings.Join(entities, ",") != strings.Join(tt.wantEntities, ",") {
+				t.Errorf("got entities %v, want %v", entities, tt.wantEntities)
+			}
+			if got := strings.Join(stored.CategoryNames(), ","); got != tt.wantCategories {
+				t.Errorf("got categories %q, want %q", got, tt.wantCategories)
+			}
+		})
+	}
+}
+
+func TestKudoEditKeepsPoints(t *testing.T) {
+	fake, workspaces := setupTestSlack(t)
+	ws := testWorkspace(t, workspaces)
+	ws.Config.PointsPerKudo, ws.Config.PointsAllowance = 0, 10
+
+	kudos, err := database.NewKudosFromText("<@U2> thanks +3", "T1", "UGIVER")
+	if err != nil {
+		t.Fatal(err)
+	}
+	denied, err := saveWithinQuota(ws, "UGIVER", kudos, prepareTestKudo)
+	if err != nil || denied != "" {
+		t.Fatalf("expected the shout out saved, got %q (%v)", denied, err)
+	}
+
+	responseURL := fake.ResponseURL()
+	_, err = HandleKudoEditSubmission(ws, slack.InteractionCallback{
+		User: slack.User{ID: "UGIVER"},
+		View: slack.View{
+			PrivateMetadata: fmt.Sprintf(`{"kudo_ids":[%d],"response_url":%q}`, kudos[0].ID, responseURL),
+			State: &slack.ViewState{Values: map[string]map[string]slack.BlockAction{
+				kudoEditBlockID: {kudoEditActionID: {Value: "thanks +5"}},
+			}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := ws.Store.GetKudoByID("T1", int(kudos[0].ID))
+	if err != nil || stored.Points != 3 {
+		t.Errorf("expected the points kept at 3, got %v (%v)", stored, err)
+	}
+	left, err := remainingPoints(ws, "UGIVER")
+	if err != nil || left != "You have 7 of your 10 monthly points left to give." {
+		t.Errorf("got %q (%v)", left, err)
+	}
+	reply, _ := json.Marshal(lastWebhook(fake, responseURL))
+	if !strings.Contains(string(reply), kudoPointsFixed) {
+		t.Errorf("expected the reply to say the points are kept, got %s", reply)
+	}
+}
@@ -3,26 +3,37 @@ package handler
 import (
 	"fmt"
 
+	"github.com/zerodahero/trout/database"
+	"github.com/zerodahero/trout/parser"
+
 	"github.com/slack-go/slack"
 )
 
-func notifyMissingToUser(channelID, userID string) error {
-	return notifyUser(channelID, userID, "Hmmm, who's this about? Please try again and tag the single user you want to shout out.")
+func notifyMissingToUser(ws *Workspace, channelID, userID string) error {
+	return notifyUser(ws, channelID, userID, "Hmmm, who's this about? Please try again and tag the user(s) you want to shout out.")
 }
 
-func notifySelfShoutOutNotAllowed(channelID, userID string) error {
-	return notifyUser(channelID, userID, "Glad to hear you're doing some great work, but I don't do self shout-outs.")
+func notifySelfShoutOutNotAllowed(ws *Workspace, channelID, userID string) error {
+	return notifyUser(ws, channelID, userID, "Glad to hear you're doing some great work, but I don't do self shout-outs.")
 }
 
-func notifyMultipleUserNotSupported(channelID, userID string) error {
-	return notifyUser(channelID, userID, "Sorry, shouting out multiple users at once is not supported (yet)? Please try again and tag each user in individual messages.")
-}
+// notifyKudoReceived adds what's left of the giver's allowance, if they have
+// one.
+func notifyKudoReceived(ws *Workspace, channelID, userID, allowance string) error {
+	message := "Got it! You're awesome, thanks!"
+	if allowance != "" {
+		message += " " + allowance
+	}
 
-func notifyKudoReceived(channelID, userID string) error {
-	return notifyUser(channelID, userID, "Got it! You're awesome, thanks!")
+	return notifyUser(ws, channelID, userID, message)
 }
 
-func notifyReleaseKudoCount(channelID, userID string, public bool, count int) error {
+func notifyReleaseKudoCount(ws *Workspace, channelID, userID string, public bool, count int, scope database.ReleaseScope, dryRun bool) error {
+	// Scheduled releases have nobody to tell
+	if userID == "" {
+		return nil
+	}
+
 	var visibility string
 	if public {
 		visibility = "public"
@@ -30,12 +41,84 @@ func notifyReleaseKudoCount(channelID, userID string, public bool, count int) er
 		visibility = "private"
 	}
 
-	message := fmt.Sprintf("Releasing %d %s shout outs!", count, visibility)
+	message := fmt.Sprintf("Releasing %d %s shout outs%s!", count, visibility, describeReleaseScope(scope))
+	if dryRun {
+		message = fmt.Sprintf("Dry run: pretending to release %d %s shout outs%s.", count, visibility, describeReleaseScope(scope))
+	}
+
+	return notifyUser(ws, channelID, userID, message)
+}
+
+// maxReportLength keeps release reports comfortably inside Slack's message
+// size limit.
+const maxReportLength = 3500
+
+func notifyDryRun(ws *Workspace, channelID, userID string, transcript []string) error {
+	if len(transcript) == 0 {
+		return notifyUser(ws, channelID, userID, "Dry run complete, nothing would have been posted.")
+	}
 
-	return notifyUser(channelID, userID, message)
+	report := "Dry run complete, nothing was posted or marked as shared. Here's what would have gone out:\n"
+	for i, line := range transcript {
+		if len(report)+len(line) > maxReportLength {
+			report += fmt.Sprintf("…and %d more", len(transcript)-i)
+			break
+		}
+		report += line + "\n"
+	}
+
+	return notifyUser(ws, channelID, userID, report)
 }
 
-func notifyUser(channelID, userID, message string) error {
-	_, err := api.PostEphemeral(channelID, userID, slack.MsgOptionText(message, false))
+func notifyReleaseFailures(ws *Workspace, channelID, userID string, failed []*database.ReleaseDelivery) error {
+	report := fmt.Sprintf("Heads up, %d shout outs couldn't be delivered:\n", len(failed))
+	for i, delivery := range failed {
+		line := fmt.Sprintf("• To %s: %s\n", parser.WrapUserIdForMention(delivery.Kudo.ToUserID), delivery.Error.String)
+		if len(report)+len(line) > maxReportLength {
+			report += fmt.Sprintf("…and %d more\n", len(failed)-i)
+			break
+		}
+		report += line
+	}
+	report += "Run `/shout-trout retry` to give them another go."
+
+	return notifyUser(ws, channelID, userID, report)
+}
+
+func notifyUser(ws *Workspace, channelID, userID, message string) error {
+	_, err := ws.API.PostEphemeral(channelID, userID, slack.MsgOptionText(message, false))
 	return err
 }
+
+// notifyDirect messages the user from the app, for when there's nowhere to
+// reply, e.g. the home tab.
+func notifyDirect(ws *Workspace, userID, message string) error {
+	_, _, err := ws.API.PostMessage(userID, slack.MsgOptionText(message, false))
+	return err
+}
+
+func replaceOriginal(ws *Workspace, responseURL string, blocks []slack.Block) error {
+	return ws.API.PostWebhook(responseURL, &slack.WebhookMessage{Blocks: &slack.Blocks{BlockSet: blocks}, ReplaceOriginal: true})
+}
+
+func replaceOriginalWithText(ws *Workspace, responseURL, message string) error {
+	return replaceOriginal(ws, responseURL, []slack.Block{
+		slack.NewSectionBlock(
+			&slack.TextBlockObject{
+				Type: slack.MarkdownType,
+				Text: message,
+			},
+			nil,
+			nil,
+		),
+	})
+}
+
+// notifyResponseURL posts an ephemeral note without touching the original
+// message. It's best effort, the caller is already handling an error.
+func notifyResponseURL(ws *Workspace, responseURL, message string) {
+	err := ws.API.PostWebhook(responseURL, &slack.WebhookMessage{Text: message, ResponseType: slack.ResponseTypeEphemeral})
+	if err != nil {
+		fmt.Printf("failed posting to response URL: %v", err)
+	}
+}
@@ -0,0 +1,259 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/zerodahero/trout/database"
+	"github.com/zerodahero/trout/parser"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+const homePageSize = 10
+
+// homePendingLimit keeps the home tab under Slack's 100 block limit.
+const homePendingLimit = 20
+
+func HandleAppHomeOpened(ws *Workspace, ev *slackevents.AppHomeOpenedEvent) {
+	if ev.Tab != "home" {
+		return
+	}
+
+	err := publishHome(ws, ev.User, 0)
+	if err != nil {
+		fmt.Printf("failed publishing app home: %v", err)
+	}
+}
+
+// HandleHomePageInteraction pages through the received shout outs.
+func HandleHomePageInteraction(ws *Workspace, a *slack.BlockAction, callback slack.InteractionCallback) error {
+	page, err := strconv.Atoi(a.Value)
+	if err != nil {
+		return fmt.Errorf("invalid home page %q: %v", a.Value, err)
+	}
+
+	return publishHome(ws, callback.User.ID, page)
+}
+
+// HandleHomeKudoInteraction changes or deletes one of the user's own pending
+// shout outs from the home tab.
+func HandleHomeKudoInteraction(ws *Workspace, a *slack.BlockAction, callback slack.InteractionCallback, kudoID int) error {
+	kudo, err := ws.Store.GetKudoByID(ws.TeamID, kudoID)
+	if err != nil {
+		return fmt.Errorf("could not find shout out: %v", err)
+	}
+
+	err = canChangeKudo(kudo, callback.User.ID)
+	if err != nil {
+		return err
+	}
+
+	// Stay on whichever page the user was looking at
+	page, _ := strconv.Atoi(callback.View.PrivateMetadata)
+
+	switch a.Value {
+	case "edit":
+		return openKudoEditModal(ws, callback.TriggerID, []*database.Kudo{kudo}, kudoEditMetadata{
+			KudoIDs:  []int{kudoID},
+			HomePage: page,
+		})
+	case "delete":
+		err = deleteKudos(ws, []*database.Kudo{kudo}, callback.User.ID)
+	default:
+		err = applyKudoToggle(kudo, a.Value)
+		if err == nil {
+			err = ws.Store.SaveKudo(kudo)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return publishHome(ws, callback.User.ID, page)
+}
+
+func publishHome(ws *Workspace, userID string, page int) error {
+	blocks, err := BuildHomeBlocks(ws, userID, page)
+	if err != nil {
+		return err
+	}
+
+	view := slack.HomeTabViewRequest{
+		Type:            slack.VTHomeTab,
+		Blocks:          slack.Blocks{BlockSet: blocks},
+		PrivateMetadata: strconv.Itoa(page),
+	}
+	_, err = ws.API.PublishView(userID, view, "")
+
+	return err
+}
+
+// BuildHomeBlocks lists a page of the user's received shout outs, followed by
+// the ones they sent that are still waiting to be released.
+func BuildHomeBlocks(ws *Workspace, userID string, page int) ([]slack.Block, error) {
+	total, err := ws.Store.CountReceived(ws.TeamID, userID, database.StatsFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	received, err := ws.Store.GetReceivedKudos(ws.TeamID, userID, page*homePageSize, homePageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	pending, err := ws.Store.GetPendingKudosFrom(ws.TeamID, userID, homePendingLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := []slack.Block{
+		slack.NewHeaderBlock(
+			&slack.TextBlockObject{
+				Type: slack.PlainTextType,
+				Text: "Your trout pond",
+			},
+		),
+	}
+
+	if ws.Config.pointsEnabled() {
+		points, err := describeHomePoints(ws, userID)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, homeContext(points))
+	}
+
+	blocks = append(blocks, homeSection(fmt.Sprintf("*Shout outs you've received* (%d)", total)))
+
+	if len(received) == 0 {
+		blocks = append(blocks, homeContext("Nothing here yet, keep up the great work and they'll start biting!"))
+	}
+	for _, kudo := range received {
+		blocks = append(blocks, homeSection(fmt.Sprintf("%s\n%s", formatReleasedKudo(ws, kudo, false), formatSlackDate(kudo.SharedAt.Time))))
+	}
+
+	pageButtons := []slack.BlockElement{}
+	if page > 0 {
+		pageButtons = append(pageButtons, slack.NewButtonBlockElement(
+			"",
+			strconv.Itoa(page-1),
+			&slack.TextBlockObject{
+				Type: slack.PlainTextType,
+				Text: "Newer",
+			},
+		))
+	}
+	if int64((page+1)*homePageSize) < total {
+		pageButtons = append(pageButtons, slack.NewButtonBlockElement(
+			"",
+			strconv.Itoa(page+1),
+			&slack.TextBlockObject{
+				Type: slack.PlainTextType,
+				Text: "Older",
+			},
+		))
+	}
+	if len(pageButtons) > 0 {
+		blocks = append(blocks, slack.NewActionBlock(fmt.Sprintf("home-%d", page), pageButtons...))
+	}
+
+	if ws.Config.rewardsEnabled() {
+		rewards, err := ws.Store.GetRewards(ws.TeamID)
+		if err != nil {
+			return nil, err
+		}
+		if len(rewards) > homeRewardsLimit {
+			rewards = rewards[:homeRewardsLimit]
+		}
+
+		rewardBlocks, err := buildRewardBlocks(ws, userID, rewards)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, slack.NewDividerBlock())
+		blocks = append(blocks, rewardBlocks...)
+	}
+
+	blocks = append(blocks,
+		slack.NewDividerBlock(),
+		homeSection("*Your shout outs waiting to be released*"),
+	)
+
+	if len(pending) == 0 {
+		blocks = append(blocks, homeContext("None right now. Use `/trout` to shout someone out!"))
+	}
+	for _, kudo := range pending {
+		privateBlock, anonymousBlock := buildKudoToggleButtons(kudo)
+		blocks = append(blocks,
+			homeSection(fmt.Sprintf("To %s: %s", parser.WrapUserIdForMention(kudo.ToUserID), kudo.Message)),
+			homeContext(describeKudoSettings(kudo)),
+			slack.NewActionBlock(fmt.Sprintf("homekudo-%d", kudo.ID), privateBlock, anonymousBlock, buildKudoEditButton(), buildKudoDeleteButton()),
+		)
+	}
+
+	return blocks, nil
+}
+
+// describeHomePoints sums up the user's balance and what they have left to
+// give.
+func describeHomePoints(ws *Workspace, userID string) (string, error) {
+	balance, err := ws.Store.GetPointsBalance(ws.TeamID, userID)
+	if err != nil {
+		return "", err
+	}
+
+	left, err := remainingPoints(ws, userID)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(fmt.Sprintf(":gem: You have %s. %s", describePoints(balance), left)), nil
+}
+
+func describeKudoSettings(kudo *database.Kudo) string {
+	visibility := "Public"
+	if !kudo.IsPublic {
+		visibility = "Private"
+	}
+
+	settings := visibility + ", from you"
+	if kudo.IsAnonymous {
+		settings = visibility + ", anonymous"
+	}
+
+	if kudo.Pond != "" {
+		settings += ", for the " + kudo.Pond + " pond"
+	}
+	if len(kudo.Categories) > 0 {
+		settings += ", tagged " + strings.Join(kudo.CategoryNames(), ", ")
+	}
+	if kudo.Points > 0 {
+		settings += ", worth " + describePoints(kudo.Points)
+	}
+
+	return settings
+}
+
+func homeSection(text string) *slack.SectionBlock {
+	return slack.NewSectionBlock(
+		&slack.TextBlockObject{
+			Type: slack.MarkdownType,
+			Text: text,
+		},
+		nil,
+		nil,
+	)
+}
+
+func homeContext(text string) *slack.ContextBlock {
+	return slack.NewContextBlock(
+		"",
+		&slack.TextBlockObject{
+			Type: slack.MarkdownType,
+			Text: text,
+		},
+	)
+}
@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// Paths for the Events API, interactivity and slash command request URLs in
+// the Slack app config.
+const (
+	EventsPath        = "/slack/events"
+	InteractivityPath = "/slack/interactivity"
+	CommandsPath      = "/slack/commands"
+)
+
+// maxRequestSize is well over anything Slack sends.
+const maxRequestSize = 1 << 20
+
+// NewHTTPHandler serves Slack's HTTP requests through the router, the same
+// way RunSocketMode does. Every request must be signed with the app's signing
+// secret. More pages can be added to the mux, e.g. with HandleOAuth.
+func NewHTTPHandler(router *Router, signingSecret string) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle(EventsPath, verifySlackRequest(signingSecret, handleEventsRequest(router)))
+	mux.Handle(InteractivityPath, verifySlackRequest(signingSecret, handleInteractivityRequest(router)))
+	mux.Handle(CommandsPath, verifySlackRequest(signingSecret, handleCommandsRequest(router)))
+
+	return mux
+}
+
+// RunHTTP listens for Slack's requests on addr, e.g. ":3000".
+func RunHTTP(addr string, handler http.Handler) error {
+	fmt.Printf("Listening for Slack requests on %s\n", addr)
+
+	return http.ListenAndServe(addr, handler)
+}
+
+// verifySlackRequest rejects anything not signed by Slack, see
+// https://api.slack.com/authentication/verifying-requests-from-slack
+func verifySlackRequest(signingSecret string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestSize))
+		if err != nil {
+			http.Error(w, "unreadable body", http.StatusBadRequest)
+			return
+		}
+
+		verifier, err := slack.NewSecretsVerifier(r.Header, signingSecret)
+		if err == nil {
+			_, err = verifier.Write(body)
+		}
+		if err == nil {
+			err = verifier.Ensure()
+		}
+		if err != nil {
+			fmt.Printf("Rejected unsigned request to %s: %v\n", r.URL.Path, err)
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next(w, r)
+	}
+}
+
+func handleEventsRequest(router *Router) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "unreadable body", http.StatusBadRequest)
+			return
+		}
+
+		// The signature already proves it's from Slack
+		event, err := slackevents.ParseEvent(body, slackevents.OptionNoVerifyToken())
+		if err != nil {
+			http.Error(w, "invalid event", http.StatusBadRequest)
+			return
+		}
+
+		if event.Type == slackevents.URLVerification {
+			verification, ok := event.Data.(*slackevents.EventsAPIURLVerificationEvent)
+			if !ok {
+				http.Error(w, "invalid challenge", http.StatusBadRequest)
+				return
+			}
+
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte(verification.Challenge))
+			return
+		}
+
+		// Acknowledge straight away like socket mode does, Slack retries
+		// anything that takes more than a few seconds
+		w.WriteHeader(http.StatusOK)
+
+		go func() {
+			err := router.HandleEvent(event)
+			if err != nil {
+				fmt.Printf("Unhandled Events API event: %v\n", err)
+			}
+		}()
+	}
+}
+
+func handleInteractivityRequest(router *Router) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var callback slack.InteractionCallback
+		err := json.Unmarshal([]byte(r.FormValue("payload")), &callback)
+		if err != nil {
+			http.Error(w, "invalid payload", http.StatusBadRequest)
+			return
+		}
+
+		// Block actions reply through the response URL or the API, and a
+		// release can easily outlast Slack's three second timeout
+		if callback.Type == slack.InteractionTypeBlockActions {
+			w.WriteHeader(http.StatusOK)
+
+			go func() {
+				_, err := router.HandleInteraction(callback)
+				if err != nil {
+					fmt.Printf("Interaction failed: %v\n", err)
+				}
+			}()
+			return
+		}
+
+		payload, err := router.HandleInteraction(callback)
+		if err != nil {
+			fmt.Printf("Interaction failed: %v\n", err)
+		}
+
+		writePayload(w, payload)
+	}
+}
+
+func handleCommandsRequest(router *Router) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cmd, err := slack.SlashCommandParse(r)
+		if err != nil {
+			http.Error(w, "invalid command", http.StatusBadRequest)
+			return
+		}
+
+		payload, err := router.HandleCommand(cmd)
+		if err != nil {
+			fmt.Printf("Slash command %s failed: %v\n", cmd.Command, err)
+		}
+
+		writePayload(w, payload)
+	}
+}
+
+// writePayload replies with the handler's payload, or just acknowledges the
+// request when there isn't one.
+func writePayload(w http.ResponseWriter, payload interface{}) {
+	if payload == nil {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(payload)
+	if err != nil {
+		fmt.Printf("Failed writing response: %v\n", err)
+	}
+}
@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zerodahero/trout/database"
+	"github.com/zerodahero/trout/fakeslack"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// startTestHTTP serves the bot's HTTP endpoints, signed with the fake Slack's
+// secret, against a couple of users and an empty in-memory store.
+func startTestHTTP(t *testing.T) (*fakeslack.Server, *Workspace, string) {
+	fake, workspaces := setupTestSlack(t)
+	ws := testWorkspace(t, workspaces)
+	fake.AddUser(slack.User{ID: "UGIVER", Profile: slack.UserProfile{DisplayName: "giver"}})
+	fake.AddUser(slack.User{ID: "U2", Profile: slack.UserProfile{DisplayName: "nemo"}})
+
+	server := httptest.NewServer(NewHTTPHandler(NewTroutRouter(workspaces), fake.SigningSecret))
+	t.Cleanup(server.Close)
+
+	return fake, ws, server.URL
+}
+
+func TestVerifySlackRequest(t *testing.T) {
+	fake, _, baseURL := startTestHTTP(t)
+	body := []byte(`{"type":"url_verification","challenge":"ahoy"}`)
+
+	var tests = []struct {
+		name       string
+		method     string
+		sign       func(header http.Header)
+		wantStatus int
+	}{
+		{"signed", http.MethodPost, func(header http.Header) {
+			fakeslack.Sign(header, fake.SigningSecret, time.Now(), body)
+		}, http.StatusOK},
+		{"wrong secret", http.MethodPost, func(header http.Header) {
+			fakeslack.Sign(header, "not-the-secret", time.Now(), body)
+		}, http.StatusUnauthorized},
+		{"stale", http.MethodPost, func(header http.Header) {
+			fakeslack.Sign(header, fake.SigningSecret, time.Now().Add(-10*time.Minute), body)
+		}, http.StatusUnauthorized},
+		{"unsigned", http.MethodPost, func(header http.Header) {}, http.StatusUnauthorized},
+		{"not a post", http.MethodGet, func(header http.Header) {
+			fakeslack.Sign(header, fake.SigningSecret, time.Now(), body)
+		}, http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, baseURL+EventsPath, bytes.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			tt.sign(req.Header)
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestHTTPURLVerification(t *testing.T) {
+	fake, _, baseURL := startTestHTTP(t)
+
+	status, reply, err := fake.Post(baseURL+EventsPath, "application/json", []byte(`{"type":"url_verification","challenge":"ahoy"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != http.StatusOK || string(reply) != "ahoy" {
+		t.Errorf("got %d %q, want the challenge back", status, reply)
+	}
+}
+
+func TestHTTPMentionEvent(t *testing.T) {
+	fake, _, baseURL := startTestHTTP(t)
+
+	status, _, err := fake.PostEvent(baseURL+EventsPath, slackevents.AppMentionEvent{
+		Type:    "app_mention",
+		User:    "UGIVER",
+		Channel: "C1",
+		Text:    "<@UBOT> <@U2> kept the lights on",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != http.StatusOK {
+		t.Fatalf("got status %d", status)
+	}
+
+	notices := fake.AwaitCalls("chat.postEphemeral", 1)
+	if len(notices) != 1 || !strings.Contains(notices[0].Values.Get("text"), "Got it!") {
+		t.Fatalf("expected an acknowledgement, got %v", notices)
+	}
+}
+
+func TestHTTPSlashCommand(t *testing.T) {
+	var tests = []struct {
+		name      string
+		cmd       slack.SlashCommand
+		wantReply string
+	}{
+		{"shout out", slack.SlashCommand{Command: "/trout", UserID: "UGIVER", ChannelID: "C1", Text: "<@U2> thanks for pairing"}, "kudo-"},
+		{"release denied", slack.SlashCommand{Command: "/shout-trout", UserID: "UGIVER", ChannelID: "C1"}, "not allowed to release"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, _, baseURL := startTestHTTP(t)
+
+			status, reply, err := fake.PostSlashCommand(baseURL+CommandsPath, tt.cmd)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status != http.StatusOK || !strings.Contains(string(reply), tt.wantReply) {
+				t.Errorf("got %d %s, want it to contain %q", status, reply, tt.wantReply)
+			}
+		})
+	}
+}
+
+func TestHTTPInteraction(t *testing.T) {
+	fake, ws, baseURL := startTestHTTP(t)
+	kudo := createTestKudo(t, ws, "UGIVER", "U2", false)
+
+	// Block actions are acknowledged first and answered through the
+	// response URL
+	responseURL := fake.ResponseURL()
+	status, reply, err := fake.PostInteraction(baseURL+InteractivityPath, blockActionCallback("UGIVER", kudoBlockID([]*database.Kudo{kudo}), "private", responseURL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != http.StatusOK || len(reply) != 0 {
+		t.Fatalf("got %d %s, want an empty acknowledgement", status, reply)
+	}
+	if replies := fake.AwaitWebhooks(responseURL, 1); len(replies) != 1 || !replies[0].ReplaceOriginal {
+		t.Errorf("expected the buttons to be replaced, got %v", replies)
+	}
+
+	// View submissions answer in the response body
+	status, reply, err = fake.PostInteraction(baseURL+InteractivityPath, slack.InteractionCallback{
+		Type: slack.InteractionTypeViewSubmission,
+		User: slack.User{ID: "UGIVER"},
+		View: slack.View{CallbackID: "kudo-compose", PrivateMetadata: "{}", State: &slack.ViewState{}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var response slack.ViewSubmissionResponse
+	err = json.Unmarshal(reply, &response)
+	if err != nil || status != http.StatusOK || response.ResponseAction != slack.RAErrors {
+		t.Errorf("expected validation errors, got %d %s (%v)", status, reply, err)
+	}
+}
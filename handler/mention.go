@@ -9,45 +9,65 @@ import (
 	"github.com/slack-go/slack/slackevents"
 )
 
-func HandleMention(ev *slackevents.AppMentionEvent, save func(*database.Kudo) error) {
+func HandleMention(ws *Workspace, ev *slackevents.AppMentionEvent, prepare func(*Workspace, *database.Kudo) error) {
 	mentionCount := parser.GetMentionCount(ev.Text)
 	if mentionCount < 2 {
-		err := notifyMissingToUser(ev.Channel, ev.User)
+		err := notifyMissingToUser(ws, ev.Channel, ev.User)
 		if err != nil {
 			fmt.Printf("failed posting message: %v", err)
 		}
 		return
 	}
-	if mentionCount > 2 {
-		err := notifyMultipleUserNotSupported(ev.Channel, ev.User)
+
+	kudos, err := database.NewKudosFromMentionEvent(ev, ws.TeamID, ws.BotUserID)
+	if err != nil {
+		fmt.Printf("failed to parse kudo: %v", err)
+		return
+	}
+
+	kudos = withoutSelfShoutOuts(kudos)
+	if len(kudos) == 0 {
+		err := notifySelfShoutOutNotAllowed(ws, ev.Channel, ev.User)
 		if err != nil {
 			fmt.Printf("failed posting message: %v", err)
 		}
 		return
 	}
 
-	kudo, err := database.NewKudoFromMentionEvent(ev, userID)
+	denied, err := saveWithinQuota(ws, ev.User, kudos, prepare)
 	if err != nil {
-		fmt.Printf("failed to parse kudo: %v", err)
+		fmt.Printf("failed to store kudo: %v", err)
 		return
 	}
-
-	if kudo.FromUserID == kudo.ToUserID {
-		err := notifySelfShoutOutNotAllowed(ev.Channel, ev.User)
+	if denied != "" {
+		err = notifyUser(ws, ev.Channel, ev.User, denied)
 		if err != nil {
 			fmt.Printf("failed posting message: %v", err)
 		}
 		return
 	}
 
-	err = save(kudo)
+	allowance, err := remainingAllowance(ws, ev.User)
 	if err != nil {
-		fmt.Printf("failed to store kudo: %v", err)
-		return
+		fmt.Printf("failed counting allowance: %v\n", err)
 	}
 
-	err = notifyKudoReceived(ev.Channel, ev.User)
+	err = notifyKudoReceived(ws, ev.Channel, ev.User, allowance)
 	if err != nil {
 		fmt.Printf("failed posting acknowledgement: %v", err)
 	}
 }
+
+// withoutSelfShoutOuts drops any kudos the giver addressed to themselves, so
+// tagging yourself alongside your team doesn't reject the whole shout out.
+func withoutSelfShoutOuts(kudos []*database.Kudo) []*database.Kudo {
+	filtered := make([]*database.Kudo, 0, len(kudos))
+	for _, kudo := range kudos {
+		if kudo.FromUserID == kudo.ToUserID {
+			continue
+		}
+		filtered = append(filtered, kudo)
+	}
+
+	return filtered
+}
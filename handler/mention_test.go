@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/slack-go/slack/slackevents"
+)
+
+func TestHandleMention(t *testing.T) {
+	var tests = []struct {
+		name       string
+		text       string
+		wantTo     []string
+		wantNotice string
+	}{
+		{"one recipient", "<@UBOT> <@U2> thanks for the review", []string{"U2"}, "Got it!"},
+		{"several recipients", "<@UBOT> <@U2> <@U3> great pairing", []string{"U2", "U3"}, "Got it!"},
+		{"self dropped from the crew", "<@UBOT> <@UGIVER> <@U2> we did it", []string{"U2"}, "Got it!"},
+		{"self only", "<@UBOT> <@UGIVER> go me", nil, "I don't do self shout-outs"},
+		{"nobody tagged", "<@UBOT> thanks everyone", nil, "who's this about?"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, workspaces := setupTestSlack(t)
+			ws := testWorkspace(t, workspaces)
+
+			HandleMention(ws, &slackevents.AppMentionEvent{
+				User:    "UGIVER",
+				Channel: "C1",
+				Text:    tt.text,
+			}, prepareTestKudo)
+
+			kudos, err := ws.Store.GetPendingKudosFrom("T1", "UGIVER", 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(kudos) != len(tt.wantTo) {
+				t.Fatalf("got %d kudos, want %d", len(kudos), len(tt.wantTo))
+			}
+			for i, kudo := range kudos {
+				if kudo.ToUserID != tt.wantTo[i] {
+					t.Errorf("kudo %d: got recipient %s, want %s", i, kudo.ToUserID, tt.wantTo[i])
+				}
+				if strings.Contains(kudo.Message, "<@UBOT>") {
+					t.Errorf("kudo %d: bot mention left in %q", i, kudo.Message)
+				}
+			}
+
+			notices := fake.Calls("chat.postEphemeral")
+			if len(notices) != 1 {
+				t.Fatalf("got %d ephemeral messages, want 1", len(notices))
+			}
+			if got := notices[0].Values.Get("text"); !strings.Contains(got, tt.wantNotice) {
+				t.Errorf("got notice %q, want it to contain %q", got, tt.wantNotice)
+			}
+			if got := notices[0].Values.Get("user"); got != "UGIVER" {
+				t.Errorf("got notice for %s, want UGIVER", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// Logging prints each request as it comes in, and any error handling it.
+func Logging(next HandlerFunc) HandlerFunc {
+	return func(req *Request) (interface{}, error) {
+		fmt.Printf("%s received: %s from %s\n", req.Kind, req.Route, req.UserID())
+
+		payload, err := next(req)
+		if err != nil {
+			fmt.Printf("Error handling %s %s: %v\n", req.Kind, req.Route, err)
+		}
+
+		return payload, err
+	}
+}
+
+// Recover turns a panic in a handler into an error, so one bad request
+// doesn't take down the event loop.
+func Recover(next HandlerFunc) HandlerFunc {
+	return func(req *Request) (payload interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				fmt.Printf("panic handling %s %s: %v\n%s", req.Kind, req.Route, r, debug.Stack())
+				payload, err = nil, fmt.Errorf("panic handling %s %s: %v", req.Kind, req.Route, r)
+			}
+		}()
+
+		return next(req)
+	}
+}
+
+// LoadWorkspace looks up the workspace the request came from, for the
+// handlers to act in. Requests from a workspace trout isn't installed in stop
+// here.
+func LoadWorkspace(workspaces *Workspaces) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(req *Request) (interface{}, error) {
+			ws, err := workspaces.Get(req.TeamID())
+			if err != nil {
+				return nil, err
+			}
+			req.Workspace = ws
+
+			return next(req)
+		}
+	}
+}
+
+// Authorize only lets the request through when check allows it, otherwise the
+// user is told message instead.
+func Authorize(check func(req *Request) (bool, error), message string) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(req *Request) (interface{}, error) {
+			allowed, err := check(req)
+			if err != nil {
+				return nil, err
+			}
+			if allowed {
+				return next(req)
+			}
+
+			if req.Kind == KindCommand {
+				return commandText(message), nil
+			}
+
+			return nil, replaceOriginalWithText(req.Workspace, req.Callback.ResponseURL, message)
+		}
+	}
+}
+
+// RequireReleasePermission limits a route to admins and those granted
+// release permission.
+var RequireReleasePermission = Authorize(func(req *Request) (bool, error) {
+	return canRelease(req.Workspace, req.UserID())
+}, releaseDenied)
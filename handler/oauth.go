@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/zerodahero/trout/database"
+
+	"github.com/slack-go/slack"
+)
+
+// Paths for installing the app into a workspace. The callback is the redirect
+// URL in the Slack app config.
+const (
+	InstallPath       = "/slack/install"
+	OAuthCallbackPath = "/slack/oauth/callback"
+)
+
+// BotScopes are the bot token scopes the app is installed with.
+var BotScopes = []string{
+	"app_mentions:read",
+	"chat:write",
+	"channels:read",
+	"commands",
+	"reactions:read",
+	"usergroups:read",
+	"users:read",
+}
+
+const oauthStateCookie = "trout_oauth_state"
+
+// OAuthConfig is the app's credentials for installing it over OAuth v2, see
+// https://api.slack.com/authentication/oauth-v2
+type OAuthConfig struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+
+	// HTTPClient exchanges the code, http.DefaultClient when not set
+	HTTPClient *http.Client
+}
+
+// HandleOAuth adds the install and OAuth callback pages to the mux, new
+// installations are added to the workspaces.
+func HandleOAuth(mux *http.ServeMux, workspaces *Workspaces, config OAuthConfig) {
+	mux.HandleFunc(InstallPath, handleInstall(config))
+	mux.HandleFunc(OAuthCallbackPath, handleOAuthCallback(workspaces, config))
+}
+
+// handleInstall sends the installer off to Slack to approve the app. The state
+// is kept in a cookie, so the callback can tell it's finishing an install
+// started here.
+func handleInstall(config OAuthConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		state, err := newRandomToken()
+		if err != nil {
+			http.Error(w, "failed starting install", http.StatusInternalServerError)
+			return
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     oauthStateCookie,
+			Value:    state,
+			Path:     OAuthCallbackPath,
+			MaxAge:   600,
+			HttpOnly: true,
+			Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+			SameSite: http.SameSiteLaxMode,
+		})
+
+		query := url.Values{
+			"client_id": {config.ClientID},
+			"scope":     {strings.Join(BotScopes, ",")},
+			"state":     {state},
+		}
+		if config.RedirectURL != "" {
+			query.Set("redirect_uri", config.RedirectURL)
+		}
+
+		http.Redirect(w, r, "https://slack.com/oauth/v2/authorize?"+query.Encode(), http.StatusFound)
+	}
+}
+
+func handleOAuthCallback(workspaces *Workspaces, config OAuthConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("error") != "" {
+			http.Error(w, "Installation cancelled: "+query.Get("error"), http.StatusForbidden)
+			return
+		}
+
+		cookie, err := r.Cookie(oauthStateCookie)
+		if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(query.Get("state"))) != 1 {
+			http.Error(w, "Installation expired, please start again.", http.StatusBadRequest)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: oauthStateCookie, Path: OAuthCallbackPath, MaxAge: -1})
+
+		httpClient := config.HTTPClient
+		if httpClient == nil {
+			httpClient = http.DefaultClient
+		}
+
+		resp, err := slack.GetOAuthV2Response(httpClient, config.ClientID, config.ClientSecret, query.Get("code"), config.RedirectURL)
+		if err != nil {
+			fmt.Printf("OAuth exchange failed: %v\n", err)
+			http.Error(w, "Installation failed, please try again.", http.StatusBadGateway)
+			return
+		}
+
+		installation := database.NewInstallationFromOAuth(resp)
+		err = workspaces.store.SaveInstallation(installation)
+		if err != nil {
+			fmt.Printf("failed saving installation for %s: %v\n", installation.TeamID, err)
+			http.Error(w, "Installation failed, please try again.", http.StatusInternalServerError)
+			return
+		}
+
+		// A reinstall may have changed the token
+		workspaces.forget(installation.TeamID)
+		fmt.Printf("Installed into workspace %s (%s)\n", installation.TeamName, installation.TeamID)
+
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintf(w, "Trout is installed in %s! Head back to Slack and /trout someone.\n", installation.TeamName)
+	}
+}
+
+// newRandomToken is 16 random bytes in hex, e.g. for an OAuth state.
+func newRandomToken() (string, error) {
+	token := make([]byte, 16)
+	_, err := rand.Read(token)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(token), nil
+}
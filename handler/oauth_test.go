@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/zerodahero/trout/fakeslack"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// startTestInstall serves the HTTP endpoints with OAuth installs, with the
+// token exchange pointed at the fake Slack.
+func startTestInstall(t *testing.T) (*fakeslack.Server, *Workspaces, *http.Client, string) {
+	fake, workspaces := setupTestSlack(t)
+	fake.AddUser(slack.User{ID: "UGIVER", Profile: slack.UserProfile{DisplayName: "giver"}})
+	fake.AddUser(slack.User{ID: "U2", Profile: slack.UserProfile{DisplayName: "nemo"}})
+
+	mux := NewHTTPHandler(NewTroutRouter(workspaces), fake.SigningSecret)
+	HandleOAuth(mux, workspaces, OAuthConfig{ClientID: "client", ClientSecret: "secret", HTTPClient: fake.HTTPClient()})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	browser := &http.Client{
+		Jar: jar,
+		// Stop at the redirect to Slack
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	return fake, workspaces, browser, server.URL
+}
+
+// startInstall follows the install link as far as Slack, returning the state
+// Slack would send back.
+func startInstall(t *testing.T, browser *http.Client, baseURL string) string {
+	resp, err := browser.Get(baseURL + InstallPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("got status %d, want a redirect", resp.StatusCode)
+	}
+	authorize, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authorize.Host != "slack.com" || authorize.Query().Get("client_id") != "client" || !strings.Contains(authorize.Query().Get("scope"), "commands") {
+		t.Errorf("unexpected authorize URL %s", authorize)
+	}
+
+	return authorize.Query().Get("state")
+}
+
+func TestOAuthCallback(t *testing.T) {
+	var tests = []struct {
+		name       string
+		code       string
+		state      func(state string) string
+		wantStatus int
+		wantSaved  bool
+	}{
+		{"approved", "good-code", func(state string) string { return state }, http.StatusOK, true},
+		{"forged state", "good-code", func(state string) string { return "forged" }, http.StatusBadRequest, false},
+		{"invalid code", "bad-code", func(state string) string { return state }, http.StatusBadGateway, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, workspaces, browser, baseURL := startTestInstall(t)
+			fake.AddInstall("good-code", "T2", "Other Pond", "xoxb-t2")
+
+			state := startInstall(t, browser, baseURL)
+			resp, err := browser.Get(baseURL + OAuthCallbackPath + "?" + url.Values{"code": {tt.code}, "state": {tt.state(state)}}.Encode())
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+
+			installation, err := workspaces.store.GetInstallation("T2")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if saved := installation != nil; saved != tt.wantSaved {
+				t.Fatalf("got installation %v, want saved %v", installation, tt.wantSaved)
+			}
+			if tt.wantSaved && (installation.BotToken != "xoxb-t2" || installation.TeamName != "Other Pond" || installation.InstalledBy != "UINSTALLER") {
+				t.Errorf("unexpected installation %+v", installation)
+			}
+		})
+	}
+}
+
+func TestInstalledWorkspace(t *testing.T) {
+	fake, workspaces, browser, baseURL := startTestInstall(t)
+	fake.AddInstall("good-code", "T2", "Other Pond", "xoxb-t2")
+
+	state := startInstall(t, browser, baseURL)
+	resp, err := browser.Get(baseURL + OAuthCallbackPath + "?" + url.Values{"code": {"good-code"}, "state": {state}}.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	router := NewTroutRouter(workspaces)
+	_, err = router.HandleCommand(slack.SlashCommand{Command: "/trout", TeamID: "T2", UserID: "UGIVER", ChannelID: "C1", Text: "<@U2> thanks"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Acting in T2 uses T2's token, and keeps the kudo there
+	lookups := fake.Calls("users.info")
+	if len(lookups) == 0 || lookups[len(lookups)-1].Token != "xoxb-t2" {
+		t.Errorf("expected users looked up with T2's token, got %v", lookups)
+	}
+	for teamID, want := range map[string]int{"T1": 0, "T2": 1} {
+		pending, err := workspaces.store.GetPendingKudosFrom(teamID, "UGIVER", 10)
+		if err != nil || len(pending) != want {
+			t.Errorf("expected %d kudos in %s, got %d (%v)", want, teamID, len(pending), err)
+		}
+	}
+
+	err = router.HandleEvent(slackevents.EventsAPIEvent{
+		Type:       slackevents.CallbackEvent,
+		TeamID:     "T2",
+		InnerEvent: slackevents.EventsAPIInnerEvent{Type: slackevents.AppUninstalled, Data: &slackevents.AppUninstalledEvent{}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	installation, err := workspaces.store.GetInstallation("T2")
+	if err != nil || installation != nil {
+		t.Errorf("expected the installation removed, got %v (%v)", installation, err)
+	}
+	_, err = router.HandleCommand(slack.SlashCommand{Command: "/trout", TeamID: "T2", UserID: "UGIVER", ChannelID: "C1", Text: "<@U2> thanks"})
+	if err == nil {
+		t.Errorf("expected commands from an uninstalled workspace to be refused")
+	}
+}
+
+func TestReinstalledBotTokenWorkspace(t *testing.T) {
+	fake, workspaces := setupTestSlack(t)
+	fake.AddUser(slack.User{ID: "UGIVER"})
+	fake.AddUser(slack.User{ID: "U2"})
+	router := NewTroutRouter(workspaces)
+
+	err := router.HandleEvent(slackevents.EventsAPIEvent{
+		Type:       slackevents.CallbackEvent,
+		TeamID:     "T1",
+		InnerEvent: slackevents.EventsAPIInnerEvent{Type: slackevents.AppUninstalled, Data: &slackevents.AppUninstalledEvent{}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The fake still takes the token, as it would once the app's reinstalled
+	checks := len(fake.Calls("auth.test"))
+	_, err = router.HandleCommand(slack.SlashCommand{Command: "/trout", TeamID: "T1", UserID: "UGIVER", ChannelID: "C1", Text: "<@U2> thanks"})
+	if err != nil {
+		t.Fatalf("expected the bot token's workspace back, got %v", err)
+	}
+	if got := len(fake.Calls("auth.test")); got != checks+1 {
+		t.Errorf("expected the token checked again, got %d checks, want %d", got, checks+1)
+	}
+
+	pending, err := workspaces.store.GetPendingKudosFrom("T1", "UGIVER", 10)
+	if err != nil || len(pending) != 1 {
+		t.Errorf("expected the kudo kept in T1, got %d (%v)", len(pending), err)
+	}
+}
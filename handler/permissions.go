@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/zerodahero/trout/database"
+	"github.com/zerodahero/trout/parser"
+
+	"github.com/slack-go/slack"
+)
+
+// isTroutAdmin treats Slack workspace admins and owners as trout admins, who
+// can always release and can grant release to others.
+func isTroutAdmin(ws *Workspace, userID string) (bool, error) {
+	user, err := ws.API.GetUserInfo(userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get user info: %v", err)
+	}
+
+	return user.IsAdmin || user.IsOwner || user.IsPrimaryOwner, nil
+}
+
+func canRelease(ws *Workspace, userID string) (bool, error) {
+	admin, err := isTroutAdmin(ws, userID)
+	if err != nil || admin {
+		return admin, err
+	}
+
+	permissions, err := ws.Store.GetReleasePermissions(ws.TeamID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, permission := range permissions {
+		if !permission.IsUserGroup {
+			if permission.SubjectID == userID {
+				return true, nil
+			}
+			continue
+		}
+
+		members, err := ws.API.GetUserGroupMembers(permission.SubjectID)
+		if err != nil {
+			return false, fmt.Errorf("failed to get user group members: %v", err)
+		}
+		for _, member := range members {
+			if member == userID {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+func handleGrantCommand(ws *Workspace, cmd slack.SlashCommand, subjects string) (interface{}, error) {
+	userIDs, groupIDs := parseReleaseSubjects(subjects)
+	if len(userIDs)+len(groupIDs) == 0 {
+		return commandText("Who should be able to release shout outs? Tag the users or user groups, e.g. `/shout-trout grant @jim @fishers`."), nil
+	}
+
+	granted := []string{}
+	for _, subjectID := range append(userIDs, groupIDs...) {
+		permission, err := ws.Store.GetReleasePermission(cmd.TeamID, subjectID)
+		if err != nil {
+			return nil, err
+		}
+		if permission == nil {
+			permission = database.NewReleasePermission(cmd.TeamID, subjectID, isGroupID(subjectID, groupIDs), cmd.UserID)
+			err = ws.Store.SaveReleasePermission(permission)
+			if err != nil {
+				return nil, fmt.Errorf("failed to save release permission: %v", err)
+			}
+		}
+		granted = append(granted, formatReleaseSubject(permission))
+	}
+
+	return commandText("Done! These folks can now release shout outs: " + strings.Join(granted, ", ")), nil
+}
+
+func handleRevokeCommand(ws *Workspace, cmd slack.SlashCommand, subjects string) (interface{}, error) {
+	userIDs, groupIDs := parseReleaseSubjects(subjects)
+	if len(userIDs)+len(groupIDs) == 0 {
+		return commandText("Whose release permission should be revoked? Tag the users or user groups, e.g. `/shout-trout revoke @jim`."), nil
+	}
+
+	revoked := []string{}
+	for _, subjectID := range append(userIDs, groupIDs...) {
+		permission, err := ws.Store.GetReleasePermission(cmd.TeamID, subjectID)
+		if err != nil {
+			return nil, err
+		}
+		if permission == nil {
+			continue
+		}
+
+		err = ws.Store.DeleteReleasePermission(permission)
+		if err != nil {
+			return nil, fmt.Errorf("failed to remove release permission: %v", err)
+		}
+		revoked = append(revoked, formatReleaseSubject(permission))
+	}
+
+	if len(revoked) == 0 {
+		return commandText("None of those had release permission to begin with."), nil
+	}
+
+	return commandText("Done! These folks can no longer release shout outs: " + strings.Join(revoked, ", ")), nil
+}
+
+func handlePermissionsCommand(ws *Workspace, cmd slack.SlashCommand) (interface{}, error) {
+	permissions, err := ws.Store.GetReleasePermissions(cmd.TeamID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(permissions) == 0 {
+		return commandText("Only workspace admins can release shout outs right now. Use `/shout-trout grant` to let others in."), nil
+	}
+
+	subjects := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		subjects = append(subjects, formatReleaseSubject(permission))
+	}
+
+	return commandText("Besides workspace admins, these folks can release shout outs: " + strings.Join(subjects, ", ")), nil
+}
+
+func parseReleaseSubjects(text string) ([]string, []string) {
+	userIDs, err := parser.ParseRecipientsFromText(text)
+	if err != nil {
+		userIDs = []string{}
+	}
+
+	return userIDs, parser.ParseUserGroupsFromText(text)
+}
+
+func isGroupID(subjectID string, groupIDs []string) bool {
+	for _, groupID := range groupIDs {
+		if groupID == subjectID {
+			return true
+		}
+	}
+
+	return false
+}
+
+func formatReleaseSubject(permission *database.ReleasePermission) string {
+	if permission.IsUserGroup {
+		return parser.WrapUserGroupIdForMention(permission.SubjectID)
+	}
+
+	return parser.WrapUserIdForMention(permission.SubjectID)
+}
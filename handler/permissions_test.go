@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/zerodahero/trout/database"
+
+	"github.com/slack-go/slack"
+)
+
+// setupTestPermissions has an admin, an owner, someone granted release
+// directly, a member of a granted group and a member of a group that isn't.
+func setupTestPermissions(t *testing.T) (*Workspaces, *Workspace) {
+	fake, workspaces := setupTestSlack(t)
+	ws := testWorkspace(t, workspaces)
+	for _, user := range []slack.User{
+		{ID: "UADMIN", IsAdmin: true},
+		{ID: "UOWNER", IsOwner: true},
+		{ID: "UGRANTED"},
+		{ID: "UMEMBER"},
+		{ID: "UOUTSIDER"},
+		{ID: "UGIVER"},
+		{ID: "U2"},
+	} {
+		fake.AddUser(user)
+	}
+	fake.AddUserGroup("SFISHERS", "UMEMBER")
+	fake.AddUserGroup("SOTHERS", "UOUTSIDER")
+
+	for _, permission := range []*database.ReleasePermission{
+		database.NewReleasePermission("T1", "UGRANTED", false, "UADMIN"),
+		database.NewReleasePermission("T1", "SFISHERS", true, "UADMIN"),
+	} {
+		err := ws.Store.SaveReleasePermission(permission)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return workspaces, ws
+}
+
+func TestCanRelease(t *testing.T) {
+	var tests = []struct {
+		userID string
+		want   bool
+	}{
+		{"UADMIN", true},
+		{"UOWNER", true},
+		{"UGRANTED", true},
+		{"UMEMBER", true},
+		{"UOUTSIDER", false},
+		{"UGIVER", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.userID, func(t *testing.T) {
+			_, ws := setupTestPermissions(t)
+
+			allowed, err := canRelease(ws, tt.userID)
+			if err != nil || allowed != tt.want {
+				t.Errorf("got %v, want %v (%v)", allowed, tt.want, err)
+			}
+		})
+	}
+}
+
+func TestGrantAndRevokeCommands(t *testing.T) {
+	var tests = []struct {
+		name      string
+		userID    string
+		text      string
+		wantReply string
+		checkID   string
+		wantAllow bool
+	}{
+		{"admin grants a user", "UADMIN", "grant <@UGIVER>", "can now release shout outs: <@UGIVER>", "UGIVER", true},
+		{"admin grants a group", "UADMIN", "grant <!subteam^SOTHERS|@others>", "can now release shout outs: <!subteam^SOTHERS>", "UOUTSIDER", true},
+		{"admin revokes a user", "UADMIN", "revoke <@UGRANTED>", "can no longer release shout outs: <@UGRANTED>", "UGRANTED", false},
+		{"admin revokes a group", "UADMIN", "revoke <!subteam^SFISHERS|@fishers>", "can no longer release shout outs: <!subteam^SFISHERS>", "UMEMBER", false},
+		{"granted user can't grant", "UGRANTED", "grant <@UGIVER>", "only workspace admins can hand out release permissions", "UGIVER", false},
+		{"granted user can't revoke", "UGRANTED", "revoke <@UMEMBER>", "only workspace admins can hand out release permissions", "UMEMBER", true},
+		{"anyone else can't grant themselves", "UGIVER", "grant <@UGIVER>", "only workspace admins can hand out release permissions", "UGIVER", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, ws := setupTestPermissions(t)
+
+			payload, err := HandleShoutTroutCommand(ws, slack.SlashCommand{Command: "/shout-trout", Text: tt.text, TeamID: "T1", UserID: tt.userID, ChannelID: "C1"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reply := encodePayload(payload); !strings.Contains(reply, tt.wantReply) {
+				t.Errorf("got reply %s, want it to contain %q", reply, tt.wantReply)
+			}
+
+			allowed, err := canRelease(ws, tt.checkID)
+			if err != nil || allowed != tt.wantAllow {
+				t.Errorf("expected %s allowed to release %v, got %v (%v)", tt.checkID, tt.wantAllow, allowed, err)
+			}
+		})
+	}
+}
+
+func TestReleaseDenied(t *testing.T) {
+	var tests = []struct {
+		name     string
+		dispatch func(r *Router, responseURL string) (interface{}, error)
+	}{
+		{"slash command", func(r *Router, responseURL string) (interface{}, error) {
+			return r.HandleCommand(slack.SlashCommand{Command: "/shout-trout", TeamID: "T1", UserID: "UOUTSIDER", ChannelID: "C1"})
+		}},
+		{"schedule", func(r *Router, responseURL string) (interface{}, error) {
+			return r.HandleCommand(slack.SlashCommand{Command: "/shout-trout", TeamID: "T1", UserID: "UOUTSIDER", ChannelID: "C1", Text: "schedule @daily"})
+		}},
+		{"password", func(r *Router, responseURL string) (interface{}, error) {
+			return r.HandleInteraction(blockActionCallback("UOUTSIDER", "shouttrout-1", "hunter2", responseURL))
+		}},
+		{"release button", func(r *Router, responseURL string) (interface{}, error) {
+			return r.HandleInteraction(blockActionCallback("UOUTSIDER", "release-preview", "release", responseURL))
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, workspaces := setupTestSlack(t)
+			ws := testWorkspace(t, workspaces)
+			fake.AddUser(slack.User{ID: "UOUTSIDER"})
+			createTestKudo(t, ws, "UGIVER", "U2", false)
+			responseURL := fake.ResponseURL()
+
+			payload, err := tt.dispatch(NewTroutRouter(workspaces), responseURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			reply := encodePayload(payload)
+			if payload == nil {
+				encoded, _ := json.Marshal(lastWebhook(fake, responseURL))
+				reply = string(encoded)
+			}
+			if !strings.Contains(reply, releaseDenied) {
+				t.Errorf("got reply %s, want %q", reply, releaseDenied)
+			}
+
+			if posts := fake.Calls("chat.postMessage"); len(posts) != 0 {
+				t.Errorf("expected nothing released, got %d posts", len(posts))
+			}
+			schedules, err := ws.Store.GetReleaseSchedules()
+			if err != nil || len(schedules) != 0 {
+				t.Errorf("expected nothing scheduled, got %v (%v)", schedules, err)
+			}
+		})
+	}
+}
+
+func TestShoutTroutPassword(t *testing.T) {
+	var tests = []struct {
+		name     string
+		password string
+		want     string
+		notWant  string
+	}{
+		{"no password", "", "release-preview", "super secret password"},
+		{"password", "hunter2", "super secret password", "release-preview"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, ws := setupTestPermissions(t)
+			ws.Config.ReleasePassword = tt.password
+			createTestKudo(t, ws, "UGIVER", "U2", false)
+
+			payload, err := HandleShoutTroutCommand(ws, slack.SlashCommand{Command: "/shout-trout", TeamID: "T1", UserID: "UGRANTED", ChannelID: "C1"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			reply := encodePayload(payload)
+			if !strings.Contains(reply, tt.want) || strings.Contains(reply, tt.notWant) {
+				t.Errorf("got %s, want it to contain %q and not %q", reply, tt.want, tt.notWant)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/zerodahero/trout/database"
+	"github.com/zerodahero/trout/parser"
+
+	"github.com/slack-go/slack"
+)
+
+const pointsHistorySize = 10
+
+// assignPoints sets the points each kudo carries, taking a "+5" out of the
+// message when the giver chose them.
+func (c *Config) assignPoints(kudos []*database.Kudo) {
+	for _, kudo := range kudos {
+		if c.PointsAllowance > 0 {
+			chosen, message := parser.ParsePointsFromText(kudo.Message)
+			if chosen > 0 {
+				kudo.Points = chosen
+				kudo.Message = message
+				continue
+			}
+		}
+
+		kudo.Points = c.PointsPerKudo
+	}
+}
+
+// allowanceStart is when the current month's allowance started. It resets at
+// the start of every month, UTC.
+func allowanceStart(now time.Time) time.Time {
+	now = now.UTC()
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// checkPoints makes sure the giver has the points left for all of the kudos.
+// The message explains why not, for the giver.
+func checkPoints(ws *Workspace, fromUserID string, kudos []*database.Kudo, now time.Time) (string, error) {
+	if ws.Config.PointsAllowance == 0 {
+		return "", nil
+	}
+
+	total := 0
+	for _, kudo := range kudos {
+		total += kudo.Points
+	}
+
+	given, err := ws.Store.GetPointsGiven(ws.TeamID, fromUserID, allowanceStart(now))
+	if err != nil {
+		return "", err
+	}
+
+	left := ws.Config.PointsAllowance - given
+	if total <= left {
+		return "", nil
+	}
+
+	return fmt.Sprintf("That's %s, but you have %s of your %d monthly points left. Try fewer points, they top back up at the start of the month.",
+		describePoints(total), describeLeft(left), ws.Config.PointsAllowance), nil
+}
+
+// remainingPoints tells the giver how many points they have left to give this
+// month. It's empty without an allowance.
+func remainingPoints(ws *Workspace, fromUserID string) (string, error) {
+	if ws.Config.PointsAllowance == 0 {
+		return "", nil
+	}
+
+	given, err := ws.Store.GetPointsGiven(ws.TeamID, fromUserID, allowanceStart(time.Now()))
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("You have %s of your %d monthly points left to give.", describeLeft(ws.Config.PointsAllowance-given), ws.Config.PointsAllowance), nil
+}
+
+func describePoints(points int) string {
+	if points == 1 {
+		return "1 point"
+	}
+
+	return fmt.Sprintf("%d points", points)
+}
+
+var pointEntryDescriptions = map[string]string{
+	database.PointsGiven:    "given",
+	database.PointsReturned: "returned from a deleted shout out",
+	database.PointsReceived: "received",
+	database.PointsRedeemed: "redeemed for a reward",
+	database.PointsRefunded: "refunded from a reward that wasn't approved",
+}
+
+func describePointEntry(entry *database.PointEntry) string {
+	description, ok := pointEntryDescriptions[entry.Kind]
+	if !ok {
+		description = entry.Kind
+	}
+
+	return fmt.Sprintf("%+d %s, %s", entry.Amount, description, formatSlackDate(entry.CreatedAt))
+}
+
+// handlePointsCommand handles "/trout points", the caller's balance, what
+// they have left to give and their latest ledger entries.
+func handlePointsCommand(ws *Workspace, cmd slack.SlashCommand) (interface{}, error) {
+	if !ws.Config.pointsEnabled() {
+		return commandText("Points are off, set TROUT_POINTS_PER_KUDO or TROUT_POINTS_ALLOWANCE to turn them on."), nil
+	}
+
+	balance, err := ws.Store.GetPointsBalance(ws.TeamID, cmd.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := []string{fmt.Sprintf("*You have %s.*", describePoints(balance))}
+
+	left, err := remainingPoints(ws, cmd.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if left != "" {
+		lines = append(lines, left)
+	}
+
+	entries, err := ws.Store.GetPointEntries(ws.TeamID, cmd.UserID, pointsHistorySize)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) > 0 {
+		lines = append(lines, "", "*Latest*")
+	}
+	for _, entry := range entries {
+		lines = append(lines, describePointEntry(entry))
+	}
+
+	return map[string]interface{}{"blocks": []slack.Block{statsSection(strings.Join(lines, "\n"))}}, nil
+}
@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zerodahero/trout/database"
+
+	"github.com/slack-go/slack"
+)
+
+func TestAllowanceStart(t *testing.T) {
+	var tests = []struct {
+		now  time.Time
+		want time.Time
+	}{
+		{time.Date(2022, 3, 15, 12, 0, 0, 0, time.UTC), time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2022, 3, 31, 23, 0, 0, 0, time.FixedZone("EST", -5*60*60)), time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.now.String(), func(t *testing.T) {
+			if ans := allowanceStart(tt.now); !ans.Equal(tt.want) {
+				t.Errorf("got %v, want %v", ans, tt.want)
+			}
+		})
+	}
+}
+
+func TestGivingPoints(t *testing.T) {
+	var tests = []struct {
+		name        string
+		perKudo     int
+		allowance   int
+		text        string
+		wantPoints  []int
+		wantMessage string
+		wantReply   string
+	}{
+		{"points off", 0, 0, "<@U2> thanks +5", []int{0}, "thanks +5", "Thanks, got it!"},
+		{"fixed points", 2, 0, "<@U2> thanks +5", []int{2}, "thanks +5", "Thanks, got it!"},
+		{"chosen points", 0, 10, "<@U2> thanks +3", []int{3}, "thanks", "You have 1 of your 10 monthly points left to give."},
+		{"fixed points from the allowance", 1, 10, "<@U2> <@U3> thanks", []int{1, 1}, "thanks", "You have 2 of your 10 monthly points left to give."},
+		{"over the allowance", 0, 10, "<@U2> thanks +5", nil, "", "That's 5 points, but you have 4 of your 10 monthly points left."},
+		{"whole crew over the allowance", 0, 10, "<@U2> <@U3> thanks +3", nil, "", "That's 6 points, but you have 4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, workspaces := setupTestSlack(t)
+			ws := testWorkspace(t, workspaces)
+			ws.Config.PointsPerKudo, ws.Config.PointsAllowance = tt.perKudo, tt.allowance
+
+			// Six given already this month
+			given := database.NewKudo("T1", "UGIVER", "U4", "Thanks!")
+			given.Points = 6
+			err := ws.Store.SaveKudo(given)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			payload, err := HandleTroutCommand(ws, slack.SlashCommand{UserID: "UGIVER", ChannelID: "C1", Text: tt.text}, prepareTestKudo)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			kudos, err := ws.Store.GetPendingKudosFrom("T1", "UGIVER", 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			saved := []*database.Kudo{}
+			for _, kudo := range kudos {
+				if kudo.ID != given.ID {
+					saved = append(saved, kudo)
+				}
+			}
+			if len(saved) != len(tt.wantPoints) {
+				t.Fatalf("got %d saved, want %d", len(saved), len(tt.wantPoints))
+			}
+			for i, kudo := range saved {
+				if kudo.Points != tt.wantPoints[i] || !strings.HasSuffix(kudo.Message, tt.wantMessage) {
+					t.Errorf("got %d points for %q, want %d for %q", kudo.Points, kudo.Message, tt.wantPoints[i], tt.wantMessage)
+				}
+			}
+
+			if reply := encodePayload(payload); !strings.Contains(reply, tt.wantReply) {
+				t.Errorf("got reply %s, want it to contain %q", reply, tt.wantReply)
+			}
+		})
+	}
+}
+
+func TestPointsAcrossMonths(t *testing.T) {
+	_, workspaces := setupTestSlack(t)
+	ws := testWorkspace(t, workspaces)
+	ws.Config.PointsAllowance = 10
+
+	lastMonth := database.NewKudo("T1", "UGIVER", "U2", "Thanks!")
+	lastMonth.Points = 4
+	lastMonth.CreatedAt = allowanceStart(time.Now()).Add(-time.Hour)
+	thisMonth := database.NewKudo("T1", "UGIVER", "U3", "Thanks!")
+	thisMonth.Points = 3
+	for _, kudo := range []*database.Kudo{lastMonth, thisMonth} {
+		err := ws.Store.SaveKudo(kudo)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := deleteKudos(ws, []*database.Kudo{lastMonth}, "UGIVER")
+	if err != nil {
+		t.Fatal(err)
+	}
+	left, err := remainingPoints(ws, "UGIVER")
+	if err != nil || left != "You have 7 of your 10 monthly points left to give." {
+		t.Errorf("got %q (%v)", left, err)
+	}
+}
+
+func TestPointsLedger(t *testing.T) {
+	fastReleases(t)
+	fake, workspaces := setupTestSlack(t)
+	ws := testWorkspace(t, workspaces)
+	ws.Config.PointsPerKudo, ws.Config.PointsAllowance = 0, 10
+
+	var kudos []*database.Kudo
+	for _, text := range []string{"<@U2> thanks +4", "<@U3> thanks +3"} {
+		saved, err := database.NewKudosFromText(text, "T1", "UGIVER")
+		if err != nil {
+			t.Fatal(err)
+		}
+		denied, err := saveWithinQuota(ws, "UGIVER", saved, prepareTestKudo)
+		if err != nil || denied != "" {
+			t.Fatalf("expected the shout out saved, got %q (%v)", denied, err)
+		}
+		kudos = append(kudos, saved...)
+	}
+
+	// Deleting hands the points back to give again
+	err := deleteKudos(ws, kudos[1:], "UGIVER")
+	if err != nil {
+		t.Fatal(err)
+	}
+	left, err := remainingPoints(ws, "UGIVER")
+	if err != nil || left != "You have 6 of your 10 monthly points left to give." {
+		t.Errorf("got %q (%v)", left, err)
+	}
+
+	err = releaseKudos(ws, "C1", "UADMIN", database.ReleaseScope{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := false
+	for _, post := range fake.Calls("chat.postMessage") {
+		if strings.Contains(post.Values.Get("text"), "+4 points") {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("expected the points in the release thread")
+	}
+
+	for user, want := range map[string]int{"U2": 4, "U3": 0, "UGIVER": 0} {
+		balance, err := ws.Store.GetPointsBalance("T1", user)
+		if err != nil || balance != want {
+			t.Errorf("expected %s to have %d points, got %d (%v)", user, want, balance, err)
+		}
+	}
+
+	payload, err := HandleTroutCommand(ws, slack.SlashCommand{UserID: "U2", ChannelID: "C1", Text: "points"}, prepareTestKudo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply := encodePayload(payload)
+	for _, want := range []string{"You have 4 points.", "+4 received"} {
+		if !strings.Contains(reply, want) {
+			t.Errorf("got %s, want it to contain %q", reply, want)
+		}
+	}
+}
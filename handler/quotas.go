@@ -0,0 +1,220 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zerodahero/trout/database"
+	"github.com/zerodahero/trout/parser"
+
+	"github.com/slack-go/slack"
+)
+
+// Quota caps how many shout outs can be given over a rolling period, e.g. 10
+// a day.
+type Quota struct {
+	Limit  int
+	Period string
+}
+
+var quotaPeriods = map[string]string{
+	"day":   "daily",
+	"week":  "weekly",
+	"month": "monthly",
+}
+
+// ParseQuotas reads a list like "10/day, 40/week".
+func ParseQuotas(value string) ([]Quota, error) {
+	quotas := []Quota{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		parts := strings.SplitN(item, "/", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("quota %q should look like 10/day", item)
+		}
+		limit, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+		if err != nil || limit < 1 {
+			return nil, fmt.Errorf("quota %q needs a positive limit", item)
+		}
+		period := strings.TrimSpace(parts[1])
+		if _, ok := quotaPeriods[period]; !ok {
+			return nil, fmt.Errorf("quota %q should be per day, week or month", item)
+		}
+
+		quotas = append(quotas, Quota{Limit: limit, Period: period})
+	}
+
+	return quotas, nil
+}
+
+// since is when the quota's period started, counting back from now.
+func (q Quota) since(now time.Time) time.Time {
+	switch q.Period {
+	case "week":
+		return now.AddDate(0, 0, -7)
+	case "month":
+		return now.AddDate(0, -1, 0)
+	default:
+		return now.AddDate(0, 0, -1)
+	}
+}
+
+func (q Quota) String() string {
+	return fmt.Sprintf("%d %s", q.Limit, quotaPeriods[q.Period])
+}
+
+// saveWithinQuota saves all of the kudos, or none when they'd take the giver
+// over a quota or their points allowance. The message then explains why, for
+// the giver. Each kudo goes through prepare first, which may call out to
+// Slack, so only the check and the save hold the giver's lock. That way a
+// burst of shout outs can't all squeeze past the same count.
+func saveWithinQuota(ws *Workspace, fromUserID string, kudos []*database.Kudo, prepare func(*Workspace, *database.Kudo) error) (string, error) {
+	ws.Config.assignPoints(kudos)
+	for _, kudo := range kudos {
+		err := prepare(ws, kudo)
+		if err != nil {
+			return "", fmt.Errorf("failed to store kudo: %w", err)
+		}
+	}
+
+	denied := ""
+	err := ws.Store.WithGiverLock(ws.TeamID, fromUserID, func(store database.Store) error {
+		locked := *ws
+		locked.Store = store
+
+		now := time.Now()
+		var err error
+		denied, err = checkQuotas(&locked, fromUserID, kudos, now)
+		if err != nil || denied != "" {
+			return err
+		}
+
+		denied, err = checkPoints(&locked, fromUserID, kudos, now)
+		if err != nil || denied != "" {
+			return err
+		}
+
+		for _, kudo := range kudos {
+			err = store.SaveKudo(kudo)
+			if err != nil {
+				return fmt.Errorf("failed to store kudo: %w", err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return denied, nil
+}
+
+func checkQuotas(ws *Workspace, fromUserID string, kudos []*database.Kudo, now time.Time) (string, error) {
+	for _, quota := range ws.Config.GiverQuotas {
+		given, err := ws.Store.CountGiven(ws.TeamID, fromUserID, database.StatsFilter{Since: quota.since(now)}, true)
+		if err != nil {
+			return "", err
+		}
+
+		left := quota.Limit - int(given)
+		if len(kudos) <= left {
+			continue
+		}
+
+		this := "this shout out"
+		if len(kudos) > 1 {
+			this = fmt.Sprintf("this shout out to %d people", len(kudos))
+		}
+
+		return fmt.Sprintf("Easy there! You get %s shout outs and have %s left, so %s will have to wait. They free up as older ones age out of the past %s.",
+			quota, describeLeft(left), this, quota.Period), nil
+	}
+
+	for _, quota := range ws.Config.PairQuotas {
+		for _, kudo := range kudos {
+			given, err := ws.Store.CountGivenTo(ws.TeamID, fromUserID, kudo.ToUserID, quota.since(now))
+			if err != nil {
+				return "", err
+			}
+
+			if int(given) >= quota.Limit {
+				return fmt.Sprintf("You've already given %s %d shout outs in the past %s, the most anyone can give one person. Spread the love around!",
+					parser.WrapUserIdForMention(kudo.ToUserID), given, quota.Period), nil
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// remainingAllowance tells the giver how many shout outs they have left,
+// going by whichever quota is closest to running out, and how many points.
+// It's empty without quotas or points to give.
+func remainingAllowance(ws *Workspace, fromUserID string) (string, error) {
+	now := time.Now()
+	message := ""
+	fewest := -1
+	for _, quota := range ws.Config.GiverQuotas {
+		given, err := ws.Store.CountGiven(ws.TeamID, fromUserID, database.StatsFilter{Since: quota.since(now)}, true)
+		if err != nil {
+			return "", err
+		}
+
+		left := quota.Limit - int(given)
+		if fewest == -1 || left < fewest {
+			fewest = left
+			message = fmt.Sprintf("You have %s of your %s shout outs left.", describeLeft(left), quota)
+		}
+	}
+
+	points, err := remainingPoints(ws, fromUserID)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(message + " " + points), nil
+}
+
+func describeLeft(left int) string {
+	if left <= 0 {
+		return "none"
+	}
+
+	return strconv.Itoa(left)
+}
+
+// handleQuotasCommand shows admins the quotas and who's closest to them.
+func handleQuotasCommand(ws *Workspace) (interface{}, error) {
+	if len(ws.Config.GiverQuotas) == 0 && len(ws.Config.PairQuotas) == 0 {
+		return commandText("No giving quotas are set, set TROUT_GIVING_QUOTA or TROUT_PAIR_QUOTA to add some."), nil
+	}
+
+	now := time.Now()
+	lines := []string{}
+	for _, quota := range ws.Config.GiverQuotas {
+		lines = append(lines, fmt.Sprintf("*%s shout outs per person*", quota))
+
+		counts, err := ws.Store.GetGivingCounts(ws.TeamID, quota.since(now), leaderboardSize)
+		if err != nil {
+			return nil, err
+		}
+		if len(counts) == 0 {
+			lines = append(lines, "_Nobody's given any in the past "+quota.Period+"._")
+		}
+		for i, count := range counts {
+			lines = append(lines, fmt.Sprintf("%d. %s — %d of %d", i+1, parser.WrapUserIdForMention(count.UserID), count.Count, quota.Limit))
+		}
+	}
+	for _, quota := range ws.Config.PairQuotas {
+		lines = append(lines, fmt.Sprintf("*%s shout outs from one person to another*", quota))
+	}
+
+	return map[string]interface{}{"blocks": []slack.Block{statsSection(strings.Join(lines, "\n"))}}, nil
+}
@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zerodahero/trout/database"
+
+	"github.com/slack-go/slack"
+)
+
+func TestParseQuotas(t *testing.T) {
+	var tests = []struct {
+		value   string
+		want    []Quota
+		wantErr bool
+	}{
+		{"", []Quota{}, false},
+		{"10/day", []Quota{{10, "day"}}, false},
+		{"10/day, 40/week,100/month", []Quota{{10, "day"}, {40, "week"}, {100, "month"}}, false},
+		{"10", nil, true},
+		{"0/day", nil, true},
+		{"ten/day", nil, true},
+		{"10/fortnight", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			quotas, err := ParseQuotas(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(quotas, tt.want) {
+				t.Errorf("got %v, want %v", quotas, tt.want)
+			}
+		})
+	}
+}
+
+// encodePayload keeps mentions readable, json.Marshal escapes the brackets.
+func encodePayload(payload interface{}) string {
+	var encoded strings.Builder
+	encoder := json.NewEncoder(&encoded)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(payload)
+
+	return encoded.String()
+}
+
+func TestGivingQuotas(t *testing.T) {
+	var tests = []struct {
+		name      string
+		giver     []Quota
+		pair      []Quota
+		text      string
+		wantSaved int
+		wantReply string
+	}{
+		{"no quotas", nil, nil, "<@U2> <@U3> thanks", 2, "Thanks, got it!"},
+		{"within quota", []Quota{{5, "day"}}, nil, "<@U2> thanks", 1, "You have 1 of your 5 daily shout outs left."},
+		{"tightest quota shown", []Quota{{10, "day"}, {4, "week"}}, nil, "<@U2> thanks", 1, "You have none of your 4 weekly shout outs left."},
+		{"over quota", []Quota{{3, "day"}}, nil, "<@U2> thanks", 0, "You get 3 daily shout outs and have none left, so this shout out will have to wait."},
+		{"whole crew over quota", []Quota{{4, "week"}}, nil, "<@U2> <@U3> thanks", 0, "have 1 left, so this shout out to 2 people will have to wait."},
+		{"pair quota", nil, []Quota{{2, "week"}}, "<@U2> thanks", 0, "You've already given <@U2> 2 shout outs in the past week"},
+		{"pair quota for someone else", nil, []Quota{{2, "week"}}, "<@U3> thanks", 1, "Thanks, got it!"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, workspaces := setupTestSlack(t)
+			ws := testWorkspace(t, workspaces)
+			ws.Config.GiverQuotas, ws.Config.PairQuotas = tt.giver, tt.pair
+
+			// Three given already, pending and anonymous count too
+			createTestKudo(t, ws, "UGIVER", "U2", true)
+			createTestKudo(t, ws, "UGIVER", "U2", false)
+			anonymous := createTestKudo(t, ws, "UGIVER", "U4", false)
+			anonymous.IsAnonymous = true
+			err := ws.Store.SaveKudo(anonymous)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			payload, err := HandleTroutCommand(ws, slack.SlashCommand{UserID: "UGIVER", ChannelID: "C1", Text: tt.text}, prepareTestKudo)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			kudos, err := ws.Store.GetPendingKudosFrom("T1", "UGIVER", 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if saved := len(kudos) - 2; saved != tt.wantSaved {
+				t.Errorf("got %d saved, want %d", saved, tt.wantSaved)
+			}
+
+			if reply := encodePayload(payload); !strings.Contains(reply, tt.wantReply) {
+				t.Errorf("got reply %s, want it to contain %q", reply, tt.wantReply)
+			}
+		})
+	}
+}
+
+func TestSaveWithinQuotaPreparesUnlocked(t *testing.T) {
+	_, workspaces := setupTestSlack(t)
+	ws := testWorkspace(t, workspaces)
+
+	kudos, err := database.NewKudosFromText("<@U2> thanks", "T1", "UGIVER")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Preparing can wait on Slack, so the giver's lock has to be free
+	// while it does
+	prepare := func(ws *Workspace, kudo *database.Kudo) error {
+		return ws.Store.WithGiverLock(ws.TeamID, kudo.FromUserID, func(database.Store) error {
+			return nil
+		})
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := saveWithinQuota(ws, "UGIVER", kudos, prepare)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected prepare to run without the giver's lock held")
+	}
+
+	count, err := ws.Store.CountGiven("T1", "UGIVER", database.StatsFilter{}, true)
+	if err != nil || count != 1 {
+		t.Errorf("expected the kudo saved, got %d (%v)", count, err)
+	}
+}
+
+func TestQuotasCommand(t *testing.T) {
+	var tests = []struct {
+		name   string
+		userID string
+		quotas []Quota
+		want   string
+	}{
+		{"admin", "UADMIN", []Quota{{10, "day"}}, "1. <@UGIVER> — 1 of 10"},
+		{"no quotas", "UADMIN", nil, "No giving quotas are set"},
+		{"not an admin", "UGIVER", []Quota{{10, "day"}}, "only workspace admins"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, workspaces := setupTestSlack(t)
+			ws := testWorkspace(t, workspaces)
+			fake.AddUser(slack.User{ID: "UADMIN", IsAdmin: true})
+			fake.AddUser(slack.User{ID: "UGIVER"})
+			ws.Config.GiverQuotas, ws.Config.PairQuotas = tt.quotas, nil
+			createTestKudo(t, ws, "UGIVER", "U2", false)
+
+			payload, err := HandleShoutTroutCommand(ws, slack.SlashCommand{Command: "/shout-trout", Text: "quotas", TeamID: "T1", UserID: tt.userID, ChannelID: "C1"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if reply := encodePayload(payload); !strings.Contains(reply, tt.want) {
+				t.Errorf("got %s, want it to contain %q", reply, tt.want)
+			}
+		})
+	}
+}
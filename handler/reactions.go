@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/zerodahero/trout/database"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// isKudoReaction ignores skin tones, :thumbsup::skin-tone-2: is :thumbsup:.
+func (c *Config) isKudoReaction(reaction string) bool {
+	reaction = strings.SplitN(reaction, "::", 2)[0]
+	for _, name := range c.Reactions {
+		if strings.Trim(name, ":") == reaction {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HandleReactionAdded turns a reaction on a message into a shout out from the
+// reactor to the message's author, once per person and message.
+func HandleReactionAdded(ws *Workspace, ev *slackevents.ReactionAddedEvent) error {
+	if !ws.Config.isKudoReaction(ev.Reaction) || ev.Item.Type != "message" {
+		return nil
+	}
+	// Bots and yourself don't need the encouragement
+	if ev.ItemUser == "" || ev.ItemUser == ev.User || ev.ItemUser == ws.BotUserID {
+		return nil
+	}
+
+	// Saves no trip for the permalink, the store turns down any repeat that
+	// slips past
+	existing, err := ws.Store.GetReactionKudo(ws.TeamID, ev.Item.Channel, ev.Item.Timestamp, ev.User)
+	if err != nil || existing != nil {
+		return err
+	}
+
+	permalink, err := ws.API.GetPermalink(&slack.PermalinkParameters{
+		Channel: ev.Item.Channel,
+		Ts:      ev.Item.Timestamp,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get message permalink: %v", err)
+	}
+
+	kudo := database.NewKudo(ws.TeamID, ev.User, ev.ItemUser, fmt.Sprintf(":%s:", ev.Reaction))
+	kudo.ChannelID = ev.Item.Channel
+	kudo.SourceTS = ev.Item.Timestamp
+	kudo.SourcePermalink = permalink
+	kudo.Reaction = ev.Reaction
+
+	denied, err := saveWithinQuota(ws, ev.User, []*database.Kudo{kudo}, (*Workspace).prepareKudo)
+	if errors.Is(err, database.ErrDuplicateKudo) {
+		return nil
+	}
+	if err != nil || denied == "" {
+		return err
+	}
+
+	return notifyUser(ws, ev.Item.Channel, ev.User, denied)
+}
+
+// HandleReactionRemoved takes the shout out back, as long as it hasn't been
+// released yet and it came from this reaction.
+func HandleReactionRemoved(ws *Workspace, ev *slackevents.ReactionRemovedEvent) error {
+	if !ws.Config.isKudoReaction(ev.Reaction) || ev.Item.Type != "message" {
+		return nil
+	}
+
+	kudo, err := ws.Store.GetReactionKudo(ws.TeamID, ev.Item.Channel, ev.Item.Timestamp, ev.User)
+	if err != nil || kudo == nil {
+		return err
+	}
+	if kudo.Reaction != ev.Reaction || !kudo.IsPending() {
+		return nil
+	}
+
+	err = ws.Store.DeleteKudo(kudo)
+	if err != nil {
+		return fmt.Errorf("failed to delete kudo: %v", err)
+	}
+
+	return nil
+}
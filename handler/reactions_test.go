@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+func reactionItem(ts string) slackevents.Item {
+	return slackevents.Item{Type: "message", Channel: "C1", Timestamp: ts}
+}
+
+func TestHandleReactionAdded(t *testing.T) {
+	var tests = []struct {
+		name      string
+		reactions []slackevents.ReactionAddedEvent
+		wantKudos int
+	}{
+		{"fish", []slackevents.ReactionAddedEvent{
+			{User: "UGIVER", Reaction: "fish", ItemUser: "U2", Item: reactionItem("1.000100")},
+		}, 1},
+		{"skin tone", []slackevents.ReactionAddedEvent{
+			{User: "UGIVER", Reaction: "fish::skin-tone-3", ItemUser: "U2", Item: reactionItem("1.000100")},
+		}, 1},
+		{"once per message", []slackevents.ReactionAddedEvent{
+			{User: "UGIVER", Reaction: "fish", ItemUser: "U2", Item: reactionItem("1.000100")},
+			{User: "UGIVER", Reaction: "tropical_fish", ItemUser: "U2", Item: reactionItem("1.000100")},
+		}, 1},
+		{"once per message each", []slackevents.ReactionAddedEvent{
+			{User: "UGIVER", Reaction: "fish", ItemUser: "U2", Item: reactionItem("1.000100")},
+			{User: "UGIVER", Reaction: "fish", ItemUser: "U2", Item: reactionItem("2.000200")},
+		}, 2},
+		{"other emoji", []slackevents.ReactionAddedEvent{
+			{User: "UGIVER", Reaction: "tada", ItemUser: "U2", Item: reactionItem("1.000100")},
+		}, 0},
+		{"own message", []slackevents.ReactionAddedEvent{
+			{User: "UGIVER", Reaction: "fish", ItemUser: "UGIVER", Item: reactionItem("1.000100")},
+		}, 0},
+		{"bot's message", []slackevents.ReactionAddedEvent{
+			{User: "UGIVER", Reaction: "fish", ItemUser: "UBOT", Item: reactionItem("1.000100")},
+		}, 0},
+		{"file", []slackevents.ReactionAddedEvent{
+			{User: "UGIVER", Reaction: "fish", ItemUser: "U2", Item: slackevents.Item{Type: "file"}},
+		}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, workspaces := setupTestSlack(t)
+			ws := testWorkspace(t, workspaces)
+			fake.AddUser(slack.User{ID: "UGIVER"})
+			fake.AddUser(slack.User{ID: "U2"})
+			ws.Config.Reactions = []string{"fish", ":tropical_fish:"}
+
+			for _, ev := range tt.reactions {
+				ev := ev
+				err := HandleReactionAdded(ws, &ev)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			kudos, err := ws.Store.GetPendingKudosFrom("T1", "UGIVER", 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(kudos) != tt.wantKudos {
+				t.Fatalf("got %d kudos, want %d", len(kudos), tt.wantKudos)
+			}
+			for _, kudo := range kudos {
+				if kudo.ToUserID != "U2" || kudo.ChannelID != "C1" || kudo.SourcePermalink == "" {
+					t.Errorf("got kudo %+v, want one to U2 linking the message in C1", kudo)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleReactionAddedTogether(t *testing.T) {
+	fake, workspaces := setupTestSlack(t)
+	ws := testWorkspace(t, workspaces)
+	fake.AddUser(slack.User{ID: "UGIVER"})
+	fake.AddUser(slack.User{ID: "U2"})
+
+	// Slack retrying the event while the first delivery is still being
+	// handled, maybe by another replica
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := HandleReactionAdded(ws, &slackevents.ReactionAddedEvent{User: "UGIVER", Reaction: "fish", ItemUser: "U2", Item: reactionItem("1.000100")})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	kudos, err := ws.Store.GetPendingKudosFrom("T1", "UGIVER", 10)
+	if err != nil || len(kudos) != 1 {
+		t.Errorf("expected one kudo, got %d (%v)", len(kudos), err)
+	}
+}
+
+func TestHandleReactionRemoved(t *testing.T) {
+	var tests = []struct {
+		name     string
+		removed  string
+		released bool
+		wantKept bool
+	}{
+		{"taken back", "fish", false, false},
+		{"different emoji", "tropical_fish", false, true},
+		{"already released", "fish", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, workspaces := setupTestSlack(t)
+			ws := testWorkspace(t, workspaces)
+			fake.AddUser(slack.User{ID: "UGIVER"})
+			fake.AddUser(slack.User{ID: "U2"})
+			ws.Config.Reactions = []string{"fish", "tropical_fish"}
+
+			err := HandleReactionAdded(ws, &slackevents.ReactionAddedEvent{User: "UGIVER", Reaction: "fish", ItemUser: "U2", Item: reactionItem("1.000100")})
+			if err != nil {
+				t.Fatal(err)
+			}
+			kudo, err := ws.Store.GetReactionKudo("T1", "C1", "1.000100", "UGIVER")
+			if err != nil || kudo == nil {
+				t.Fatalf("expected a kudo, got %v (%v)", kudo, err)
+			}
+			if tt.released {
+				kudo.SharedAt.SetValid(time.Now())
+				err = ws.Store.SaveKudo(kudo)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err = HandleReactionRemoved(ws, &slackevents.ReactionRemovedEvent{User: "UGIVER", Reaction: tt.removed, ItemUser: "U2", Item: reactionItem("1.000100")})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			kept, err := ws.Store.GetReactionKudo("T1", "C1", "1.000100", "UGIVER")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (kept != nil) != tt.wantKept {
+				t.Errorf("got kudo %v, want kept %v", kept, tt.wantKept)
+			}
+		})
+	}
+}
@@ -0,0 +1,414 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/zerodahero/trout/database"
+	"github.com/zerodahero/trout/parser"
+
+	"github.com/slack-go/slack"
+)
+
+// releaseClaimTTL is how long a release's claim on the workspace lasts unless
+// it's renewed. A release cut short by a crash is left to whoever claims the
+// workspace after that.
+var releaseClaimTTL = time.Minute
+
+const releaseBusy = "Another release is still going out, try again once it's done."
+
+// Slack limits to ~1 post/s
+var postInterval = time.Second
+
+// No clue what the limit is for thread replies, but we will limit anyway
+var threadReplyInterval = 350 * time.Millisecond
+
+// releaser works through the deliveries of a release run. In a dry run nothing
+// is posted or stored, the posts are collected for the releaser instead.
+type releaser struct {
+	ws     *Workspace
+	run    *database.ReleaseRun
+	dryRun bool
+
+	// who to report back to, and where; empty for scheduled releases
+	notifyChannelID string
+	notifyUserID    string
+
+	// threads maps each public recipient to their thread in the channel
+	threads    map[string]string
+	transcript []string
+}
+
+// releaseKudos shares everything pending in the scope into the channel. The
+// userID is who asked for the release, or empty for a scheduled release.
+func releaseKudos(ws *Workspace, channelID, userID string, scope database.ReleaseScope, dryRun bool) error {
+	// A dry run shares nothing, so it can't get in a real release's way
+	if dryRun {
+		return startRelease(ws, channelID, userID, scope, true)
+	}
+
+	claimed, err := withReleaseClaim(ws, func() error {
+		return startRelease(ws, channelID, userID, scope, false)
+	})
+	if err != nil || claimed {
+		return err
+	}
+
+	return notifyReleaseBusy(ws, channelID, userID)
+}
+
+func startRelease(ws *Workspace, channelID, userID string, scope database.ReleaseScope, dryRun bool) error {
+	public, private, err := getUnsharedKudos(ws, scope)
+	if err != nil {
+		return err
+	}
+
+	run := database.NewReleaseRun(ws.TeamID, channelID, userID, append(public, private...))
+	if !dryRun {
+		err = ws.Store.CreateReleaseRun(run)
+		if err != nil {
+			return fmt.Errorf("failed to start release: %v", err)
+		}
+	}
+
+	err = notifyReleaseKudoCount(ws, channelID, userID, true, len(public), scope, dryRun)
+	if err != nil {
+		return err
+	}
+	err = notifyReleaseKudoCount(ws, channelID, userID, false, len(private), scope, dryRun)
+	if err != nil {
+		return err
+	}
+
+	return newReleaser(ws, run, dryRun, channelID, userID).release()
+}
+
+// resumeRelease retries whatever is left pending or failed in the run,
+// reporting back to the given user. It's false, with nothing retried, while
+// another release has the workspace. The run is loaded again once claimed,
+// another retry may have finished it in the meantime.
+func resumeRelease(ws *Workspace, runID uint, channelID, userID string) (bool, error) {
+	return withReleaseClaim(ws, func() error {
+		run, err := ws.Store.GetReleaseRun(ws.TeamID, runID)
+		if err != nil {
+			return err
+		}
+		if run == nil || run.Status == database.ReleaseRunCompleted {
+			return nil
+		}
+
+		return newReleaser(ws, run, false, channelID, userID).release()
+	})
+}
+
+// withReleaseClaim runs fn holding the workspace's release claim, renewing it
+// until fn returns. It's false, without running fn, while another release,
+// maybe on another replica, has the claim.
+func withReleaseClaim(ws *Workspace, fn func() error) (bool, error) {
+	owner, err := newRandomToken()
+	if err != nil {
+		return false, err
+	}
+
+	claimed, err := ws.Store.ClaimRelease(ws.TeamID, owner, time.Now().Add(releaseClaimTTL))
+	if err != nil {
+		return false, fmt.Errorf("failed to claim release: %v", err)
+	}
+	if !claimed {
+		return false, nil
+	}
+
+	done := make(chan struct{})
+	renewed := make(chan struct{})
+	go func() {
+		defer close(renewed)
+
+		ticker := time.NewTicker(releaseClaimTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				claimed, err := ws.Store.ClaimRelease(ws.TeamID, owner, time.Now().Add(releaseClaimTTL))
+				if err != nil {
+					fmt.Printf("failed renewing the release claim in %s: %v\n", ws.TeamID, err)
+				} else if !claimed {
+					fmt.Printf("Lost the release claim in %s, it expired before it was renewed\n", ws.TeamID)
+				}
+			}
+		}
+	}()
+
+	err = fn()
+
+	close(done)
+	<-renewed
+	unclaimErr := ws.Store.UnclaimRelease(ws.TeamID, owner)
+	if unclaimErr != nil {
+		fmt.Printf("failed giving up the release claim in %s: %v\n", ws.TeamID, unclaimErr)
+	}
+
+	return true, err
+}
+
+// notifyReleaseBusy tells whoever asked for the release to wait, scheduled
+// releases are only logged.
+func notifyReleaseBusy(ws *Workspace, channelID, userID string) error {
+	if userID == "" {
+		fmt.Printf("Skipping release into %s, another is still going out\n", channelID)
+		return nil
+	}
+
+	return notifyUser(ws, channelID, userID, releaseBusy)
+}
+
+// ResumeInterruptedReleases picks up any release cut short by a restart, in
+// every workspace. Runs in a workspace another replica is still releasing into
+// are left to it, their claim hasn't expired.
+func ResumeInterruptedReleases(workspaces *Workspaces) {
+	runs, err := workspaces.store.GetInterruptedReleaseRuns()
+	if err != nil {
+		fmt.Printf("failed loading interrupted releases: %v\n", err)
+		return
+	}
+
+	for _, run := range runs {
+		ws, err := workspaces.Get(run.TeamID)
+		if err != nil {
+			fmt.Printf("can't resume release %d: %v\n", run.ID, err)
+			continue
+		}
+
+		fmt.Printf("Resuming release %d into %s\n", run.ID, run.ChannelID)
+		claimed, err := resumeRelease(ws, run.ID, run.ChannelID, run.ReleasedBy)
+		if err != nil {
+			fmt.Printf("failed resuming release %d: %v\n", run.ID, err)
+		} else if !claimed {
+			fmt.Printf("Release %d is still going out elsewhere\n", run.ID)
+		}
+	}
+}
+
+func newReleaser(ws *Workspace, run *database.ReleaseRun, dryRun bool, notifyChannelID, notifyUserID string) *releaser {
+	r := &releaser{
+		ws:              ws,
+		run:             run,
+		dryRun:          dryRun,
+		notifyChannelID: notifyChannelID,
+		notifyUserID:    notifyUserID,
+		threads:         map[string]string{},
+	}
+
+	// Keep replies in the threads already started by an earlier attempt
+	for _, delivery := range run.Deliveries {
+		if delivery.IsPublic && delivery.Status == database.DeliveryPosted && delivery.Kudo != nil {
+			r.threads[delivery.Kudo.ToUserID] = delivery.ThreadTS
+		}
+	}
+
+	return r
+}
+
+// release delivers everything not yet posted. Failed posts are recorded and
+// reported, only a failure to store the delivery state stops the run early.
+func (r *releaser) release() error {
+	shareTime := time.Now().UTC()
+
+	postLimiter := time.NewTicker(postInterval)
+	defer postLimiter.Stop()
+	threadLimiter := time.NewTicker(threadReplyInterval)
+	defer threadLimiter.Stop()
+
+	for _, delivery := range r.run.Deliveries {
+		if delivery.Status == database.DeliveryPosted || delivery.Status == database.DeliverySkipped {
+			continue
+		}
+
+		// Shared or removed since the run was queued
+		if delivery.Kudo == nil || delivery.Kudo.SharedAt.Valid {
+			err := r.markSkipped(delivery)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		var messageTs, threadTs string
+		var err error
+		if delivery.IsPublic {
+			messageTs, threadTs, err = r.postPublic(delivery.Kudo, postLimiter, threadLimiter)
+		} else {
+			r.refreshGiver(delivery.Kudo)
+			r.wait(postLimiter)
+			messageTs, err = r.post(delivery.Kudo.ToUserID, formatReleasedKudo(r.ws, delivery.Kudo, false), "")
+		}
+
+		if err != nil {
+			err = r.markFailed(delivery, err)
+		} else {
+			err = r.markPosted(delivery, messageTs, threadTs, shareTime)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if r.dryRun {
+		return notifyDryRun(r.ws, r.notifyChannelID, r.notifyUserID, r.transcript)
+	}
+
+	r.run.Finish(time.Now().UTC())
+	err := r.ws.Store.SaveReleaseRun(r.run)
+	if err != nil {
+		return err
+	}
+
+	return r.report()
+}
+
+// refreshGiver makes sure a private shout out is signed with the giver's
+// current name, the one stored may be out of date.
+func (r *releaser) refreshGiver(kudo *database.Kudo) {
+	if kudo.IsAnonymous {
+		return
+	}
+
+	_, err := r.ws.getOrFetchUser(kudo.FromUserID)
+	if err != nil {
+		fmt.Printf("Failed to refresh giver %s: %v\n", kudo.FromUserID, err)
+	}
+}
+
+// postPublic replies in the recipient's thread, starting one if needed.
+func (r *releaser) postPublic(kudo *database.Kudo, postLimiter, threadLimiter *time.Ticker) (string, string, error) {
+	threadTs, ok := r.threads[kudo.ToUserID]
+	if !ok {
+		r.wait(postLimiter)
+		var err error
+		threadTs, err = r.post(r.run.ChannelID, parser.WrapUserIdForMention(kudo.ToUserID), "")
+		if err != nil {
+			return "", "", err
+		}
+		r.threads[kudo.ToUserID] = threadTs
+	}
+
+	r.wait(threadLimiter)
+	messageTs, err := r.post(r.run.ChannelID, formatReleasedKudo(r.ws, kudo, true), threadTs)
+
+	return messageTs, threadTs, err
+}
+
+// post sends the message and returns its timestamp, for threading replies.
+func (r *releaser) post(channelID, text, threadTs string) (string, error) {
+	if r.dryRun {
+		r.transcript = append(r.transcript, describeDryRunPost(channelID, text, threadTs))
+		return fmt.Sprintf("dryrun-%d", len(r.transcript)), nil
+	}
+
+	options := []slack.MsgOption{slack.MsgOptionText(text, false)}
+	if threadTs != "" {
+		options = append(options, slack.MsgOptionTS(threadTs))
+	}
+
+	_, ts, err := r.ws.API.PostMessage(channelID, options...)
+	return ts, err
+}
+
+func (r *releaser) markPosted(delivery *database.ReleaseDelivery, messageTs, threadTs string, t time.Time) error {
+	if r.dryRun {
+		delivery.Status = database.DeliveryPosted
+		return nil
+	}
+
+	delivery.MarkPosted(messageTs, threadTs, t)
+	return r.ws.Store.MarkDeliveryPosted(delivery)
+}
+
+func (r *releaser) markFailed(delivery *database.ReleaseDelivery, deliveryErr error) error {
+	if r.dryRun {
+		delivery.Status = database.DeliveryFailed
+		return nil
+	}
+
+	delivery.MarkFailed(deliveryErr)
+	return r.ws.Store.SaveReleaseDelivery(delivery)
+}
+
+func (r *releaser) markSkipped(delivery *database.ReleaseDelivery) error {
+	if r.dryRun {
+		delivery.Status = database.DeliverySkipped
+		return nil
+	}
+
+	delivery.MarkSkipped()
+	return r.ws.Store.SaveReleaseDelivery(delivery)
+}
+
+// wait holds off for the rate limit, there is no limit to respect in a dry run.
+func (r *releaser) wait(limiter *time.Ticker) {
+	if r.dryRun {
+		return
+	}
+
+	<-limiter.C
+}
+
+// report lets the releaser know about anything that couldn't be delivered.
+// Scheduled releases have nobody to tell, so failures are only logged.
+func (r *releaser) report() error {
+	failed := r.run.FailedDeliveries()
+	if len(failed) == 0 {
+		return nil
+	}
+
+	if r.notifyUserID == "" {
+		fmt.Printf("release %d into %s finished with %d failed deliveries\n", r.run.ID, r.run.ChannelID, len(failed))
+		return nil
+	}
+
+	return notifyReleaseFailures(r.ws, r.notifyChannelID, r.notifyUserID, failed)
+}
+
+// groupKudosByRecipient splits kudos ordered by recipient into one group per
+// recipient.
+func groupKudosByRecipient(kudos []*database.Kudo) [][]*database.Kudo {
+	groups := [][]*database.Kudo{}
+	for i, kudo := range kudos {
+		if i == 0 || kudo.ToUserID != kudos[i-1].ToUserID {
+			groups = append(groups, []*database.Kudo{})
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], kudo)
+	}
+
+	return groups
+}
+
+func formatReleasedKudo(ws *Workspace, kudo *database.Kudo, mention bool) string {
+	text := fmt.Sprintf("> %s\n - %s", kudo.Message, kudo.GetDisplayFrom(ws.Store, mention))
+	if kudo.SourcePermalink != "" {
+		text += fmt.Sprintf(" (<%s|original message>)", kudo.SourcePermalink)
+	}
+	if len(kudo.Categories) > 0 {
+		text += "\n:label: " + strings.Join(kudo.CategoryNames(), ", ")
+	}
+	if kudo.Points > 0 {
+		text += "\n:gem: +" + describePoints(kudo.Points)
+	}
+
+	return text
+}
+
+func describeDryRunPost(channelID, text, threadTs string) string {
+	text = strings.ReplaceAll(text, "\n", " ")
+
+	switch {
+	case threadTs != "":
+		return "      ↳ " + text
+	case strings.HasPrefix(channelID, "U"):
+		return "• DM to " + parser.WrapUserIdForMention(channelID) + ": " + text
+	default:
+		return "• Post in <#" + channelID + ">: " + text
+	}
+}
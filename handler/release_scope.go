@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/zerodahero/trout/database"
+	"github.com/zerodahero/trout/parser"
+)
+
+// parseReleaseScope pulls a pond ("pond:platform"), a channel or a user group
+// out of the command text, returning the rest of it.
+func parseReleaseScope(text string) (database.ReleaseScope, string) {
+	var scope database.ReleaseScope
+	rest := []string{}
+
+	for _, field := range strings.Fields(text) {
+		if pond, remaining := parser.ParsePondFromText(field); pond != "" && remaining == "" {
+			scope.Pond = pond
+			continue
+		}
+		if channels := parser.ParseChannelsFromText(field); len(channels) > 0 {
+			scope.ChannelID = channels[0]
+			continue
+		}
+		if groups := parser.ParseUserGroupsFromText(field); len(groups) > 0 {
+			scope.UserGroupID = groups[0]
+			continue
+		}
+		rest = append(rest, field)
+	}
+
+	return scope, strings.Join(rest, " ")
+}
+
+// encodeReleaseScope fits the scope into a block ID or button value, so it
+// survives the trip through the preview and password prompt.
+func encodeReleaseScope(scope database.ReleaseScope) string {
+	values := url.Values{}
+	if scope.Pond != "" {
+		values.Set("pond", scope.Pond)
+	}
+	if scope.ChannelID != "" {
+		values.Set("channel", scope.ChannelID)
+	}
+	if scope.UserGroupID != "" {
+		values.Set("group", scope.UserGroupID)
+	}
+
+	return values.Encode()
+}
+
+func decodeReleaseScope(encoded string) database.ReleaseScope {
+	values, _ := url.ParseQuery(encoded)
+
+	return database.ReleaseScope{
+		Pond:        values.Get("pond"),
+		ChannelID:   values.Get("channel"),
+		UserGroupID: values.Get("group"),
+	}
+}
+
+// describeReleaseScope reads as the end of a sentence about the release, e.g.
+// " for the platform pond".
+func describeReleaseScope(scope database.ReleaseScope) string {
+	description := ""
+	if scope.Pond != "" {
+		description += fmt.Sprintf(" for the %s pond", scope.Pond)
+	}
+	if scope.UserGroupID != "" {
+		description += " for " + parser.WrapUserGroupIdForMention(scope.UserGroupID)
+	}
+	if scope.ChannelID != "" {
+		description += " given in " + parser.WrapChannelIdForMention(scope.ChannelID)
+	}
+
+	return description
+}
+
+// releaseFilter looks up the scope's user group, if any, to filter pending
+// kudos by.
+func releaseFilter(ws *Workspace, scope database.ReleaseScope) (database.KudoFilter, error) {
+	filter := database.KudoFilter{Pond: scope.Pond, ChannelID: scope.ChannelID}
+	if scope.UserGroupID == "" {
+		return filter, nil
+	}
+
+	members, err := ws.API.GetUserGroupMembers(scope.UserGroupID)
+	if err != nil {
+		return filter, fmt.Errorf("failed to get user group members: %v", err)
+	}
+	filter.ToUserIDs = append([]string{}, members...)
+
+	return filter, nil
+}
+
+// getUnsharedKudos fetches the public and private kudos the release covers.
+func getUnsharedKudos(ws *Workspace, scope database.ReleaseScope) ([]*database.Kudo, []*database.Kudo, error) {
+	filter, err := releaseFilter(ws, scope)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	public, err := ws.Store.GetUnsharedKudos(ws.TeamID, true, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	private, err := ws.Store.GetUnsharedKudos(ws.TeamID, false, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return public, private, nil
+}
+
+// releaseActionValue adds the scope to a release preview button's value.
+func releaseActionValue(action string, scope database.ReleaseScope) string {
+	if scope.IsEmpty() {
+		return action
+	}
+
+	return action + " " + encodeReleaseScope(scope)
+}
+
+func parseReleaseActionValue(value string) (string, database.ReleaseScope) {
+	parts := strings.SplitN(value, " ", 2)
+	if len(parts) < 2 {
+		return parts[0], database.ReleaseScope{}
+	}
+
+	return parts[0], decodeReleaseScope(parts[1])
+}
@@ -0,0 +1,366 @@
+package handler
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zerodahero/trout/database"
+)
+
+// fastReleases drops the rate limits, the fake Slack doesn't need them.
+func fastReleases(t *testing.T) {
+	post, thread := postInterval, threadReplyInterval
+	postInterval, threadReplyInterval = time.Millisecond, time.Millisecond
+	t.Cleanup(func() {
+		postInterval, threadReplyInterval = post, thread
+	})
+}
+
+func createPrivateTestKudo(t *testing.T, ws *Workspace, from, to string) *database.Kudo {
+	kudo := createTestKudo(t, ws, from, to, false)
+	kudo.IsPublic = false
+
+	err := ws.Store.SaveKudo(kudo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return kudo
+}
+
+func TestReleaseKudos(t *testing.T) {
+	fastReleases(t)
+	fake, workspaces := setupTestSlack(t)
+	ws := testWorkspace(t, workspaces)
+
+	createTestKudo(t, ws, "U1", "U2", false)
+	createTestKudo(t, ws, "U3", "U2", false)
+	createTestKudo(t, ws, "U1", "U3", false)
+	createPrivateTestKudo(t, ws, "U1", "U4")
+	createTestKudo(t, ws, "U1", "U5", true)
+
+	err := releaseKudos(ws, "C1", "UADMIN", database.ReleaseScope{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	posts := fake.Calls("chat.postMessage")
+	var want = []struct {
+		channel  string
+		text     string
+		threaded bool
+	}{
+		{"C1", "<@U2>", false},
+		{"C1", "Thanks for the help!", true},
+		{"C1", "Thanks for the help!", true},
+		{"C1", "<@U3>", false},
+		{"C1", "Thanks for the help!", true},
+		{"U4", "Thanks for the help!", false},
+	}
+	if len(posts) != len(want) {
+		t.Fatalf("got %d posts, want %d", len(posts), len(want))
+	}
+	for i, post := range posts {
+		if post.Values.Get("channel") != want[i].channel {
+			t.Errorf("post %d: got channel %s, want %s", i, post.Values.Get("channel"), want[i].channel)
+		}
+		if !strings.Contains(post.Values.Get("text"), want[i].text) {
+			t.Errorf("post %d: got text %q, want it to contain %q", i, post.Values.Get("text"), want[i].text)
+		}
+		if threaded := post.Values.Get("thread_ts") != ""; threaded != want[i].threaded {
+			t.Errorf("post %d: got threaded %v, want %v", i, threaded, want[i].threaded)
+		}
+	}
+
+	// Both of U2's shout outs land in the same thread
+	if posts[1].Values.Get("thread_ts") != posts[2].Values.Get("thread_ts") {
+		t.Errorf("expected replies to share a thread, got %s and %s", posts[1].Values.Get("thread_ts"), posts[2].Values.Get("thread_ts"))
+	}
+
+	for _, public := range []bool{true, false} {
+		unshared, err := ws.Store.GetUnsharedKudos("T1", public, database.KudoFilter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(unshared) != 0 {
+			t.Errorf("expected everything shared, %d left", len(unshared))
+		}
+	}
+
+	run, err := ws.Store.GetLatestUnfinishedReleaseRun("T1")
+	if err != nil || run != nil {
+		t.Errorf("expected the run to be completed, got %v (%v)", run, err)
+	}
+}
+
+func TestReleaseKudosDryRun(t *testing.T) {
+	fastReleases(t)
+	fake, workspaces := setupTestSlack(t)
+	ws := testWorkspace(t, workspaces)
+
+	createTestKudo(t, ws, "U1", "U2", false)
+	createPrivateTestKudo(t, ws, "U1", "U3")
+
+	err := releaseKudos(ws, "C1", "UADMIN", database.ReleaseScope{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if posts := fake.Calls("chat.postMessage"); len(posts) != 0 {
+		t.Errorf("dry run posted %d messages", len(posts))
+	}
+
+	pending, err := ws.Store.GetPendingKudosFrom("T1", "U1", 10)
+	if err != nil || len(pending) != 2 {
+		t.Errorf("expected both kudos still pending, got %d (%v)", len(pending), err)
+	}
+
+	notices := fake.Calls("chat.postEphemeral")
+	if len(notices) == 0 {
+		t.Fatal("expected the dry run to be reported")
+	}
+	report := notices[len(notices)-1].Values.Get("text")
+	if !strings.Contains(report, "<#C1>") || !strings.Contains(report, "<@U3>") {
+		t.Errorf("expected the report to cover the thread and the DM, got %q", report)
+	}
+}
+
+func TestReleaseKudosRetry(t *testing.T) {
+	fastReleases(t)
+	fake, workspaces := setupTestSlack(t)
+	ws := testWorkspace(t, workspaces)
+
+	createTestKudo(t, ws, "U1", "U2", false)
+	failing := createPrivateTestKudo(t, ws, "U1", "U3")
+	fake.SetPostError("U3", "channel_not_found")
+
+	err := releaseKudos(ws, "C1", "UADMIN", database.ReleaseScope{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run, err := ws.Store.GetLatestUnfinishedReleaseRun("T1")
+	if err != nil || run == nil {
+		t.Fatalf("expected an incomplete run, got %v (%v)", run, err)
+	}
+	if run.Status != database.ReleaseRunIncomplete {
+		t.Errorf("got status %s, want %s", run.Status, database.ReleaseRunIncomplete)
+	}
+	failed := run.FailedDeliveries()
+	if len(failed) != 1 || failed[0].KudoID != failing.ID || failed[0].Error.String != "channel_not_found" {
+		t.Fatalf("expected kudo %d to fail, got %v", failing.ID, failed)
+	}
+
+	notices := fake.Calls("chat.postEphemeral")
+	if report := notices[len(notices)-1].Values.Get("text"); !strings.Contains(report, "couldn't be delivered") {
+		t.Errorf("expected the failure to be reported, got %q", report)
+	}
+
+	fake.SetPostError("U3", "")
+	posted := len(fake.Calls("chat.postMessage"))
+
+	claimed, err := resumeRelease(ws, run.ID, "C1", "UADMIN")
+	if err != nil || !claimed {
+		t.Fatalf("expected the retry to go ahead, got %v (%v)", claimed, err)
+	}
+
+	retried := fake.Calls("chat.postMessage")[posted:]
+	if len(retried) != 1 || retried[0].Values.Get("channel") != "U3" {
+		t.Errorf("expected only the failed DM to be retried, got %v", retried)
+	}
+
+	run, err = ws.Store.GetLatestUnfinishedReleaseRun("T1")
+	if err != nil || run != nil {
+		t.Errorf("expected the run to be completed, got %v (%v)", run, err)
+	}
+}
+
+func TestReleaseKudosRetryTwice(t *testing.T) {
+	fastReleases(t)
+	fake, workspaces := setupTestSlack(t)
+	ws := testWorkspace(t, workspaces)
+
+	createPrivateTestKudo(t, ws, "U1", "U3")
+	fake.SetPostError("U3", "channel_not_found")
+
+	err := releaseKudos(ws, "C1", "UADMIN", database.ReleaseScope{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.SetPostError("U3", "")
+	attempted := len(postsTo(fake, "U3"))
+
+	// Both retries find the run unfinished before either gets to release it
+	var runs []*database.ReleaseRun
+	for i := 0; i < 2; i++ {
+		run, err := ws.Store.GetLatestUnfinishedReleaseRun("T1")
+		if err != nil || run == nil {
+			t.Fatalf("expected an incomplete run, got %v (%v)", run, err)
+		}
+		runs = append(runs, run)
+	}
+
+	var wg sync.WaitGroup
+	for _, run := range runs {
+		wg.Add(1)
+		go func(run *database.ReleaseRun) {
+			defer wg.Done()
+			_, err := resumeRelease(ws, run.ID, "C1", "UADMIN")
+			if err != nil {
+				t.Error(err)
+			}
+		}(run)
+	}
+	wg.Wait()
+
+	if dms := postsTo(fake, "U3")[attempted:]; len(dms) != 1 {
+		t.Errorf("expected the DM retried once, got %v", dms)
+	}
+}
+
+func TestReleaseClaimedElsewhere(t *testing.T) {
+	fastReleases(t)
+	fake, workspaces := setupTestSlack(t)
+	ws := testWorkspace(t, workspaces)
+
+	// Another replica is part way through a release, and one it started
+	// earlier was cut short
+	claimed, err := ws.Store.ClaimRelease("T1", "other-replica", time.Now().Add(time.Minute))
+	if err != nil || !claimed {
+		t.Fatalf("expected the claim, got %v (%v)", claimed, err)
+	}
+	run := database.NewReleaseRun("T1", "C1", "", []*database.Kudo{createTestKudo(t, ws, "U1", "U2", false)})
+	err = ws.Store.CreateReleaseRun(run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	createTestKudo(t, ws, "U1", "U3", false)
+
+	err = releaseKudos(ws, "C1", "UADMIN", database.ReleaseScope{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ResumeInterruptedReleases(workspaces)
+
+	if posts := postsTo(fake, "C1"); len(posts) != 0 {
+		t.Fatalf("expected nothing released while claimed, got %v", posts)
+	}
+	notices := fake.Calls("chat.postEphemeral")
+	if len(notices) != 1 || notices[0].Values.Get("text") != releaseBusy {
+		t.Errorf("expected to be told to wait, got %v", notices)
+	}
+
+	// Once the other replica's claim lapses the cut short run is picked up
+	_, err = ws.Store.ClaimRelease("T1", "other-replica", time.Now().Add(-time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ResumeInterruptedReleases(workspaces)
+
+	if posts := postsTo(fake, "C1"); len(posts) != 2 || !strings.Contains(posts[0], "<@U2>") {
+		t.Errorf("expected the interrupted run resumed, got %v", posts)
+	}
+	claimed, err = ws.Store.ClaimRelease("T1", "next", time.Now().Add(time.Minute))
+	if err != nil || !claimed {
+		t.Errorf("expected the claim given up after resuming, got %v (%v)", claimed, err)
+	}
+}
+
+func TestParseReleaseScope(t *testing.T) {
+	var tests = []struct {
+		text     string
+		want     database.ReleaseScope
+		wantRest string
+	}{
+		{"", database.ReleaseScope{}, ""},
+		{"dryrun", database.ReleaseScope{}, "dryrun"},
+		{"pond:Platform", database.ReleaseScope{Pond: "platform"}, ""},
+		{"<#C123|general> dryrun", database.ReleaseScope{ChannelID: "C123"}, "dryrun"},
+		{"<!subteam^S123|@devs>", database.ReleaseScope{UserGroupID: "S123"}, ""},
+		{"pond:web <#C1> <!subteam^S1>", database.ReleaseScope{Pond: "web", ChannelID: "C1", UserGroupID: "S1"}, ""},
+		{"lilypond:web", database.ReleaseScope{}, "lilypond:web"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			scope, rest := parseReleaseScope(tt.text)
+			if scope != tt.want {
+				t.Errorf("got scope %+v, want %+v", scope, tt.want)
+			}
+			if rest != tt.wantRest {
+				t.Errorf("got rest %q, want %q", rest, tt.wantRest)
+			}
+
+			action, decoded := parseReleaseActionValue(releaseActionValue("release", scope))
+			if action != "release" || decoded != scope {
+				t.Errorf("round trip got %s %+v, want release %+v", action, decoded, scope)
+			}
+		})
+	}
+}
+
+func TestReleaseKudosScoped(t *testing.T) {
+	var tests = []struct {
+		name     string
+		scope    database.ReleaseScope
+		released []string
+	}{
+		{"pond", database.ReleaseScope{Pond: "web"}, []string{"U2", "U4"}},
+		{"channel", database.ReleaseScope{ChannelID: "C9"}, []string{"U3", "U4"}},
+		{"user group", database.ReleaseScope{UserGroupID: "S1"}, []string{"U2", "U3"}},
+		{"pond and channel", database.ReleaseScope{Pond: "web", ChannelID: "C9"}, []string{"U4"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fastReleases(t)
+			fake, workspaces := setupTestSlack(t)
+			ws := testWorkspace(t, workspaces)
+			fake.AddUserGroup("S1", "U2", "U3")
+
+			for i, kudo := range []*database.Kudo{
+				{Pond: "web"},
+				{ChannelID: "C9"},
+				{Pond: "web", ChannelID: "C9"},
+				{Pond: "data"},
+			} {
+				kudo.TeamID = "T1"
+				kudo.FromUserID = "U1"
+				kudo.ToUserID = []string{"U2", "U3", "U4", "U5"}[i]
+				kudo.Message = "Thanks for the help!"
+				kudo.IsPublic = true
+				err := ws.Store.SaveKudo(kudo)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := releaseKudos(ws, "C1", "UADMIN", tt.scope, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, to := range tt.released {
+				found := false
+				for _, post := range fake.Calls("chat.postMessage") {
+					if strings.Contains(post.Values.Get("text"), "<@"+to+">") {
+						found = true
+					}
+				}
+				if !found {
+					t.Errorf("expected kudos for %s to be released", to)
+				}
+			}
+
+			pending, err := ws.Store.GetUnsharedKudos("T1", true, database.KudoFilter{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(pending) != 4-len(tt.released) {
+				t.Errorf("got %d pending kudos, want %d", len(pending), 4-len(tt.released))
+			}
+		})
+	}
+}
@@ -0,0 +1,351 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zerodahero/trout/database"
+	"github.com/zerodahero/trout/parser"
+
+	"github.com/slack-go/slack"
+)
+
+// homeRewardsLimit keeps the home tab under Slack's 100 block limit.
+const homeRewardsLimit = 10
+
+const rewardsOff = "Rewards aren't set up here yet, ask an admin."
+
+const rewardsUsage = "Try `/shout-trout rewards add 50 Team lunch` or `/shout-trout rewards remove 3`."
+
+// handleRewardsCommand handles
+// "/shout-trout rewards [add <cost> <name>|remove <id>]" for admins.
+func handleRewardsCommand(ws *Workspace, cmd slack.SlashCommand, rest string) (interface{}, error) {
+	args := strings.Fields(rest)
+	if len(args) == 0 {
+		return listRewards(ws)
+	}
+
+	switch args[0] {
+	case "add":
+		if len(args) < 3 {
+			return commandText(rewardsUsage), nil
+		}
+		cost, err := strconv.Atoi(args[1])
+		if err != nil || cost < 1 {
+			return commandText("A reward needs to cost at least 1 point. " + rewardsUsage), nil
+		}
+
+		reward := database.NewReward(ws.TeamID, strings.Join(args[2:], " "), cost, cmd.UserID)
+		err = ws.Store.SaveReward(reward)
+		if err != nil {
+			return nil, fmt.Errorf("failed to store reward: %v", err)
+		}
+
+		return commandText(fmt.Sprintf("Added *%s* for %s.", reward.Name, describePoints(reward.Cost))), nil
+	case "remove":
+		if len(args) != 2 {
+			return commandText(rewardsUsage), nil
+		}
+		rewardID, _ := strconv.Atoi(args[1])
+		reward, err := ws.Store.GetReward(ws.TeamID, rewardID)
+		if err != nil {
+			return nil, err
+		}
+		if reward == nil {
+			return commandText(fmt.Sprintf("There's no reward %s. %s", args[1], rewardsUsage)), nil
+		}
+
+		err = ws.Store.DeleteReward(reward)
+		if err != nil {
+			return nil, fmt.Errorf("failed to delete reward: %v", err)
+		}
+
+		return commandText(fmt.Sprintf("Removed *%s*, redemptions already waiting can still be approved.", reward.Name)), nil
+	}
+
+	return commandText(rewardsUsage), nil
+}
+
+func listRewards(ws *Workspace) (interface{}, error) {
+	rewards, err := ws.Store.GetRewards(ws.TeamID)
+	if err != nil {
+		return nil, err
+	}
+	if len(rewards) == 0 {
+		return commandText("There are no rewards yet. " + rewardsUsage), nil
+	}
+
+	lines := []string{"*Rewards*"}
+	for _, reward := range rewards {
+		lines = append(lines, fmt.Sprintf("`%d` %s — %s", reward.ID, reward.Name, describePoints(reward.Cost)))
+	}
+	if ws.Config.RewardsChannelID == "" {
+		lines = append(lines, "_Set TROUT_REWARDS_CHANNEL so they can be redeemed._")
+	}
+
+	return map[string]interface{}{"blocks": []slack.Block{statsSection(strings.Join(lines, "\n"))}}, nil
+}
+
+// handleRedeemCommand handles "/trout redeem [reward]". Without a reward it
+// offers the whole catalog.
+func handleRedeemCommand(ws *Workspace, cmd slack.SlashCommand, args []string) (interface{}, error) {
+	if !ws.Config.rewardsEnabled() {
+		return commandText(rewardsOff), nil
+	}
+
+	rewards, err := ws.Store.GetRewards(ws.TeamID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) == 0 {
+		blocks, err := buildRewardBlocks(ws, cmd.UserID, rewards)
+		if err != nil {
+			return nil, err
+		}
+
+		return map[string]interface{}{"blocks": blocks}, nil
+	}
+
+	reward := findReward(rewards, strings.Join(args, " "))
+	if reward == nil {
+		return commandText(fmt.Sprintf("There's no reward called %q, try `/trout redeem` to see them all.", strings.Join(args, " "))), nil
+	}
+
+	message, err := redeem(ws, cmd.UserID, reward)
+	if err != nil {
+		return nil, err
+	}
+
+	return commandText(message), nil
+}
+
+// findReward matches by ID or by name, whatever the case.
+func findReward(rewards []*database.Reward, name string) *database.Reward {
+	for _, reward := range rewards {
+		if strconv.Itoa(int(reward.ID)) == name || strings.EqualFold(reward.Name, name) {
+			return reward
+		}
+	}
+
+	return nil
+}
+
+// buildRewardBlocks offers each reward with a button to redeem it.
+func buildRewardBlocks(ws *Workspace, userID string, rewards []*database.Reward) ([]slack.Block, error) {
+	balance, err := ws.Store.GetPointsBalance(ws.TeamID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := []slack.Block{homeSection(fmt.Sprintf("*Rewards* (you have %s)", describePoints(balance)))}
+	if len(rewards) == 0 {
+		blocks = append(blocks, homeContext("Nothing in the catalog yet, check back soon!"))
+	}
+	for _, reward := range rewards {
+		blocks = append(blocks, buildRewardBlock(reward))
+	}
+
+	return blocks, nil
+}
+
+func buildRewardBlock(reward *database.Reward) *slack.SectionBlock {
+	button := slack.NewButtonBlockElement(
+		"",
+		"redeem",
+		&slack.TextBlockObject{
+			Type: slack.PlainTextType,
+			Text: "Redeem",
+		},
+	)
+	button.Confirm = slack.NewConfirmationBlockObject(
+		&slack.TextBlockObject{Type: slack.PlainTextType, Text: "Redeem reward?"},
+		&slack.TextBlockObject{Type: slack.PlainTextType, Text: fmt.Sprintf("%s for %s, an admin will approve it.", reward.Name, describePoints(reward.Cost))},
+		&slack.TextBlockObject{Type: slack.PlainTextType, Text: "Redeem"},
+		&slack.TextBlockObject{Type: slack.PlainTextType, Text: "Not yet"},
+	)
+
+	return slack.NewSectionBlock(
+		&slack.TextBlockObject{
+			Type: slack.MarkdownType,
+			Text: fmt.Sprintf("*%s*\n%s", reward.Name, describePoints(reward.Cost)),
+		},
+		nil,
+		slack.NewAccessory(button),
+		slack.SectionBlockOptionBlockID(fmt.Sprintf("redeem-%d", reward.ID)),
+	)
+}
+
+// HandleRedeemInteraction redeems a reward from the /trout redeem reply or
+// the home tab.
+func HandleRedeemInteraction(ws *Workspace, callback slack.InteractionCallback, rewardID int) error {
+	reward, err := ws.Store.GetReward(ws.TeamID, rewardID)
+	if err != nil {
+		return err
+	}
+
+	message := "Sorry, that reward isn't in the catalog any more."
+	if reward != nil {
+		message, err = redeem(ws, callback.User.ID, reward)
+		if err != nil {
+			return err
+		}
+	}
+
+	if callback.View.Type != slack.VTHomeTab {
+		notifyResponseURL(ws, callback.ResponseURL, message)
+		return nil
+	}
+
+	err = notifyDirect(ws, callback.User.ID, message)
+	if err != nil {
+		fmt.Printf("failed posting message: %v\n", err)
+	}
+
+	page, _ := strconv.Atoi(callback.View.PrivateMetadata)
+	return publishHome(ws, callback.User.ID, page)
+}
+
+// redeem takes the reward's points from the user and asks the admins to
+// approve it. The message is for the user.
+func redeem(ws *Workspace, userID string, reward *database.Reward) (string, error) {
+	if !ws.Config.rewardsEnabled() {
+		return rewardsOff, nil
+	}
+
+	redemption := database.NewRedemption(reward, userID)
+	created, err := ws.Store.CreateRedemption(redemption)
+	if err != nil {
+		return "", fmt.Errorf("failed to store redemption: %v", err)
+	}
+	if !created {
+		balance, err := ws.Store.GetPointsBalance(ws.TeamID, userID)
+		if err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("*%s* is %s and you have %s. Keep up the great work!", reward.Name, describePoints(reward.Cost), describePoints(balance)), nil
+	}
+
+	err = requestApproval(ws, redemption)
+	if err != nil {
+		fmt.Printf("Failed to send redemption %d for approval: %v\n", redemption.ID, err)
+
+		redemption.Reject("", time.Now().UTC())
+		_, err = ws.Store.DecideRedemption(redemption)
+		if err != nil {
+			return "", fmt.Errorf("failed to refund redemption %d: %v", redemption.ID, err)
+		}
+
+		return "Sorry, I couldn't send that to the admins, your points are back. Please try again later.", nil
+	}
+
+	return fmt.Sprintf("Redeemed *%s* for %s! It's waiting for an admin to approve it, I'll let you know.", reward.Name, describePoints(redemption.Cost)), nil
+}
+
+func requestApproval(ws *Workspace, redemption *database.Redemption) error {
+	_, _, err := ws.API.PostMessage(
+		ws.Config.RewardsChannelID,
+		slack.MsgOptionText(describeRedemption(redemption), false),
+		slack.MsgOptionBlocks(buildApprovalBlocks(redemption)...),
+	)
+
+	return err
+}
+
+func buildApprovalBlocks(redemption *database.Redemption) []slack.Block {
+	approve := slack.NewButtonBlockElement(
+		"",
+		"approve",
+		&slack.TextBlockObject{
+			Type: slack.PlainTextType,
+			Text: "Approve",
+		},
+	).WithStyle(slack.StylePrimary)
+	reject := slack.NewButtonBlockElement(
+		"",
+		"reject",
+		&slack.TextBlockObject{
+			Type: slack.PlainTextType,
+			Text: "Reject",
+		},
+	).WithStyle(slack.StyleDanger)
+
+	return []slack.Block{
+		statsSection(describeRedemption(redemption)),
+		slack.NewActionBlock(fmt.Sprintf("redemption-%d", redemption.ID), approve, reject),
+	}
+}
+
+func describeRedemption(redemption *database.Redemption) string {
+	text := fmt.Sprintf("%s would like *%s* for %s.", parser.WrapUserIdForMention(redemption.UserID), redemption.Reward.Name, describePoints(redemption.Cost))
+
+	switch redemption.Status {
+	case database.RedemptionApproved:
+		text += " :white_check_mark: Approved by " + parser.WrapUserIdForMention(redemption.DecidedBy) + "."
+	case database.RedemptionRejected:
+		text += " :x: Rejected by " + parser.WrapUserIdForMention(redemption.DecidedBy) + ", the points were refunded."
+	}
+
+	return text
+}
+
+// HandleRedemptionInteraction approves or rejects a redemption from the
+// rewards channel. Anyone in the channel sees the buttons, so it checks for
+// an admin itself rather than replacing the request for everyone.
+func HandleRedemptionInteraction(ws *Workspace, a *slack.BlockAction, callback slack.InteractionCallback, redemptionID int) error {
+	admin, err := isTroutAdmin(ws, callback.User.ID)
+	if err != nil {
+		return err
+	}
+	if !admin {
+		notifyResponseURL(ws, callback.ResponseURL, "Sorry, only workspace admins can approve rewards.")
+		return nil
+	}
+
+	redemption, err := ws.Store.GetRedemption(ws.TeamID, redemptionID)
+	if err != nil {
+		return err
+	}
+	if redemption == nil {
+		return fmt.Errorf("could not find redemption %d", redemptionID)
+	}
+
+	now := time.Now().UTC()
+	switch a.Value {
+	case "approve":
+		redemption.Approve(callback.User.ID, now)
+	case "reject":
+		redemption.Reject(callback.User.ID, now)
+	default:
+		return fmt.Errorf("unknown redemption action %q", a.Value)
+	}
+
+	decided, err := ws.Store.DecideRedemption(redemption)
+	if err != nil {
+		return fmt.Errorf("failed to store redemption: %v", err)
+	}
+	if !decided {
+		// Someone beat them to it, show what they decided
+		redemption, err = ws.Store.GetRedemption(ws.TeamID, redemptionID)
+		if err != nil {
+			return err
+		}
+
+		return replaceOriginalWithText(ws, callback.ResponseURL, describeRedemption(redemption))
+	}
+
+	err = replaceOriginalWithText(ws, callback.ResponseURL, describeRedemption(redemption))
+	if err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf("Your *%s* has been approved, enjoy!", redemption.Reward.Name)
+	if redemption.Status == database.RedemptionRejected {
+		message = fmt.Sprintf("Sorry, your *%s* wasn't approved. Your %s are back.", redemption.Reward.Name, describePoints(redemption.Cost))
+	}
+
+	return notifyDirect(ws, redemption.UserID, message)
+}
@@ -0,0 +1,248 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zerodahero/trout/database"
+	"github.com/zerodahero/trout/fakeslack"
+
+	"github.com/slack-go/slack"
+)
+
+// creditTestPoints releases a shout out worth the points to the user.
+func creditTestPoints(t *testing.T, ws *Workspace, userID string, points int) {
+	kudo := database.NewKudo("T1", "UGIVER", userID, "Thanks!")
+	kudo.Points = points
+	err := ws.Store.SaveKudo(kudo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := database.NewReleaseRun("T1", "C1", "UADMIN", []*database.Kudo{kudo})
+	err = ws.Store.CreateReleaseRun(run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delivery := run.Deliveries[0]
+	delivery.MarkPosted("1.1", "1.0", time.Now())
+	err = ws.Store.MarkDeliveryPosted(delivery)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// postsTo lists the text of everything posted to the channel.
+func postsTo(fake *fakeslack.Server, channelID string) []string {
+	posts := []string{}
+	for _, call := range fake.Calls("chat.postMessage") {
+		if call.Values.Get("channel") == channelID {
+			posts = append(posts, call.Values.Get("text")+call.Values.Get("blocks"))
+		}
+	}
+
+	return posts
+}
+
+func TestRewardsCommand(t *testing.T) {
+	var tests = []struct {
+		name   string
+		userID string
+		text   string
+		want   string
+	}{
+		{"list", "UADMIN", "rewards", "`1` Trout socks — 10 points"},
+		{"add", "UADMIN", "rewards add 50 Team lunch", "Added *Team lunch* for 50 points."},
+		{"add without a name", "UADMIN", "rewards add 50", rewardsUsage},
+		{"add for nothing", "UADMIN", "rewards add 0 Team lunch", "needs to cost at least 1 point"},
+		{"remove", "UADMIN", "rewards remove 1", "Removed *Trout socks*"},
+		{"remove unknown", "UADMIN", "rewards remove 99", "There's no reward 99."},
+		{"not an admin", "UGIVER", "rewards add 50 Team lunch", "only workspace admins can change the rewards"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, workspaces := setupTestSlack(t)
+			ws := testWorkspace(t, workspaces)
+			fake.AddUser(slack.User{ID: "UADMIN", IsAdmin: true})
+			fake.AddUser(slack.User{ID: "UGIVER"})
+			ws.Config.PointsPerKudo, ws.Config.RewardsChannelID = 1, "CREWARDS"
+
+			err := ws.Store.SaveReward(database.NewReward("T1", "Trout socks", 10, "UADMIN"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			payload, err := HandleShoutTroutCommand(ws, slack.SlashCommand{Command: "/shout-trout", Text: tt.text, TeamID: "T1", UserID: tt.userID, ChannelID: "C1"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if reply := encodePayload(payload); !strings.Contains(reply, tt.want) {
+				t.Errorf("got %s, want it to contain %q", reply, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedeemCommand(t *testing.T) {
+	var tests = []struct {
+		name        string
+		channelID   string
+		postError   string
+		text        string
+		want        string
+		wantPosted  bool
+		wantBalance int
+	}{
+		{"catalog", "CREWARDS", "", "redeem", "redeem-1", false, 20},
+		{"by name", "CREWARDS", "", "redeem trout Socks", "Redeemed *Trout socks* for 10 points!", true, 10},
+		{"by ID", "CREWARDS", "", "redeem 1", "Redeemed *Trout socks*", true, 10},
+		{"too dear", "CREWARDS", "", "redeem Team lunch", "*Team lunch* is 50 points and you have 20 points.", false, 20},
+		{"unknown", "CREWARDS", "", "redeem yacht", "There's no reward called", false, 20},
+		{"rewards off", "", "", "redeem 1", rewardsOff, false, 20},
+		{"approval not sent", "CREWARDS", "not_in_channel", "redeem 1", "couldn't send that to the admins, your points are back", true, 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, workspaces := setupTestSlack(t)
+			ws := testWorkspace(t, workspaces)
+			ws.Config.PointsPerKudo, ws.Config.RewardsChannelID = 1, tt.channelID
+			fake.SetPostError(tt.channelID, tt.postError)
+
+			for _, reward := range []*database.Reward{
+				database.NewReward("T1", "Trout socks", 10, "UADMIN"),
+				database.NewReward("T1", "Team lunch", 50, "UADMIN"),
+			} {
+				err := ws.Store.SaveReward(reward)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			creditTestPoints(t, ws, "U2", 20)
+
+			payload, err := HandleTroutCommand(ws, slack.SlashCommand{UserID: "U2", ChannelID: "C1", Text: tt.text}, prepareTestKudo)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if reply := encodePayload(payload); !strings.Contains(reply, tt.want) {
+				t.Errorf("got %s, want it to contain %q", reply, tt.want)
+			}
+			if posted := len(postsTo(fake, tt.channelID)) > 0; posted != tt.wantPosted {
+				t.Errorf("got approval requested %v, want %v", posted, tt.wantPosted)
+			}
+			balance, err := ws.Store.GetPointsBalance("T1", "U2")
+			if err != nil || balance != tt.wantBalance {
+				t.Errorf("got a balance of %d (%v), want %d", balance, err, tt.wantBalance)
+			}
+		})
+	}
+}
+
+func TestRedeemFromHome(t *testing.T) {
+	fake, workspaces := setupTestSlack(t)
+	ws := testWorkspace(t, workspaces)
+	ws.Config.PointsPerKudo, ws.Config.RewardsChannelID = 1, "CREWARDS"
+
+	reward := database.NewReward("T1", "Trout socks", 10, "UADMIN")
+	err := ws.Store.SaveReward(reward)
+	if err != nil {
+		t.Fatal(err)
+	}
+	creditTestPoints(t, ws, "U2", 10)
+
+	blocks, err := BuildHomeBlocks(ws, "U2", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if home := encodePayload(blocks); !strings.Contains(home, "redeem-") || !strings.Contains(home, "Trout socks") {
+		t.Errorf("expected the home tab to offer the reward, got %s", home)
+	}
+
+	callback := blockActionCallback("U2", "", "redeem", "")
+	callback.View = slack.View{Type: slack.VTHomeTab, PrivateMetadata: "0"}
+	err = HandleRedeemInteraction(ws, callback, int(reward.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(postsTo(fake, "CREWARDS")) != 1 {
+		t.Error("expected the redemption sent for approval")
+	}
+	if dms := postsTo(fake, "U2"); len(dms) != 1 || !strings.Contains(dms[0], "Redeemed *Trout socks*") {
+		t.Errorf("expected the redeemer told in a DM, got %v", dms)
+	}
+	if len(fake.Calls("views.publish")) != 1 {
+		t.Error("expected the home tab refreshed")
+	}
+}
+
+func TestRedemptionInteraction(t *testing.T) {
+	var tests = []struct {
+		name        string
+		userID      string
+		value       string
+		decided     bool
+		wantWebhook string
+		wantDM      string
+		wantBalance int
+	}{
+		{"approve", "UADMIN", "approve", false, "Approved by <@UADMIN>", "has been approved", 0},
+		{"reject", "UADMIN", "reject", false, "Rejected by <@UADMIN>", "Your 10 points are back.", 10},
+		{"already decided", "UADMIN", "reject", true, "Approved by <@UOTHER>", "", 0},
+		{"not an admin", "U2", "approve", false, "only workspace admins can approve rewards", "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, workspaces := setupTestSlack(t)
+			ws := testWorkspace(t, workspaces)
+			fake.AddUser(slack.User{ID: "UADMIN", IsAdmin: true})
+			fake.AddUser(slack.User{ID: "U2"})
+			ws.Config.PointsPerKudo, ws.Config.RewardsChannelID = 1, "CREWARDS"
+
+			reward := database.NewReward("T1", "Trout socks", 10, "UADMIN")
+			err := ws.Store.SaveReward(reward)
+			if err != nil {
+				t.Fatal(err)
+			}
+			creditTestPoints(t, ws, "U2", 10)
+			redemption := database.NewRedemption(reward, "U2")
+			_, err = ws.Store.CreateRedemption(redemption)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.decided {
+				redemption.Approve("UOTHER", time.Now())
+				_, err = ws.Store.DecideRedemption(redemption)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			responseURL := fake.ResponseURL()
+			callback := blockActionCallback(tt.userID, "", tt.value, responseURL)
+			err = HandleRedemptionInteraction(ws, callback.ActionCallback.BlockActions[0], callback, int(redemption.ID))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Blocks escape mentions themselves
+			reply := strings.NewReplacer(`\u003c`, "<", `\u003e`, ">").Replace(encodePayload(lastWebhook(fake, responseURL)))
+			if !strings.Contains(reply, tt.wantWebhook) {
+				t.Errorf("got reply %s, want it to contain %q", reply, tt.wantWebhook)
+			}
+			dms := postsTo(fake, "U2")
+			if (tt.wantDM == "") != (len(dms) == 0) || (tt.wantDM != "" && !strings.Contains(dms[0], tt.wantDM)) {
+				t.Errorf("got DMs %v, want %q", dms, tt.wantDM)
+			}
+			balance, err := ws.Store.GetPointsBalance("T1", "U2")
+			if err != nil || balance != tt.wantBalance {
+				t.Errorf("got a balance of %d (%v), want %d", balance, err, tt.wantBalance)
+			}
+		})
+	}
+}
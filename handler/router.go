@@ -0,0 +1,269 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// Kinds of request a route can handle.
+const (
+	KindCommand        = "command"
+	KindBlockAction    = "block_action"
+	KindViewSubmission = "view_submission"
+	KindShortcut       = "shortcut"
+	KindMessageAction  = "message_action"
+	KindEvent          = "event"
+)
+
+// ErrNoRoute is returned for anything Slack sends that nothing handles.
+var ErrNoRoute = errors.New("no route")
+
+// Request is whatever Slack sent, along with the route it matched. Only the
+// fields for its kind are set.
+type Request struct {
+	Kind  string
+	Route string
+
+	// Workspace is set by the LoadWorkspace middleware
+	Workspace *Workspace
+
+	Command  slack.SlashCommand
+	Callback slack.InteractionCallback
+	Action   *slack.BlockAction
+	Event    slackevents.EventsAPIEvent
+}
+
+// UserID is who the request came from.
+func (req *Request) UserID() string {
+	switch req.Kind {
+	case KindCommand:
+		return req.Command.UserID
+	case KindEvent:
+		return eventUserID(req.Event)
+	default:
+		return req.Callback.User.ID
+	}
+}
+
+// TeamID is the workspace the request came from.
+func (req *Request) TeamID() string {
+	switch req.Kind {
+	case KindCommand:
+		return req.Command.TeamID
+	case KindEvent:
+		return req.Event.TeamID
+	default:
+		return req.Callback.Team.ID
+	}
+}
+
+func eventUserID(event slackevents.EventsAPIEvent) string {
+	switch ev := event.InnerEvent.Data.(type) {
+	case *slackevents.AppMentionEvent:
+		return ev.User
+	case *slackevents.AppHomeOpenedEvent:
+		return ev.User
+	case *slackevents.MemberJoinedChannelEvent:
+		return ev.User
+	case *slackevents.ReactionAddedEvent:
+		return ev.User
+	case *slackevents.ReactionRemovedEvent:
+		return ev.User
+	case *slack.UserChangeEvent:
+		return ev.User.ID
+	}
+
+	return ""
+}
+
+// actionSuffix is the part of the block ID after the route prefix, e.g. the
+// kudo IDs in "kudo-12_13".
+func (req *Request) actionSuffix() string {
+	parts := strings.SplitN(req.Action.BlockID, "-", 2)
+	if len(parts) < 2 {
+		return ""
+	}
+
+	return parts[1]
+}
+
+// HandlerFunc handles one request. The payload, if any, is sent back as the
+// acknowledgement, e.g. a slash command reply or a view submission response.
+type HandlerFunc func(req *Request) (interface{}, error)
+
+// Middleware wraps a handler, to run code around every route it's used on.
+type Middleware func(next HandlerFunc) HandlerFunc
+
+type actionRoute struct {
+	pattern string
+	handler HandlerFunc
+}
+
+// Router sends each request from Slack to the handler registered for it.
+type Router struct {
+	middleware []Middleware
+
+	commands         map[string]HandlerFunc
+	actions          []actionRoute
+	viewSubmissions  map[string]HandlerFunc
+	shortcuts        map[string]HandlerFunc
+	messageShortcuts map[string]HandlerFunc
+	events           map[string]HandlerFunc
+}
+
+func NewRouter() *Router {
+	return &Router{
+		commands:         map[string]HandlerFunc{},
+		viewSubmissions:  map[string]HandlerFunc{},
+		shortcuts:        map[string]HandlerFunc{},
+		messageShortcuts: map[string]HandlerFunc{},
+		events:           map[string]HandlerFunc{},
+	}
+}
+
+// Use adds middleware to every route, including those already registered.
+// The first middleware added runs first.
+func (r *Router) Use(middleware ...Middleware) {
+	r.middleware = append(r.middleware, middleware...)
+}
+
+// wrap applies route middleware, then router middleware outside of it.
+func (r *Router) wrap(handler HandlerFunc, middleware []Middleware) HandlerFunc {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+
+	return handler
+}
+
+// Command routes a slash command by name, e.g. "/trout".
+func (r *Router) Command(name string, handler HandlerFunc, middleware ...Middleware) {
+	r.commands[name] = r.wrap(handler, middleware)
+}
+
+// BlockAction routes button presses and inputs by block ID pattern, e.g.
+// "kudo-*", using path.Match. Patterns are tried in the order registered.
+func (r *Router) BlockAction(pattern string, handler HandlerFunc, middleware ...Middleware) {
+	r.actions = append(r.actions, actionRoute{pattern, r.wrap(handler, middleware)})
+}
+
+// ViewSubmission routes modal submissions by the view's callback ID.
+func (r *Router) ViewSubmission(callbackID string, handler HandlerFunc, middleware ...Middleware) {
+	r.viewSubmissions[callbackID] = r.wrap(handler, middleware)
+}
+
+// Shortcut routes global shortcuts by callback ID.
+func (r *Router) Shortcut(callbackID string, handler HandlerFunc, middleware ...Middleware) {
+	r.shortcuts[callbackID] = r.wrap(handler, middleware)
+}
+
+// MessageShortcut routes message actions by callback ID.
+func (r *Router) MessageShortcut(callbackID string, handler HandlerFunc, middleware ...Middleware) {
+	r.messageShortcuts[callbackID] = r.wrap(handler, middleware)
+}
+
+// Event routes Events API callbacks by inner event type, e.g. "app_mention".
+func (r *Router) Event(eventType string, handler HandlerFunc, middleware ...Middleware) {
+	r.events[eventType] = r.wrap(handler, middleware)
+}
+
+// serve runs the request through the router middleware and its handler.
+func (r *Router) serve(handler HandlerFunc, req *Request) (interface{}, error) {
+	return r.wrap(handler, r.middleware)(req)
+}
+
+func (r *Router) HandleCommand(cmd slack.SlashCommand) (interface{}, error) {
+	handler, ok := r.commands[cmd.Command]
+	if !ok {
+		return nil, fmt.Errorf("%w for command %s", ErrNoRoute, cmd.Command)
+	}
+
+	return r.serve(handler, &Request{Kind: KindCommand, Route: cmd.Command, Command: cmd})
+}
+
+// HandleInteraction routes every kind of interaction callback. Each action in
+// a block actions callback is routed on its own, errors are collected so one
+// failure doesn't stop the rest.
+func (r *Router) HandleInteraction(callback slack.InteractionCallback) (interface{}, error) {
+	switch callback.Type {
+	case slack.InteractionTypeBlockActions:
+		errs := []error{}
+		for _, action := range callback.ActionCallback.BlockActions {
+			_, err := r.handleBlockAction(callback, action)
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		return nil, joinErrors(errs)
+	case slack.InteractionTypeViewSubmission:
+		return r.route(r.viewSubmissions, KindViewSubmission, callback.View.CallbackID, callback)
+	case slack.InteractionTypeShortcut:
+		return r.route(r.shortcuts, KindShortcut, callback.CallbackID, callback)
+	case slack.InteractionTypeMessageAction:
+		return r.route(r.messageShortcuts, KindMessageAction, callback.CallbackID, callback)
+	}
+
+	return nil, fmt.Errorf("%w for interaction type %s", ErrNoRoute, callback.Type)
+}
+
+func (r *Router) route(routes map[string]HandlerFunc, kind, callbackID string, callback slack.InteractionCallback) (interface{}, error) {
+	handler, ok := routes[callbackID]
+	if !ok {
+		return nil, fmt.Errorf("%w for %s %s", ErrNoRoute, kind, callbackID)
+	}
+
+	return r.serve(handler, &Request{Kind: kind, Route: callbackID, Callback: callback})
+}
+
+func (r *Router) handleBlockAction(callback slack.InteractionCallback, action *slack.BlockAction) (interface{}, error) {
+	for _, route := range r.actions {
+		matched, err := path.Match(route.pattern, action.BlockID)
+		if err != nil || !matched {
+			continue
+		}
+
+		return r.serve(route.handler, &Request{Kind: KindBlockAction, Route: route.pattern, Callback: callback, Action: action})
+	}
+
+	return nil, fmt.Errorf("%w for block %s", ErrNoRoute, action.BlockID)
+}
+
+// HandleEvent routes Events API callbacks. There's nothing to reply with, the
+// event has already been acknowledged.
+func (r *Router) HandleEvent(event slackevents.EventsAPIEvent) error {
+	if event.Type != slackevents.CallbackEvent {
+		return fmt.Errorf("%w for events API type %s", ErrNoRoute, event.Type)
+	}
+
+	handler, ok := r.events[event.InnerEvent.Type]
+	if !ok {
+		return fmt.Errorf("%w for event %s", ErrNoRoute, event.InnerEvent.Type)
+	}
+
+	_, err := r.serve(handler, &Request{Kind: KindEvent, Route: event.InnerEvent.Type, Event: event})
+	return err
+}
+
+// joinErrors keeps a lone error as is, so it can still be checked with
+// errors.Is, and otherwise lists them all.
+func joinErrors(errs []error) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+
+	return errors.New(strings.Join(messages, "; "))
+}
@@ -0,0 +1,337 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/zerodahero/trout/database"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+func blockActionCallback(userID, blockID, value, responseURL string) slack.InteractionCallback {
+	return slack.InteractionCallback{
+		Type:        slack.InteractionTypeBlockActions,
+		ResponseURL: responseURL,
+		User:        slack.User{ID: userID},
+		Team:        slack.Team{ID: "T1"},
+		Channel:     slack.Channel{GroupConversation: slack.GroupConversation{Conversation: slack.Conversation{ID: "C1"}}},
+		ActionCallback: slack.ActionCallbacks{
+			BlockActions: []*slack.BlockAction{{BlockID: blockID, Value: value}},
+		},
+	}
+}
+
+func callbackEvent(eventType string, data interface{}) slackevents.EventsAPIEvent {
+	return slackevents.EventsAPIEvent{
+		Type:       slackevents.CallbackEvent,
+		TeamID:     "T1",
+		InnerEvent: slackevents.EventsAPIInnerEvent{Type: eventType, Data: data},
+	}
+}
+
+func TestRouterDispatch(t *testing.T) {
+	r := NewRouter()
+	var hit string
+	route := func(name string) HandlerFunc {
+		return func(req *Request) (interface{}, error) {
+			hit = name + " " + req.Route
+			return name, nil
+		}
+	}
+	r.Command("/trout", route("command"))
+	r.BlockAction("kudo-*", route("kudo"))
+	r.BlockAction("release-preview", route("preview"))
+	r.ViewSubmission("kudo-edit", route("view"))
+	r.Shortcut("trout-compose", route("shortcut"))
+	r.MessageShortcut("trout-from-message", route("message"))
+	r.Event("app_mention", route("event"))
+
+	var tests = []struct {
+		name     string
+		dispatch func() (interface{}, error)
+		wantHit  string
+	}{
+		{"command", func() (interface{}, error) {
+			return r.HandleCommand(slack.SlashCommand{Command: "/trout"})
+		}, "command /trout"},
+		{"block action by pattern", func() (interface{}, error) {
+			return r.HandleInteraction(blockActionCallback("U1", "kudo-12_13", "private", ""))
+		}, "kudo kudo-*"},
+		{"block action by exact ID", func() (interface{}, error) {
+			return r.HandleInteraction(blockActionCallback("U1", "release-preview", "cancel", ""))
+		}, "preview release-preview"},
+		{"view submission", func() (interface{}, error) {
+			return r.HandleInteraction(slack.InteractionCallback{Type: slack.InteractionTypeViewSubmission, View: slack.View{CallbackID: "kudo-edit"}})
+		}, "view kudo-edit"},
+		{"shortcut", func() (interface{}, error) {
+			return r.HandleInteraction(slack.InteractionCallback{Type: slack.InteractionTypeShortcut, CallbackID: "trout-compose"})
+		}, "shortcut trout-compose"},
+		{"message shortcut", func() (interface{}, error) {
+			return r.HandleInteraction(slack.InteractionCallback{Type: slack.InteractionTypeMessageAction, CallbackID: "trout-from-message"})
+		}, "message trout-from-message"},
+		{"event", func() (interface{}, error) {
+			return nil, r.HandleEvent(callbackEvent("app_mention", &slackevents.AppMentionEvent{}))
+		}, "event app_mention"},
+		{"unknown command", func() (interface{}, error) {
+			return r.HandleCommand(slack.SlashCommand{Command: "/salmon"})
+		}, ""},
+		{"unknown block", func() (interface{}, error) {
+			return r.HandleInteraction(blockActionCallback("U1", "kudos-1", "", ""))
+		}, ""},
+		{"shortcut registered as a message shortcut", func() (interface{}, error) {
+			return r.HandleInteraction(slack.InteractionCallback{Type: slack.InteractionTypeShortcut, CallbackID: "trout-from-message"})
+		}, ""},
+		{"unknown event", func() (interface{}, error) {
+			return nil, r.HandleEvent(callbackEvent("reaction_added", &slackevents.ReactionAddedEvent{}))
+		}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hit = ""
+			_, err := tt.dispatch()
+			if hit != tt.wantHit {
+				t.Errorf("got route %q, want %q", hit, tt.wantHit)
+			}
+			if tt.wantHit == "" && !errors.Is(err, ErrNoRoute) {
+				t.Errorf("got error %v, want %v", err, ErrNoRoute)
+			}
+			if tt.wantHit != "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestRouterCommandPayload(t *testing.T) {
+	r := NewRouter()
+	r.Command("/trout", func(req *Request) (interface{}, error) {
+		return commandText("hello " + req.UserID()), nil
+	})
+
+	payload, err := r.HandleCommand(slack.SlashCommand{Command: "/trout", UserID: "U1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(payload, commandText("hello U1")) {
+		t.Errorf("got payload %v", payload)
+	}
+}
+
+func TestRouterMiddlewareOrder(t *testing.T) {
+	calls := []string{}
+	record := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(req *Request) (interface{}, error) {
+				calls = append(calls, name)
+				return next(req)
+			}
+		}
+	}
+
+	r := NewRouter()
+	r.Use(record("first"))
+	r.Command("/trout", func(req *Request) (interface{}, error) {
+		calls = append(calls, "handler")
+		return nil, nil
+	}, record("route"))
+	// Router middleware applies to routes registered before it was added
+	r.Use(record("second"))
+
+	_, err := r.HandleCommand(slack.SlashCommand{Command: "/trout"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"first", "second", "route", "handler"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("got %v, want %v", calls, want)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	r := NewRouter()
+	r.Use(Recover)
+	r.BlockAction("kudo-*", func(req *Request) (interface{}, error) {
+		panic("trout overboard")
+	})
+	r.BlockAction("home-*", func(req *Request) (interface{}, error) {
+		return nil, nil
+	})
+
+	callback := blockActionCallback("U1", "kudo-1", "", "")
+	callback.ActionCallback.BlockActions = append(callback.ActionCallback.BlockActions, &slack.BlockAction{BlockID: "home-0"})
+
+	_, err := r.HandleInteraction(callback)
+	if err == nil || !strings.Contains(err.Error(), "trout overboard") {
+		t.Errorf("expected the panic as an error, got %v", err)
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	fake, workspaces := setupTestSlack(t)
+
+	allowed := false
+	r := NewRouter()
+	r.Use(LoadWorkspace(workspaces))
+	handled := 0
+	handle := func(req *Request) (interface{}, error) {
+		handled++
+		return nil, nil
+	}
+	auth := Authorize(func(req *Request) (bool, error) {
+		return allowed, nil
+	}, "Nope.")
+	r.Command("/shout-trout", handle, auth)
+	r.BlockAction("release-preview", handle, auth)
+
+	payload, err := r.HandleCommand(slack.SlashCommand{Command: "/shout-trout", TeamID: "T1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(payload, commandText("Nope.")) {
+		t.Errorf("expected the command to be refused, got %v", payload)
+	}
+
+	responseURL := fake.ResponseURL()
+	_, err = r.HandleInteraction(blockActionCallback("U1", "release-preview", "release", responseURL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply := lastWebhook(fake, responseURL)
+	encoded, _ := json.Marshal(reply)
+	if !reply.ReplaceOriginal || !strings.Contains(string(encoded), "Nope.") {
+		t.Errorf("expected the buttons to be replaced with the refusal, got %s", encoded)
+	}
+	if handled != 0 {
+		t.Errorf("expected nothing handled, got %d", handled)
+	}
+
+	allowed = true
+	_, err = r.HandleCommand(slack.SlashCommand{Command: "/shout-trout", TeamID: "T1"})
+	if err != nil || handled != 1 {
+		t.Errorf("expected the command through, got %d handled (%v)", handled, err)
+	}
+}
+
+// TestTroutRoutes sends something to each route the bot registers, and checks
+// it reached the right handler by what that handler does in Slack.
+func TestTroutRoutes(t *testing.T) {
+	var tests = []struct {
+		name        string
+		dispatch    func(r *Router, kudo *database.Kudo, responseURL string) (interface{}, error)
+		wantCall    string
+		wantWebhook string
+		wantPayload string
+	}{
+		{"/trout", func(r *Router, kudo *database.Kudo, responseURL string) (interface{}, error) {
+			return r.HandleCommand(slack.SlashCommand{Command: "/trout", TeamID: "T1", UserID: "UGIVER", ChannelID: "C1", Text: "<@U2> thanks"})
+		}, "", "", "Thanks"},
+		{"/shout-trout denied", func(r *Router, kudo *database.Kudo, responseURL string) (interface{}, error) {
+			return r.HandleCommand(slack.SlashCommand{Command: "/shout-trout", TeamID: "T1", UserID: "UGIVER", ChannelID: "C1"})
+		}, "", "", "not allowed to release"},
+		{"/shout-trout", func(r *Router, kudo *database.Kudo, responseURL string) (interface{}, error) {
+			return r.HandleCommand(slack.SlashCommand{Command: "/shout-trout", TeamID: "T1", UserID: "UADMIN", ChannelID: "C1"})
+		}, "", "", "release-preview"},
+		{"/shout-trout scoped", func(r *Router, kudo *database.Kudo, responseURL string) (interface{}, error) {
+			return r.HandleCommand(slack.SlashCommand{Command: "/shout-trout", TeamID: "T1", UserID: "UADMIN", ChannelID: "C1", Text: "pond:web"})
+		}, "", "", "for the web pond"},
+		{"kudo buttons", func(r *Router, kudo *database.Kudo, responseURL string) (interface{}, error) {
+			return r.HandleInteraction(blockActionCallback("UGIVER", kudoBlockID([]*database.Kudo{kudo}), "private", responseURL))
+		}, "", "private", ""},
+		{"password denied", func(r *Router, kudo *database.Kudo, responseURL string) (interface{}, error) {
+			return r.HandleInteraction(blockActionCallback("UGIVER", "shouttrout-1", "hunter2", responseURL))
+		}, "", "not allowed to release", ""},
+		{"password", func(r *Router, kudo *database.Kudo, responseURL string) (interface{}, error) {
+			return r.HandleInteraction(blockActionCallback("UADMIN", "shouttrout-1", "hunter2", responseURL))
+		}, "", "release-preview", ""},
+		{"password scoped", func(r *Router, kudo *database.Kudo, responseURL string) (interface{}, error) {
+			return r.HandleInteraction(blockActionCallback("UADMIN", "shouttrout-1-pond=web", "hunter2", responseURL))
+		}, "", "for the web pond", ""},
+		{"release preview denied", func(r *Router, kudo *database.Kudo, responseURL string) (interface{}, error) {
+			return r.HandleInteraction(blockActionCallback("UGIVER", "release-preview", "release", responseURL))
+		}, "", "not allowed to release", ""},
+		{"release preview", func(r *Router, kudo *database.Kudo, responseURL string) (interface{}, error) {
+			return r.HandleInteraction(blockActionCallback("UADMIN", "release-preview", "cancel", responseURL))
+		}, "", "stay in the pond", ""},
+		{"home paging", func(r *Router, kudo *database.Kudo, responseURL string) (interface{}, error) {
+			return r.HandleInteraction(blockActionCallback("U2", "home-1", "1", responseURL))
+		}, "views.publish", "", ""},
+		{"home kudo buttons", func(r *Router, kudo *database.Kudo, responseURL string) (interface{}, error) {
+			return r.HandleInteraction(blockActionCallback("UGIVER", fmt.Sprintf("homekudo-%d", kudo.ID), "private", responseURL))
+		}, "views.publish", "", ""},
+		{"redeem button", func(r *Router, kudo *database.Kudo, responseURL string) (interface{}, error) {
+			return r.HandleInteraction(blockActionCallback("U2", "redeem-999", "redeem", responseURL))
+		}, "", "isn't in the catalog any more", ""},
+		{"redemption buttons denied", func(r *Router, kudo *database.Kudo, responseURL string) (interface{}, error) {
+			return r.HandleInteraction(blockActionCallback("UGIVER", "redemption-1", "approve", responseURL))
+		}, "", "only workspace admins can approve rewards", ""},
+		{"compose shortcut", func(r *Router, kudo *database.Kudo, responseURL string) (interface{}, error) {
+			return r.HandleInteraction(slack.InteractionCallback{Team: slack.Team{ID: "T1"}, Type: slack.InteractionTypeShortcut, CallbackID: "trout-compose", User: slack.User{ID: "UGIVER"}, TriggerID: "trigger"})
+		}, "views.open", "", ""},
+		{"message shortcut", func(r *Router, kudo *database.Kudo, responseURL string) (interface{}, error) {
+			return r.HandleInteraction(slack.InteractionCallback{
+				Type:       slack.InteractionTypeMessageAction,
+				Team:       slack.Team{ID: "T1"},
+				CallbackID: "trout-from-message",
+				User:       slack.User{ID: "UGIVER"},
+				TriggerID:  "trigger",
+				Channel:    slack.Channel{GroupConversation: slack.GroupConversation{Conversation: slack.Conversation{ID: "C1"}}},
+				Message:    slack.Message{Msg: slack.Msg{User: "U2", Text: "shipped it", Timestamp: "1.000100"}},
+			})
+		}, "views.open", "", ""},
+		{"edit submission", func(r *Router, kudo *database.Kudo, responseURL string) (interface{}, error) {
+			return r.HandleInteraction(slack.InteractionCallback{Team: slack.Team{ID: "T1"}, Type: slack.InteractionTypeViewSubmission, User: slack.User{ID: "UGIVER"}, View: slack.View{CallbackID: "kudo-edit", PrivateMetadata: "{}", State: &slack.ViewState{}}})
+		}, "", "", "A shout out needs a message."},
+		{"compose submission", func(r *Router, kudo *database.Kudo, responseURL string) (interface{}, error) {
+			return r.HandleInteraction(slack.InteractionCallback{Team: slack.Team{ID: "T1"}, Type: slack.InteractionTypeViewSubmission, User: slack.User{ID: "UGIVER"}, View: slack.View{CallbackID: "kudo-compose", PrivateMetadata: "{}", State: &slack.ViewState{}}})
+		}, "", "", "A shout out needs a message."},
+		{"mention", func(r *Router, kudo *database.Kudo, responseURL string) (interface{}, error) {
+			return nil, r.HandleEvent(callbackEvent(slackevents.AppMention, &slackevents.AppMentionEvent{User: "UGIVER", Channel: "C1", Text: "<@UBOT> <@U2> thanks"}))
+		}, "chat.postEphemeral", "", ""},
+		{"home opened", func(r *Router, kudo *database.Kudo, responseURL string) (interface{}, error) {
+			return nil, r.HandleEvent(callbackEvent(slackevents.AppHomeOpened, &slackevents.AppHomeOpenedEvent{User: "U2", Tab: "home"}))
+		}, "views.publish", "", ""},
+		{"member joined", func(r *Router, kudo *database.Kudo, responseURL string) (interface{}, error) {
+			return nil, r.HandleEvent(callbackEvent(slackevents.MemberJoinedChannel, &slackevents.MemberJoinedChannelEvent{User: "U2", Channel: "C1"}))
+		}, "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, workspaces := setupTestSlack(t)
+			ws := testWorkspace(t, workspaces)
+			fake.AddUser(slack.User{ID: "UGIVER", Profile: slack.UserProfile{DisplayName: "giver"}})
+			fake.AddUser(slack.User{ID: "U2", Profile: slack.UserProfile{DisplayName: "nemo"}})
+			fake.AddUser(slack.User{ID: "UADMIN", IsAdmin: true})
+
+			kudo := createTestKudo(t, ws, "UGIVER", "U2", false)
+			responseURL := fake.ResponseURL()
+
+			payload, err := tt.dispatch(NewTroutRouter(workspaces), kudo, responseURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.wantCall != "" && len(fake.Calls(tt.wantCall)) == 0 {
+				t.Errorf("expected a call to %s", tt.wantCall)
+			}
+			if tt.wantWebhook != "" {
+				reply, _ := json.Marshal(lastWebhook(fake, responseURL))
+				if !strings.Contains(string(reply), tt.wantWebhook) {
+					t.Errorf("got reply %s, want it to contain %q", reply, tt.wantWebhook)
+				}
+			}
+			encoded, _ := json.Marshal(payload)
+			if tt.wantPayload != "" && !strings.Contains(string(encoded), tt.wantPayload) {
+				t.Errorf("got payload %s, want it to contain %q", encoded, tt.wantPayload)
+			}
+		})
+	}
+}
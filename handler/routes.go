@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/zerodahero/trout/database"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// NewTroutRouter registers everything the bot handles. New commands, buttons
+// and events get a route here.
+func NewTroutRouter(workspaces *Workspaces) *Router {
+	r := NewRouter()
+	r.Use(Logging, Recover, LoadWorkspace(workspaces))
+
+	r.Command("/trout", func(req *Request) (interface{}, error) {
+		return HandleTroutCommand(req.Workspace, req.Command, (*Workspace).prepareKudo)
+	})
+	r.Command("/shout-trout", func(req *Request) (interface{}, error) {
+		return HandleShoutTroutCommand(req.Workspace, req.Command)
+	})
+
+	// See https://api.slack.com/apis/connections/socket-implement#button
+	r.BlockAction("kudo-*", func(req *Request) (interface{}, error) {
+		kudoIDs, err := ParseKudoBlockIDs(req.actionSuffix())
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, HandleTroutInteraction(req.Workspace, req.Action, req.Callback, kudoIDs)
+	})
+	r.BlockAction("shouttrout-*", func(req *Request) (interface{}, error) {
+		// The attempt, then the scope if the release is limited to one
+		parts := strings.SplitN(req.actionSuffix(), "-", 2)
+		attempt, _ := strconv.Atoi(parts[0])
+		scope := database.ReleaseScope{}
+		if len(parts) == 2 {
+			scope = decodeReleaseScope(parts[1])
+		}
+
+		return nil, HandleShoutTroutInteraction(req.Workspace, req.Action, req.Callback, attempt, scope)
+	}, RequireReleasePermission)
+	r.BlockAction("release-preview", func(req *Request) (interface{}, error) {
+		return nil, HandleReleasePreviewInteraction(req.Workspace, req.Action, req.Callback)
+	}, RequireReleasePermission)
+	r.BlockAction("home-*", func(req *Request) (interface{}, error) {
+		return nil, HandleHomePageInteraction(req.Workspace, req.Action, req.Callback)
+	})
+	r.BlockAction("homekudo-*", func(req *Request) (interface{}, error) {
+		kudoID, _ := strconv.Atoi(req.actionSuffix())
+		return nil, HandleHomeKudoInteraction(req.Workspace, req.Action, req.Callback, kudoID)
+	})
+	r.BlockAction("redeem-*", func(req *Request) (interface{}, error) {
+		rewardID, _ := strconv.Atoi(req.actionSuffix())
+		return nil, HandleRedeemInteraction(req.Workspace, req.Callback, rewardID)
+	})
+	r.BlockAction("redemption-*", func(req *Request) (interface{}, error) {
+		redemptionID, _ := strconv.Atoi(req.actionSuffix())
+		return nil, HandleRedemptionInteraction(req.Workspace, req.Action, req.Callback, redemptionID)
+	})
+
+	r.Shortcut("trout-compose", func(req *Request) (interface{}, error) {
+		return nil, HandleComposeShortcut(req.Workspace, req.Callback)
+	})
+	r.MessageShortcut("trout-from-message", func(req *Request) (interface{}, error) {
+		return nil, HandleMessageShortcut(req.Workspace, req.Callback)
+	})
+
+	// See https://api.slack.com/apis/connections/socket-implement#modal
+	r.ViewSubmission("kudo-edit", func(req *Request) (interface{}, error) {
+		return HandleKudoEditSubmission(req.Workspace, req.Callback)
+	})
+	r.ViewSubmission("kudo-compose", func(req *Request) (interface{}, error) {
+		return HandleKudoComposeSubmission(req.Workspace, req.Callback, (*Workspace).prepareKudo)
+	})
+
+	r.Event(slackevents.AppMention, func(req *Request) (interface{}, error) {
+		ev, ok := req.Event.InnerEvent.Data.(*slackevents.AppMentionEvent)
+		if !ok {
+			return nil, fmt.Errorf("unexpected %s data: %T", req.Route, req.Event.InnerEvent.Data)
+		}
+
+		HandleMention(req.Workspace, ev, (*Workspace).prepareKudo)
+		return nil, nil
+	})
+	r.Event(slackevents.AppHomeOpened, func(req *Request) (interface{}, error) {
+		ev, ok := req.Event.InnerEvent.Data.(*slackevents.AppHomeOpenedEvent)
+		if !ok {
+			return nil, fmt.Errorf("unexpected %s data: %T", req.Route, req.Event.InnerEvent.Data)
+		}
+
+		HandleAppHomeOpened(req.Workspace, ev)
+		return nil, nil
+	})
+	r.Event(slackevents.MemberJoinedChannel, func(req *Request) (interface{}, error) {
+		ev, ok := req.Event.InnerEvent.Data.(*slackevents.MemberJoinedChannelEvent)
+		if ok {
+			fmt.Printf("user %q joined to channel %q\n", ev.User, ev.Channel)
+		}
+
+		return nil, nil
+	})
+
+	r.Event(slackevents.ReactionAdded, func(req *Request) (interface{}, error) {
+		ev, ok := req.Event.InnerEvent.Data.(*slackevents.ReactionAddedEvent)
+		if !ok {
+			return nil, fmt.Errorf("unexpected %s data: %T", req.Route, req.Event.InnerEvent.Data)
+		}
+
+		return nil, HandleReactionAdded(req.Workspace, ev)
+	})
+	r.Event(slackevents.ReactionRemoved, func(req *Request) (interface{}, error) {
+		ev, ok := req.Event.InnerEvent.Data.(*slackevents.ReactionRemovedEvent)
+		if !ok {
+			return nil, fmt.Errorf("unexpected %s data: %T", req.Route, req.Event.InnerEvent.Data)
+		}
+
+		return nil, HandleReactionRemoved(req.Workspace, ev)
+	})
+	r.Event("user_change", func(req *Request) (interface{}, error) {
+		ev, ok := req.Event.InnerEvent.Data.(*slack.UserChangeEvent)
+		if !ok {
+			return nil, fmt.Errorf("unexpected %s data: %T", req.Route, req.Event.InnerEvent.Data)
+		}
+
+		return nil, HandleUserChange(req.Workspace, ev)
+	})
+
+	r.Event(slackevents.AppUninstalled, func(req *Request) (interface{}, error) {
+		return nil, workspaces.uninstall(req.Workspace.TeamID)
+	})
+	r.Event(slackevents.TokensRevoked, func(req *Request) (interface{}, error) {
+		ev, ok := req.Event.InnerEvent.Data.(*slackevents.TokensRevokedEvent)
+		if !ok {
+			return nil, fmt.Errorf("unexpected %s data: %T", req.Route, req.Event.InnerEvent.Data)
+		}
+
+		// Only losing the bot token matters, user tokens aren't used
+		for _, userID := range ev.Tokens.Bot {
+			if userID == req.Workspace.BotUserID {
+				return nil, workspaces.uninstall(req.Workspace.TeamID)
+			}
+		}
+
+		return nil, nil
+	})
+
+	return r
+}
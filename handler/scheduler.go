@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/zerodahero/trout/database"
+
+	"github.com/robfig/cron/v3"
+	"github.com/slack-go/slack"
+)
+
+// RunScheduler checks for due release schedules in every workspace each
+// interval. It blocks, so run it in its own goroutine.
+func RunScheduler(workspaces *Workspaces, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	for now := range ticker.C {
+		runDueSchedules(workspaces, now)
+	}
+}
+
+func runDueSchedules(workspaces *Workspaces, now time.Time) {
+	schedules, err := workspaces.store.GetReleaseSchedules()
+	if err != nil {
+		fmt.Printf("failed loading release schedules: %v\n", err)
+		return
+	}
+
+	for _, schedule := range schedules {
+		next, err := nextScheduledRun(schedule.CronExpression, schedule.LastRunOrCreated())
+		if err != nil {
+			fmt.Printf("skipping release schedule %d: %v\n", schedule.ID, err)
+			continue
+		}
+		if next.After(now) {
+			continue
+		}
+
+		// Record the run first, a restart mid-release must not release again,
+		// and another replica may have got here first
+		claimed, err := workspaces.store.ClaimReleaseScheduleRun(schedule, now)
+		if err != nil {
+			fmt.Printf("failed marking release schedule %d as run: %v\n", schedule.ID, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		ws, err := workspaces.Get(schedule.TeamID)
+		if err != nil {
+			fmt.Printf("skipping release schedule %d: %v\n", schedule.ID, err)
+			continue
+		}
+
+		err = releaseKudos(ws, schedule.ChannelID, "", schedule.Scope, false)
+		if err != nil {
+			fmt.Printf("scheduled release into %s failed: %v\n", schedule.ChannelID, err)
+		}
+	}
+}
+
+// nextScheduledRun accepts standard 5 field cron expressions, descriptors such
+// as "@weekly" and an optional "CRON_TZ=America/Chicago" prefix.
+func nextScheduledRun(expression string, from time.Time) (time.Time, error) {
+	schedule, err := cron.ParseStandard(expression)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return schedule.Next(from), nil
+}
+
+// handleScheduleCommand sets up a recurring release into the channel, limited
+// to a pond, channel or user group when one is given along with the cron
+// expression.
+func handleScheduleCommand(ws *Workspace, cmd slack.SlashCommand, text string) (interface{}, error) {
+	scope, expression := parseReleaseScope(text)
+
+	schedule, err := ws.Store.GetReleaseScheduleForChannel(cmd.TeamID, cmd.ChannelID)
+	if err != nil {
+		return nil, err
+	}
+
+	if expression == "" {
+		if schedule == nil {
+			return commandText("No automatic release is scheduled for this channel. Try something like `/shout-trout schedule 0 16 * * FRI`."), nil
+		}
+
+		next, err := nextScheduledRun(schedule.CronExpression, time.Now())
+		if err != nil {
+			return nil, err
+		}
+
+		return commandText(fmt.Sprintf("Shout outs%s are released here on `%s`, next up %s.", describeReleaseScope(schedule.Scope), schedule.CronExpression, formatSlackDate(next))), nil
+	}
+
+	next, err := nextScheduledRun(expression, time.Now())
+	if err != nil {
+		return commandText(fmt.Sprintf("Sorry, I couldn't make sense of `%s`: %v", expression, err)), nil
+	}
+
+	// Replace rather than update, so the next run is counted from now and not
+	// from whenever the old schedule last ran
+	if schedule != nil {
+		err = ws.Store.DeleteReleaseSchedule(schedule)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	schedule = database.NewReleaseSchedule(cmd.TeamID, cmd.ChannelID, expression, cmd.UserID)
+	schedule.Scope = scope
+	err = ws.Store.SaveReleaseSchedule(schedule)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save release schedule: %v", err)
+	}
+
+	return commandText(fmt.Sprintf("Got it! Shout outs%s will be released here on `%s`, starting %s.", describeReleaseScope(scope), expression, formatSlackDate(next))), nil
+}
+
+func handleUnscheduleCommand(ws *Workspace, cmd slack.SlashCommand) (interface{}, error) {
+	schedule, err := ws.Store.GetReleaseScheduleForChannel(cmd.TeamID, cmd.ChannelID)
+	if err != nil {
+		return nil, err
+	}
+
+	if schedule == nil {
+		return commandText("There's no automatic release scheduled for this channel."), nil
+	}
+
+	err = ws.Store.DeleteReleaseSchedule(schedule)
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove release schedule: %v", err)
+	}
+
+	return commandText("Done, shout outs won't be released here automatically anymore."), nil
+}
+
+func commandText(text string) map[string]interface{} {
+	return map[string]interface{}{"text": text}
+}
+
+// formatSlackDate lets Slack render the time in the reader's own timezone.
+func formatSlackDate(t time.Time) string {
+	return fmt.Sprintf("<!date^%d^{date_short_pretty} at {time}|%s>", t.Unix(), t.Format(time.RFC1123))
+}
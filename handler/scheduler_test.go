@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zerodahero/trout/database"
+)
+
+func TestNextScheduledRun(t *testing.T) {
+	// A Friday morning
+	from := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	var tests = []struct {
+		expression string
+		want       time.Time
+		wantErr    bool
+	}{
+		{"0 16 * * FRI", time.Date(2024, 3, 1, 16, 0, 0, 0, time.UTC), false},
+		{"0 16 * * MON", time.Date(2024, 3, 4, 16, 0, 0, 0, time.UTC), false},
+		{"30 9 1 * *", time.Date(2024, 4, 1, 9, 30, 0, 0, time.UTC), false},
+		{"@daily", time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC), false},
+		{"@weekly", time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC), false},
+		{"@monthly", time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), false},
+		{"@every 90m", time.Date(2024, 3, 1, 11, 30, 0, 0, time.UTC), false},
+		{"CRON_TZ=America/New_York 0 9 * * *", time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC), false},
+		{"CRON_TZ=Asia/Tokyo 0 9 * * *", time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC), false},
+		{"", time.Time{}, true},
+		{"every friday", time.Time{}, true},
+		{"0 25 * * *", time.Time{}, true},
+		{"0 16 * * FRI *", time.Time{}, true},
+		{"@fortnightly", time.Time{}, true},
+		{"CRON_TZ=Nowhere/Special 0 9 * * *", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			next, err := nextScheduledRun(tt.expression, from)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !next.Equal(tt.want) {
+				t.Errorf("got %v, want %v", next, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunDueSchedules(t *testing.T) {
+	fastReleases(t)
+	fake, workspaces := setupTestSlack(t)
+	ws := testWorkspace(t, workspaces)
+
+	schedule := database.NewReleaseSchedule("T1", "C1", "@every 1h", "UADMIN")
+	err := ws.Store.SaveReleaseSchedule(schedule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	createTestKudo(t, ws, "U1", "U2", false)
+
+	// Not due yet
+	runDueSchedules(workspaces, time.Now().Add(30*time.Minute))
+	if posts := postsTo(fake, "C1"); len(posts) != 0 {
+		t.Fatalf("expected nothing released before the schedule is due, got %v", posts)
+	}
+
+	tick := time.Now().Add(2 * time.Hour)
+	runDueSchedules(workspaces, tick)
+	released := len(postsTo(fake, "C1"))
+	if released == 0 {
+		t.Fatal("expected the due schedule to release")
+	}
+
+	// The next tick counts from the run, so there's nothing due yet even
+	// with more shout outs waiting
+	createTestKudo(t, ws, "U1", "U3", false)
+	runDueSchedules(workspaces, tick.Add(time.Minute))
+	if posts := postsTo(fake, "C1"); len(posts) != released {
+		t.Errorf("expected one release, got %d posts after the second tick, want %d", len(posts), released)
+	}
+
+	schedules, err := ws.Store.GetReleaseSchedules()
+	if err != nil || len(schedules) != 1 || !schedules[0].LastRunAt.Valid || !schedules[0].LastRunAt.Time.Equal(tick) {
+		t.Errorf("expected the run recorded at the tick, got %v (%v)", schedules, err)
+	}
+}
@@ -1,8 +1,10 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
-	"time"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/zerodahero/trout/database"
 	"github.com/zerodahero/trout/parser"
@@ -10,9 +12,20 @@ import (
 	"github.com/slack-go/slack"
 )
 
-func BuildShoutTroutPasswordBlocks(attempt int, message string) []slack.Block {
+const releaseWarning = "This will release *all* the baby shout trouts into the wild, starting right here IN THIS CHANNEL!\n\nAre you sure you wan to do that, RIGHT NOW?"
+
+const releaseDenied = "Sorry, you're not allowed to release shout outs. Ask a workspace admin to `/shout-trout grant` you."
+
+// BuildShoutTroutPasswordBlocks asks for the release password. The block ID
+// carries the attempt and the release scope, e.g. "shouttrout-2-pond=web".
+func BuildShoutTroutPasswordBlocks(attempt int, message string, scope database.ReleaseScope) []slack.Block {
+	blockID := fmt.Sprintf("shouttrout-%d", attempt)
+	if !scope.IsEmpty() {
+		blockID += "-" + encodeReleaseScope(scope)
+	}
+
 	inputBlock := slack.NewInputBlock(
-		fmt.Sprintf("shouttrout-%d", attempt),
+		blockID,
 		&slack.TextBlockObject{
 			Type: slack.PlainTextType,
 			Text: message,
@@ -27,7 +40,7 @@ func BuildShoutTroutPasswordBlocks(attempt int, message string) []slack.Block {
 	)
 	inputBlock.DispatchAction = true
 
-	headerBlockMessage := "This will release *all* the baby shout trouts into the wild, starting right here IN THIS CHANNEL!\n\nAre you sure you wan to do that, RIGHT NOW?"
+	headerBlockMessage := releaseWarning
 	if attempt >= 3 {
 		headerBlockMessage = "Looks like you don't know the super secret password. ACCESS DENIED!"
 	}
@@ -48,112 +61,316 @@ func BuildShoutTroutPasswordBlocks(attempt int, message string) []slack.Block {
 	return blocks
 }
 
-func HandleShoutTroutCommand(cmd slack.SlashCommand) (interface{}, error) {
-	blocks := BuildShoutTroutPasswordBlocks(1, "Please enter the super secret password to continue.")
+// maxPreviewSections keeps the preview under Slack's 50 block limit, with room
+// left for the header, summary and buttons.
+const maxPreviewSections = 40
 
-	return map[string]interface{}{"blocks": blocks}, nil
-}
+// maxSectionTextLength is Slack's limit on section block text.
+const maxSectionTextLength = 3000
 
-func HandleShoutTroutInteraction(a *slack.BlockAction, callback slack.InteractionCallback, attempt int) error {
-	var blocks []slack.Block
-	if a.Value != "Here, fishy fishy fishy!" {
-		blocks = BuildShoutTroutPasswordBlocks(attempt+1, "Good try, but WRONG!")
-		slack.PostWebhook(callback.ResponseURL, &slack.WebhookMessage{Blocks: &slack.Blocks{BlockSet: blocks}, ReplaceOriginal: true})
-		return nil
+// truncateText cuts the text down to limit characters, never splitting one,
+// with an ellipsis to show something was cut.
+func truncateText(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
 	}
 
-	blocks = []slack.Block{
+	return string(runes[:limit-1]) + "…"
+}
+
+// BuildReleasePreviewBlocks shows each recipient's thread (or DM) as it would
+// be released, with buttons to release, dry run or back out.
+func BuildReleasePreviewBlocks(public, private []*database.Kudo, scope database.ReleaseScope) []slack.Block {
+	blocks := []slack.Block{
 		slack.NewHeaderBlock(
 			&slack.TextBlockObject{
 				Type: slack.PlainTextType,
-				Text: "Access Granted!",
+				Text: "Here's what's about to be released",
 			},
 		),
 	}
-	slack.PostWebhook(callback.ResponseURL, &slack.WebhookMessage{Blocks: &slack.Blocks{BlockSet: blocks}, ReplaceOriginal: true})
 
-	err := releasePublicKudos(callback.Channel.ID, callback.User.ID)
-	if err != nil {
-		return err
+	if len(public)+len(private) == 0 {
+		return append(blocks, slack.NewSectionBlock(
+			&slack.TextBlockObject{
+				Type: slack.MarkdownType,
+				Text: "Nothing to release" + describeReleaseScope(scope) + ", the pond is empty!",
+			},
+			nil,
+			nil,
+		))
+	}
+
+	blocks = append(blocks,
+		slack.NewContextBlock(
+			"",
+			&slack.TextBlockObject{
+				Type: slack.MarkdownType,
+				Text: fmt.Sprintf("%d public shout outs%s threaded right here IN THIS CHANNEL, %d private shout outs sent by DM.", len(public), describeReleaseScope(scope), len(private)),
+			},
+		),
+		slack.NewDividerBlock(),
+	)
+
+	sections := []string{}
+	for _, group := range groupKudosByRecipient(public) {
+		sections = append(sections, previewGroupText("Thread for "+parser.WrapUserIdForMention(group[0].ToUserID), group))
+	}
+	for _, group := range groupKudosByRecipient(private) {
+		sections = append(sections, previewGroupText("DM to "+parser.WrapUserIdForMention(group[0].ToUserID), group))
+	}
+
+	for i, text := range sections {
+		if i == maxPreviewSections {
+			blocks = append(blocks, slack.NewContextBlock(
+				"",
+				&slack.TextBlockObject{
+					Type: slack.MarkdownType,
+					Text: fmt.Sprintf("…and %d more recipients", len(sections)-i),
+				},
+			))
+			break
+		}
+
+		blocks = append(blocks, slack.NewSectionBlock(
+			&slack.TextBlockObject{
+				Type: slack.MarkdownType,
+				Text: text,
+			},
+			nil,
+			nil,
+		))
 	}
 
-	return releasePrivateKudos(callback.Channel.ID, callback.User.ID)
+	return append(blocks, slack.NewActionBlock(
+		"release-preview",
+		slack.NewButtonBlockElement(
+			"",
+			releaseActionValue("release", scope),
+			&slack.TextBlockObject{
+				Type: slack.PlainTextType,
+				Text: "Release the trout!",
+			},
+		).WithStyle(slack.StylePrimary),
+		slack.NewButtonBlockElement(
+			"",
+			releaseActionValue("dryrun", scope),
+			&slack.TextBlockObject{
+				Type: slack.PlainTextType,
+				Text: "Dry run",
+			},
+		),
+		slack.NewButtonBlockElement(
+			"",
+			"cancel",
+			&slack.TextBlockObject{
+				Type: slack.PlainTextType,
+				Text: "Cancel",
+			},
+		).WithStyle(slack.StyleDanger),
+	))
 }
 
-func releasePublicKudos(channelID, userID string) error {
-	kudos, err := database.GetUnsharedKudos(true)
-	if err != nil {
-		return err
+// previewGroupText lists the shout outs under the title. When they don't all
+// fit in the section, it stops after the last one that does and counts the
+// rest, only cutting into a shout out when the first is too long by itself.
+func previewGroupText(title string, kudos []*database.Kudo) string {
+	text := "*" + title + "*"
+	for i, kudo := range kudos {
+		from := parser.WrapUserIdForMention(kudo.FromUserID)
+		if kudo.IsAnonymous {
+			from = "_anonymous_"
+		}
+		line := fmt.Sprintf("\n> %s\n - %s", kudo.Message, from)
+
+		// Leave room to say how many are left if the next one doesn't fit
+		rest := ""
+		if left := len(kudos) - i - 1; left > 0 {
+			rest = fmt.Sprintf("\n…and %d more", left)
+		}
+		if utf8.RuneCountInString(text+line+rest) <= maxSectionTextLength {
+			text += line
+			continue
+		}
+
+		if i == 0 {
+			return truncateText(text+line, maxSectionTextLength-utf8.RuneCountInString(rest)) + rest
+		}
+		return text + fmt.Sprintf("\n…and %d more", len(kudos)-i)
 	}
-	err = notifyReleaseKudoCount(channelID, userID, true, len(kudos))
+
+	return text
+}
+
+func buildReleasePreview(ws *Workspace, scope database.ReleaseScope) ([]slack.Block, error) {
+	public, private, err := getUnsharedKudos(ws, scope)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	// No kudos, nothing to do
-	if len(kudos) == 0 {
-		return nil
-	}
-
-	shareTime := time.Now().UTC()
-	prevUserID := ""
-	threadTs := ""
-
-	// Slack limits to ~1 post/s
-	postLimiter := time.NewTicker(1 * time.Second).C
-	// No clue what the limit is here, but we will limit anyway
-	threadLimiter := time.NewTicker(350 * time.Millisecond).C
-	for _, kudo := range kudos {
-		if kudo.ToUserID != prevUserID {
-			// start new thread
-			<-postLimiter
-			_, threadTs, err = api.PostMessage(channelID, slack.MsgOptionText(parser.WrapUserIdForMention(kudo.ToUserID), false))
-			if err != nil {
-				return err
-			}
-		}
-		<-threadLimiter
-		prevUserID = kudo.ToUserID
-		_, _, err = api.PostMessage(channelID, slack.MsgOptionText(fmt.Sprintf("> %s\n - %s", kudo.Message, kudo.GetDisplayFrom(true)), false), slack.MsgOptionTS(threadTs))
+
+	return BuildReleasePreviewBlocks(public, private, scope), nil
+}
+
+func HandleShoutTroutCommand(ws *Workspace, cmd slack.SlashCommand) (interface{}, error) {
+	args := strings.Fields(cmd.Text)
+	subcommand, rest := "", ""
+	if len(args) > 0 {
+		subcommand = args[0]
+		rest = strings.Join(args[1:], " ")
+	}
+
+	switch subcommand {
+	case "grant", "revoke":
+		admin, err := isTroutAdmin(ws, cmd.UserID)
 		if err != nil {
-			continue
+			return nil, err
+		}
+		if !admin {
+			return commandText("Sorry, only workspace admins can hand out release permissions."), nil
 		}
 
-		err = kudo.MarkShared(shareTime)
+		if subcommand == "grant" {
+			return handleGrantCommand(ws, cmd, rest)
+		}
+		return handleRevokeCommand(ws, cmd, rest)
+	case "sync-users":
+		admin, err := isTroutAdmin(ws, cmd.UserID)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		if !admin {
+			return commandText("Sorry, only workspace admins can refresh everyone's names."), nil
+		}
+
+		return handleSyncUsersCommand(ws, cmd)
+	case "quotas":
+		admin, err := isTroutAdmin(ws, cmd.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if !admin {
+			return commandText("Sorry, only workspace admins can see everyone's quotas."), nil
+		}
+
+		return handleQuotasCommand(ws)
+	case "rewards":
+		admin, err := isTroutAdmin(ws, cmd.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if !admin {
+			return commandText("Sorry, only workspace admins can change the rewards."), nil
+		}
+
+		return handleRewardsCommand(ws, cmd, rest)
+	}
+
+	allowed, err := canRelease(ws, cmd.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return commandText(releaseDenied), nil
+	}
+
+	switch subcommand {
+	case "schedule":
+		return handleScheduleCommand(ws, cmd, rest)
+	case "unschedule":
+		return handleUnscheduleCommand(ws, cmd)
+	case "permissions":
+		return handlePermissionsCommand(ws, cmd)
+	case "retry":
+		return handleRetryCommand(ws, cmd)
+	}
+
+	// Anything else limits the release, e.g. "/shout-trout pond:web"
+	scope, _ := parseReleaseScope(cmd.Text)
+
+	if ws.Config.ReleasePassword == "" {
+		blocks, err := buildReleasePreview(ws, scope)
+		if err != nil {
+			return nil, err
 		}
+
+		return map[string]interface{}{"blocks": blocks}, nil
 	}
 
-	return nil
+	blocks := BuildShoutTroutPasswordBlocks(1, "Please enter the super secret password to continue.", scope)
+
+	return map[string]interface{}{"blocks": blocks}, nil
 }
 
-func releasePrivateKudos(channelID, userID string) error {
-	kudos, err := database.GetUnsharedKudos(false)
+func handleRetryCommand(ws *Workspace, cmd slack.SlashCommand) (interface{}, error) {
+	run, err := ws.Store.GetLatestUnfinishedReleaseRun(ws.TeamID)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if run == nil {
+		return commandText("Nothing to retry, every release made it out."), nil
+	}
+
+	// Report back to whoever retried, in the channel they retried from
+	go func() {
+		claimed, err := resumeRelease(ws, run.ID, cmd.ChannelID, cmd.UserID)
+		if err == nil && !claimed {
+			err = notifyReleaseBusy(ws, cmd.ChannelID, cmd.UserID)
+		}
+		if err != nil {
+			fmt.Printf("failed retrying release %d: %v\n", run.ID, err)
+		}
+	}()
+
+	return commandText(fmt.Sprintf("Retrying %d undelivered shout outs from release %d into <#%s>.", len(run.FailedDeliveries())+len(run.PendingDeliveries()), run.ID, run.ChannelID)), nil
+}
+
+// HandleShoutTroutInteraction checks the password, routed behind
+// RequireReleasePermission.
+func HandleShoutTroutInteraction(ws *Workspace, a *slack.BlockAction, callback slack.InteractionCallback, attempt int, scope database.ReleaseScope) error {
+	if ws.Config.ReleasePassword != "" && a.Value != ws.Config.ReleasePassword {
+		return replaceOriginal(ws, callback.ResponseURL, BuildShoutTroutPasswordBlocks(attempt+1, "Good try, but WRONG!", scope))
 	}
-	err = notifyReleaseKudoCount(channelID, userID, false, len(kudos))
+
+	blocks, err := buildReleasePreview(ws, scope)
 	if err != nil {
 		return err
 	}
-	// No kudos, nothing to do
-	if len(kudos) == 0 {
-		return nil
-	}
 
-	limiter := time.NewTicker(1 * time.Second).C
-	for _, kudo := range kudos {
-		<-limiter
-		_, _, err = api.PostMessage(kudo.ToUserID, slack.MsgOptionText(fmt.Sprintf("> %s\n - %s", kudo.Message, kudo.GetDisplayFrom(false)), false))
+	return replaceOriginal(ws, callback.ResponseURL, blocks)
+}
+
+// HandleReleasePreviewInteraction handles the preview's buttons, routed behind
+// RequireReleasePermission.
+func HandleReleasePreviewInteraction(ws *Workspace, a *slack.BlockAction, callback slack.InteractionCallback) error {
+	action, scope := parseReleaseActionValue(a.Value)
+
+	var err error
+	switch action {
+	case "cancel":
+		return replaceOriginalWithText(ws, callback.ResponseURL, "Okay, the trout stay in the pond for now.")
+	case "dryrun":
+		err = replaceOriginalWithText(ws, callback.ResponseURL, "Dry run in progress, nothing will be posted or marked as shared.")
 		if err != nil {
-			continue
+			return err
 		}
 
-		err = kudo.MarkShared(time.Now().UTC())
+		return releaseKudos(ws, callback.Channel.ID, callback.User.ID, scope, true)
+	case "release":
+		err = replaceOriginal(ws, callback.ResponseURL, []slack.Block{
+			slack.NewHeaderBlock(
+				&slack.TextBlockObject{
+					Type: slack.PlainTextType,
+					Text: "Access Granted!",
+				},
+			),
+		})
 		if err != nil {
 			return err
 		}
-	}
 
-	return nil
+		return releaseKudos(ws, callback.Channel.ID, callback.User.ID, scope, false)
+	default:
+		return errors.New("unknown action value")
+	}
 }
@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/zerodahero/trout/database"
+)
+
+func TestPreviewGroupText(t *testing.T) {
+	kudosWith := func(count int, message string) []*database.Kudo {
+		kudos := []*database.Kudo{}
+		for i := 0; i < count; i++ {
+			kudos = append(kudos, database.NewKudo("T1", "U1", "U2", message))
+		}
+		return kudos
+	}
+
+	var tests = []struct {
+		name      string
+		kudos     []*database.Kudo
+		wantLines int
+		wantMore  string
+	}{
+		{"all fit", kudosWith(3, "thanks 🐟"), 3, ""},
+		{"stops at a whole shout out", kudosWith(5, strings.Repeat("🐟", 900)), 3, "…and 2 more"},
+		{"cuts a lone long one", kudosWith(1, strings.Repeat("🐟", maxKudoMessageInput)), 1, ""},
+		{"cuts the first when it's too long", kudosWith(2, strings.Repeat("🐟", maxKudoMessageInput)), 1, "…and 1 more"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text := previewGroupText("Thread for <@U2>", tt.kudos)
+
+			if !utf8.ValidString(text) || utf8.RuneCountInString(text) > maxSectionTextLength {
+				t.Fatalf("expected at most %d whole characters, got %d", maxSectionTextLength, utf8.RuneCountInString(text))
+			}
+			if lines := strings.Count(text, "\n> "); lines != tt.wantLines {
+				t.Errorf("got %d shout outs, want %d", lines, tt.wantLines)
+			}
+			if tt.wantMore != "" && !strings.HasSuffix(text, "\n"+tt.wantMore) {
+				t.Errorf("expected it to end with %q", tt.wantMore)
+			}
+			if tt.wantMore == "" && strings.Contains(text, "more") {
+				t.Errorf("expected nothing left over, got %q", text[len(text)-20:])
+			}
+		})
+	}
+}
@@ -1,50 +1,81 @@
 package handler
 
 import (
+	"fmt"
 	"log"
 	"os"
 
+	"github.com/zerodahero/trout/database"
+
 	"github.com/slack-go/slack"
 	"github.com/slack-go/slack/socketmode"
 )
 
-var api *slack.Client
+// SlackAPI is every Slack call the bot makes. *slack.Client covers all but
+// response URLs, see slackClient.
+type SlackAPI interface {
+	AuthTest() (*slack.AuthTestResponse, error)
+	GetUserInfo(user string) (*slack.User, error)
+	GetUserGroupMembers(userGroup string) ([]string, error)
+	GetPermalink(params *slack.PermalinkParameters) (string, error)
+	PostMessage(channelID string, options ...slack.MsgOption) (string, string, error)
+	PostEphemeral(channelID, userID string, options ...slack.MsgOption) (string, error)
+	OpenView(triggerID string, view slack.ModalViewRequest) (*slack.ViewResponse, error)
+	PublishView(userID string, view slack.HomeTabViewRequest, hash string) (*slack.ViewResponse, error)
+	PostWebhook(url string, msg *slack.WebhookMessage) error
+}
+
+// slackClient adds response URLs, which aren't tied to a token, to the client.
+type slackClient struct {
+	*slack.Client
+}
+
+func (c *slackClient) PostWebhook(url string, msg *slack.WebhookMessage) error {
+	return slack.PostWebhook(url, msg)
+}
+
+// client is kept for socket mode, which needs the concrete client.
+var client *slack.Client
 
-// var botID string
-var userID string
+// apiOptions are passed on to every workspace's client.
+var apiOptions []slack.Option
 
-func InitApi(botToken, appToken string, debug bool) error {
-	api = slack.New(
-		botToken,
+// InitApi connects to Slack, with the shout outs kept in the store and trout
+// set up by the config. The bot token is optional once the app can be
+// installed over OAuth. When set, it's the workspace the bot was first set up
+// in. Options are passed on to the client, and tests use slack.OptionAPIURL
+// to point it at a fake.
+func InitApi(store database.Store, config *Config, botToken, appToken string, debug bool, options ...slack.Option) (*Workspaces, error) {
+	apiOptions = append([]slack.Option{
 		slack.OptionDebug(debug),
 		slack.OptionLog(log.New(os.Stdout, "api: ", log.Lshortfile|log.LstdFlags)),
-		slack.OptionAppLevelToken(appToken),
-	)
+	}, options...)
 
-	var err error
-	userID, _, err = getBotIDs(api)
+	client = slack.New(botToken, append([]slack.Option{slack.OptionAppLevelToken(appToken)}, apiOptions...)...)
+	workspaces := NewWorkspaces(store, config)
 
-	return err
-}
+	if botToken == "" {
+		return workspaces, nil
+	}
 
-func getBotIDs(api *slack.Client) (string, string, error) {
-	// Get the bot's user ID
-	auth, err := api.AuthTest()
+	ws, err := workspaces.connect(botToken)
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 
-	return auth.UserID, auth.BotID, nil
+	// Everything stored before workspaces were tracked came from this one
+	err = store.AdoptUnscopedRecords(ws.TeamID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to adopt records into %s: %v", ws.TeamID, err)
+	}
+
+	return workspaces, nil
 }
 
 func NewClient(debug bool) *socketmode.Client {
 	return socketmode.New(
-		api,
+		client,
 		socketmode.OptionDebug(debug),
 		socketmode.OptionLog(log.New(os.Stdout, "socketmode: ", log.Lshortfile|log.LstdFlags)),
 	)
 }
-
-func GetUserInfo(userID string) (*slack.User, error) {
-	return api.GetUserInfo(userID)
-}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"github.com/slack-go/slack/socketmode"
+)
+
+// RunSocketMode sends everything Slack delivers over socket mode through the
+// router, until the client's event channel closes.
+func RunSocketMode(client *socketmode.Client, router *Router) {
+	for evt := range client.Events {
+		switch evt.Type {
+		case socketmode.EventTypeConnecting:
+			fmt.Println("Connecting to Slack with Socket Mode...")
+		case socketmode.EventTypeConnectionError:
+			fmt.Println("Connection failed. Retrying later...")
+		case socketmode.EventTypeConnected:
+			fmt.Println("Connected to Slack with Socket Mode.")
+		case socketmode.EventTypeEventsAPI:
+			event, ok := evt.Data.(slackevents.EventsAPIEvent)
+			if !ok {
+				fmt.Printf("Ignored %+v\n", evt)
+
+				continue
+			}
+
+			client.Ack(*evt.Request)
+
+			err := router.HandleEvent(event)
+			if err != nil {
+				client.Debugf("unhandled Events API event: %v", err)
+			}
+		case socketmode.EventTypeInteractive:
+			callback, ok := evt.Data.(slack.InteractionCallback)
+			if !ok {
+				fmt.Printf("Ignored %+v\n", evt)
+
+				continue
+			}
+
+			payload, err := router.HandleInteraction(callback)
+			if err != nil {
+				client.Debugf("interaction failed: %v", err)
+			}
+
+			client.Ack(*evt.Request, payload)
+		case socketmode.EventTypeSlashCommand:
+			cmd, ok := evt.Data.(slack.SlashCommand)
+			if !ok {
+				fmt.Printf("Ignored %+v\n", evt)
+
+				continue
+			}
+
+			payload, err := router.HandleCommand(cmd)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Slash command %s failed: %v\n", cmd.Command, err)
+			}
+
+			client.Ack(*evt.Request, payload)
+		default:
+			fmt.Fprintf(os.Stderr, "Unexpected event type received: %s\n", evt.Type)
+		}
+	}
+}
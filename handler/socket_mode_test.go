@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/zerodahero/trout/database"
+	"github.com/zerodahero/trout/fakeslack"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// startTestBot runs the socket mode event loop against a fake Slack with a
+// couple of users and an empty in-memory store.
+func startTestBot(t *testing.T) (*fakeslack.Server, *Workspace) {
+	fake := fakeslack.New()
+	t.Cleanup(fake.Close)
+
+	fake.AddUser(slack.User{ID: "UGIVER", Profile: slack.UserProfile{DisplayName: "giver", RealName: "Gil Giver"}})
+	fake.AddUser(slack.User{ID: "U2", Profile: slack.UserProfile{DisplayName: "nemo", RealName: "Nemo Fish"}})
+	fake.AddUser(slack.User{ID: "U3", Profile: slack.UserProfile{RealName: "Dory Fish"}})
+
+	workspaces, err := InitApi(database.NewMemoryStore(), DefaultConfig(), "xoxb-test", "xapp-test", false, slack.OptionAPIURL(fake.APIURL()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := NewClient(false)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	go RunSocketMode(client, NewTroutRouter(workspaces))
+	go client.RunContext(ctx)
+
+	return fake, testWorkspace(t, workspaces)
+}
+
+func TestMentionEvent(t *testing.T) {
+	fake, ws := startTestBot(t)
+
+	err := fake.SendEvent(slackevents.AppMentionEvent{
+		Type:    "app_mention",
+		User:    "UGIVER",
+		Channel: "C1",
+		Text:    "<@UBOT> <@U2> and <@U3> rescued the release",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notices := fake.AwaitCalls("chat.postEphemeral", 1)
+	if len(notices) != 1 || !strings.Contains(notices[0].Values.Get("text"), "Got it!") {
+		t.Fatalf("expected an acknowledgement, got %v", notices)
+	}
+
+	kudos, err := ws.Store.GetPendingKudosFrom("T1", "UGIVER", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kudos) != 2 {
+		t.Fatalf("got %d kudos, want 2", len(kudos))
+	}
+	for _, kudo := range kudos {
+		if kudo.Message != "nemo and Dory Fish rescued the release" {
+			t.Errorf("kudo to %s: got message %q", kudo.ToUserID, kudo.Message)
+		}
+	}
+
+	for _, userID := range []string{"UGIVER", "U2", "U3"} {
+		user, err := ws.Store.GetUser("T1", userID)
+		if err != nil || user == nil {
+			t.Errorf("expected %s to be stored, got %v (%v)", userID, user, err)
+		}
+	}
+}
+
+func TestSlashCommandThenToggle(t *testing.T) {
+	fake, ws := startTestBot(t)
+
+	ack, err := fake.SendSlashCommand(slack.SlashCommand{
+		Command:   "/trout",
+		UserID:    "UGIVER",
+		ChannelID: "C1",
+		Text:      "<@U2|nemo> thanks for pairing",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var reply struct {
+		Blocks slack.Blocks `json:"blocks"`
+	}
+	err = json.Unmarshal(ack, &reply)
+	if err != nil {
+		t.Fatalf("invalid command reply %s: %v", ack, err)
+	}
+	actions, ok := reply.Blocks.BlockSet[len(reply.Blocks.BlockSet)-1].(*slack.ActionBlock)
+	if !ok {
+		t.Fatalf("expected the reply to end in buttons, got %s", ack)
+	}
+
+	responseURL := fake.ResponseURL()
+	callback := slack.InteractionCallback{
+		Type:        slack.InteractionTypeBlockActions,
+		ResponseURL: responseURL,
+		User:        slack.User{ID: "UGIVER"},
+		Channel:     slack.Channel{GroupConversation: slack.GroupConversation{Conversation: slack.Conversation{ID: "C1"}}},
+		ActionCallback: slack.ActionCallbacks{
+			BlockActions: []*slack.BlockAction{{ActionID: "private", BlockID: actions.BlockID, Value: "private"}},
+		},
+	}
+	_, err = fake.SendInteraction(callback)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replies := fake.AwaitWebhooks(responseURL, 1)
+	if len(replies) != 1 || !replies[0].ReplaceOriginal {
+		t.Fatalf("expected the buttons to be replaced, got %v", replies)
+	}
+
+	kudos, err := ws.Store.GetPendingKudosFrom("T1", "UGIVER", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kudos) != 1 || kudos[0].IsPublic {
+		t.Errorf("expected one private kudo, got %v", kudos)
+	}
+}
+
+func TestSlashCommandDenied(t *testing.T) {
+	fake, _ := startTestBot(t)
+
+	ack, err := fake.SendSlashCommand(slack.SlashCommand{
+		Command:   "/shout-trout",
+		UserID:    "UGIVER",
+		ChannelID: "C1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(ack), "not allowed to release") {
+		t.Errorf("expected the release to be refused, got %s", ack)
+	}
+	if posts := fake.Calls("chat.postMessage"); len(posts) != 0 {
+		t.Errorf("expected nothing released, got %d posts", len(posts))
+	}
+}
@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/zerodahero/trout/database"
+	"github.com/zerodahero/trout/parser"
+
+	"github.com/slack-go/slack"
+)
+
+const leaderboardSize = 5
+
+// statsWindow is a time window the stats can be limited to, e.g. "week".
+type statsWindow struct {
+	name  string
+	since time.Time
+}
+
+func parseStatsWindow(name string, now time.Time) (statsWindow, error) {
+	switch name {
+	case "week":
+		return statsWindow{"the past week", now.AddDate(0, 0, -7)}, nil
+	case "", "month":
+		return statsWindow{"the past month", now.AddDate(0, -1, 0)}, nil
+	case "quarter":
+		return statsWindow{"the past quarter", now.AddDate(0, -3, 0)}, nil
+	case "all", "all-time":
+		return statsWindow{"all time", time.Time{}}, nil
+	default:
+		return statsWindow{}, fmt.Errorf("unknown time window %q", name)
+	}
+}
+
+const statsUsage = "Try `/trout stats [week|month|quarter|all] [@someone] [#category]`."
+
+// handleStatsCommand handles
+// "/trout stats [week|month|quarter|all] [@user] [#category]".
+func handleStatsCommand(ws *Workspace, cmd slack.SlashCommand, args []string) (interface{}, error) {
+	windowName, category := "", ""
+	var userIDs []string
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "<@") {
+			mentioned, err := parser.ParseRecipientsFromText(arg)
+			if err == nil {
+				userIDs = append(userIDs, mentioned...)
+			}
+			continue
+		}
+		if strings.HasPrefix(arg, "#") {
+			category = ws.Config.findCategory(arg)
+			if category == "" {
+				return commandText(fmt.Sprintf("%s isn't one of the categories. %s", arg, statsUsage)), nil
+			}
+			continue
+		}
+		windowName = arg
+	}
+
+	window, err := parseStatsWindow(windowName, time.Now().UTC())
+	if err != nil {
+		return commandText(statsUsage), nil
+	}
+
+	blocks, err := buildStatsBlocks(ws, cmd.UserID, userIDs, window, category)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{"blocks": blocks}, nil
+}
+
+// buildStatsBlocks limits the stats to the category, unless it's empty.
+func buildStatsBlocks(ws *Workspace, callerID string, userIDs []string, window statsWindow, category string) ([]slack.Block, error) {
+	filter := database.StatsFilter{Since: window.since, Category: category}
+	title := "Trout stats for " + window.name
+	if category != "" {
+		title += " in " + category
+	}
+
+	receivers, err := ws.Store.GetTopReceivers(ws.TeamID, filter, leaderboardSize)
+	if err != nil {
+		return nil, err
+	}
+
+	givers, err := ws.Store.GetTopGivers(ws.TeamID, filter, leaderboardSize)
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := []slack.Block{
+		slack.NewHeaderBlock(
+			&slack.TextBlockObject{
+				Type: slack.PlainTextType,
+				Text: title,
+			},
+		),
+		statsSection("*Biggest catches* (shout outs received)\n" + formatLeaderboard(receivers)),
+		statsSection("*Top anglers* (shout outs given)\n" + formatLeaderboard(givers)),
+		slack.NewDividerBlock(),
+	}
+
+	for _, userID := range userIDs {
+		received, err := ws.Store.CountReceived(ws.TeamID, userID, filter)
+		if err != nil {
+			return nil, err
+		}
+		given, err := ws.Store.CountGiven(ws.TeamID, userID, filter, false)
+		if err != nil {
+			return nil, err
+		}
+
+		blocks = append(blocks, statsSection(fmt.Sprintf("%s received %d and gave %d shout outs.", parser.WrapUserIdForMention(userID), received, given)))
+	}
+
+	received, err := ws.Store.CountReceived(ws.TeamID, callerID, filter)
+	if err != nil {
+		return nil, err
+	}
+	// The caller can see everything they gave, including pending and anonymous
+	given, err := ws.Store.CountGiven(ws.TeamID, callerID, filter, true)
+	if err != nil {
+		return nil, err
+	}
+
+	blocks = append(blocks, statsSection(fmt.Sprintf("*You* received %d and gave %d shout outs.", received, given)))
+
+	return blocks, nil
+}
+
+func formatLeaderboard(counts []*database.KudoCount) string {
+	if len(counts) == 0 {
+		return "_Nothing biting yet._"
+	}
+
+	lines := make([]string, 0, len(counts))
+	for i, count := range counts {
+		lines = append(lines, fmt.Sprintf("%d. %s — %d", i+1, parser.WrapUserIdForMention(count.UserID), count.Count))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func statsSection(text string) *slack.SectionBlock {
+	return slack.NewSectionBlock(
+		&slack.TextBlockObject{
+			Type: slack.MarkdownType,
+			Text: text,
+		},
+		nil,
+		nil,
+	)
+}
@@ -3,6 +3,8 @@ package handler
 import (
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/zerodahero/trout/database"
 	"github.com/zerodahero/trout/parser"
@@ -10,8 +12,45 @@ import (
 	"github.com/slack-go/slack"
 )
 
-func BuildCommandPayloadBlocks(kudo database.Kudo, message string) []slack.Block {
-	var privateBlock, anonymousBlock slack.BlockElement
+// BuildCommandPayloadBlocks renders the toggles for a single shout out. Kudos
+// fanned out to several recipients share one set of toggles, so the first kudo
+// stands in for the state of the rest.
+func BuildCommandPayloadBlocks(kudos []*database.Kudo, message string) []slack.Block {
+	kudo := kudos[0]
+
+	privateBlock, anonymousBlock := buildKudoToggleButtons(kudo)
+
+	return []slack.Block{
+		slack.NewSectionBlock(
+			&slack.TextBlockObject{
+				Type:     slack.PlainTextType,
+				Text:     "Shout out: " + kudo.Message,
+				Verbatim: false,
+			},
+			nil,
+			nil,
+		),
+		slack.NewSectionBlock(
+			&slack.TextBlockObject{
+				Type: slack.MarkdownType,
+				Text: message,
+			},
+			nil,
+			nil,
+		),
+		slack.NewActionBlock(
+			kudoBlockID(kudos),
+			privateBlock,
+			anonymousBlock,
+			buildKudoEditButton(),
+			buildKudoDeleteButton(),
+		),
+	}
+}
+
+// buildKudoToggleButtons offers to flip the kudo's visibility and anonymity.
+func buildKudoToggleButtons(kudo *database.Kudo) (*slack.ButtonBlockElement, *slack.ButtonBlockElement) {
+	var privateBlock, anonymousBlock *slack.ButtonBlockElement
 
 	if kudo.IsPublic {
 		privateBlock = slack.NewButtonBlockElement(
@@ -53,71 +92,198 @@ func BuildCommandPayloadBlocks(kudo database.Kudo, message string) []slack.Block
 		)
 	}
 
-	return []slack.Block{
-		slack.NewSectionBlock(
-			&slack.TextBlockObject{
-				Type:     slack.PlainTextType,
-				Text:     "Shout out: " + kudo.Message,
-				Verbatim: false,
-			},
-			nil,
-			nil,
-		),
-		slack.NewSectionBlock(
-			&slack.TextBlockObject{
-				Type: slack.MarkdownType,
-				Text: message,
-			},
-			nil,
-			nil,
-		),
-		slack.NewActionBlock(
-			fmt.Sprintf("kudo-%d", kudo.ID),
-			privateBlock,
-			anonymousBlock,
-		),
+	return privateBlock, anonymousBlock
+}
+
+func buildKudoEditButton() *slack.ButtonBlockElement {
+	return slack.NewButtonBlockElement(
+		"",
+		"edit",
+		&slack.TextBlockObject{
+			Type: slack.PlainTextType,
+			Text: "Edit",
+		},
+	)
+}
+
+func buildKudoDeleteButton() *slack.ButtonBlockElement {
+	deleteBlock := slack.NewButtonBlockElement(
+		"",
+		"delete",
+		&slack.TextBlockObject{
+			Type: slack.PlainTextType,
+			Text: "Delete",
+		},
+	).WithStyle(slack.StyleDanger)
+
+	deleteBlock.Confirm = slack.NewConfirmationBlockObject(
+		&slack.TextBlockObject{Type: slack.PlainTextType, Text: "Delete shout out?"},
+		&slack.TextBlockObject{Type: slack.PlainTextType, Text: "It won't be released, this can't be undone."},
+		&slack.TextBlockObject{Type: slack.PlainTextType, Text: "Delete"},
+		&slack.TextBlockObject{Type: slack.PlainTextType, Text: "Keep it"},
+	)
+
+	return deleteBlock
+}
+
+// kudoBlockID encodes every kudo in a shout out into the action block ID, e.g.
+// "kudo-12_13_14".
+func kudoBlockID(kudos []*database.Kudo) string {
+	ids := make([]string, 0, len(kudos))
+	for _, kudo := range kudos {
+		ids = append(ids, strconv.Itoa(int(kudo.ID)))
+	}
+
+	return "kudo-" + strings.Join(ids, "_")
+}
+
+// ParseKudoBlockIDs reverses kudoBlockID, given the part after "kudo-".
+func ParseKudoBlockIDs(encoded string) ([]int, error) {
+	kudoIDs := []int{}
+	for _, id := range strings.Split(encoded, "_") {
+		kudoID, err := strconv.Atoi(id)
+		if err != nil {
+			return nil, fmt.Errorf("invalid shout out ID %q: %v", id, err)
+		}
+		kudoIDs = append(kudoIDs, kudoID)
 	}
+
+	return kudoIDs, nil
 }
 
-func HandleTroutCommand(cmd slack.SlashCommand, save func(*database.Kudo) error) (interface{}, error) {
+func HandleTroutCommand(ws *Workspace, cmd slack.SlashCommand, prepare func(*Workspace, *database.Kudo) error) (interface{}, error) {
+	args := strings.Fields(cmd.Text)
+	if len(args) == 0 {
+		return nil, openKudoComposeModal(ws, cmd.TriggerID, kudoComposePrefill{})
+	}
+	if args[0] == "stats" {
+		return handleStatsCommand(ws, cmd, args[1:])
+	}
+	if args[0] == "points" {
+		return handlePointsCommand(ws, cmd)
+	}
+	if args[0] == "redeem" {
+		return handleRedeemCommand(ws, cmd, args[1:])
+	}
+
 	mentionCount := parser.GetMentionCount(cmd.Text)
-	if mentionCount != 1 {
-		err := notifyMissingToUser(cmd.ChannelID, cmd.UserID)
+	if mentionCount < 1 {
+		err := notifyMissingToUser(ws, cmd.ChannelID, cmd.UserID)
 		if err != nil {
 			fmt.Printf("failed posting message: %v", err)
 		}
 		return nil, err
 	}
 
-	kudo, err := database.NewKudoFromText(cmd.Text, cmd.UserID)
+	kudos, err := database.NewKudosFromText(cmd.Text, ws.TeamID, cmd.UserID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse kudo: %v", err)
 	}
 
-	if kudo.FromUserID == kudo.ToUserID {
-		notifySelfShoutOutNotAllowed(cmd.ChannelID, cmd.UserID)
+	kudos = withoutSelfShoutOuts(kudos)
+	if len(kudos) == 0 {
+		notifySelfShoutOutNotAllowed(ws, cmd.ChannelID, cmd.UserID)
 		return nil, err
 	}
 
-	err = save(kudo)
+	for _, kudo := range kudos {
+		kudo.ChannelID = cmd.ChannelID
+	}
+
+	denied, err := saveWithinQuota(ws, cmd.UserID, kudos, prepare)
 	if err != nil {
-		return nil, fmt.Errorf("failed to store kudo: %v", err)
+		return nil, err
+	}
+	if denied != "" {
+		return commandText(denied), nil
 	}
 
-	blocks := BuildCommandPayloadBlocks(*kudo, "Thanks, got it!")
+	message := "Thanks, got it!"
+	allowance, err := remainingAllowance(ws, cmd.UserID)
+	if err != nil {
+		fmt.Printf("failed counting allowance: %v\n", err)
+	}
+	if allowance != "" {
+		message += " " + allowance
+	}
+
+	blocks := BuildCommandPayloadBlocks(kudos, message)
 
 	return map[string]interface{}{"blocks": blocks}, nil
 }
 
-func HandleTroutInteraction(a *slack.BlockAction, callback slack.InteractionCallback, kudoID int) error {
-	kudo, err := database.GetKudoByID(kudoID)
-	if err != nil {
-		return fmt.Errorf("could not find shout out: %v", err)
-	}
+// HandleTroutInteraction applies a button press to every kudo in the block.
+// Block IDs come back from the client, so each kudo is checked against the
+// user pressing the button before anything changes.
+func HandleTroutInteraction(ws *Workspace, a *slack.BlockAction, callback slack.InteractionCallback, kudoIDs []int) error {
+	kudos := make([]*database.Kudo, 0, len(kudoIDs))
+	for _, kudoID := range kudoIDs {
+		kudo, err := ws.Store.GetKudoByID(ws.TeamID, kudoID)
+		if err != nil {
+			notifyResponseURL(ws, callback.ResponseURL, "Sorry, I couldn't find that shout out.")
+			return fmt.Errorf("could not find shout out: %v", err)
+		}
 
-	fmt.Println(kudo)
+		err = canChangeKudo(kudo, callback.User.ID)
+		if err != nil {
+			notifyResponseURL(ws, callback.ResponseURL, "Sorry, "+err.Error()+".")
+			return fmt.Errorf("user %s may not change shout out %d: %v", callback.User.ID, kudo.ID, err)
+		}
+
+		kudos = append(kudos, kudo)
+	}
 
 	switch a.Value {
+	case "edit":
+		return openKudoEditModal(ws, callback.TriggerID, kudos, kudoEditMetadata{
+			KudoIDs:     kudoIDs,
+			ResponseURL: callback.ResponseURL,
+		})
+	case "delete":
+		err := deleteKudos(ws, kudos, callback.User.ID)
+		if err != nil {
+			notifyResponseURL(ws, callback.ResponseURL, "Sorry, I couldn't delete that shout out.")
+			return err
+		}
+
+		return replaceOriginalWithText(ws, callback.ResponseURL, "Shout out deleted, it won't be released.")
+	}
+
+	for _, kudo := range kudos {
+		err := applyKudoToggle(kudo, a.Value)
+		if err != nil {
+			return err
+		}
+
+		err = ws.Store.SaveKudo(kudo)
+		if err != nil {
+			notifyResponseURL(ws, callback.ResponseURL, "Sorry, I couldn't save that change, please try again.")
+			return fmt.Errorf("failed to store kudo: %v", err)
+		}
+	}
+
+	blocks := BuildCommandPayloadBlocks(kudos, "Successfully set shout out to be "+a.Value+"!")
+
+	return replaceOriginal(ws, callback.ResponseURL, blocks)
+}
+
+// canChangeKudo only lets the giver change a shout out, and only until it has
+// been released.
+func canChangeKudo(kudo *database.Kudo, userID string) error {
+	if kudo.FromUserID != userID {
+		return errors.New("only the person who gave a shout out can change it")
+	}
+
+	if !kudo.IsPending() {
+		return errors.New("this shout out has already been released")
+	}
+
+	return nil
+}
+
+// applyKudoToggle flips visibility or anonymity by button value.
+func applyKudoToggle(kudo *database.Kudo, value string) error {
+	switch value {
 	case "private":
 		kudo.IsPublic = false
 	case "public":
@@ -129,10 +295,6 @@ func HandleTroutInteraction(a *slack.BlockAction, callback slack.InteractionCall
 	default:
 		return errors.New("unknown action value")
 	}
-	kudo.Save()
-
-	blocks := BuildCommandPayloadBlocks(*kudo, "Successfully set shout out to be "+a.Value+"!")
-	slack.PostWebhook(callback.ResponseURL, &slack.WebhookMessage{Blocks: &slack.Blocks{BlockSet: blocks}, ReplaceOriginal: true})
 
 	return nil
 }
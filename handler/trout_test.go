@@ -0,0 +1,266 @@
+package handler
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zerodahero/trout/database"
+	"github.com/zerodahero/trout/fakeslack"
+	"github.com/zerodahero/trout/parser"
+
+	"github.com/slack-go/slack"
+)
+
+// setupTestSlack points the API at a fake Slack, with an empty in-memory
+// store and the default config, for the length of the test. Tests change
+// ws.Config for the settings they need.
+func setupTestSlack(t *testing.T) (*fakeslack.Server, *Workspaces) {
+	fake := fakeslack.New()
+	t.Cleanup(fake.Close)
+
+	workspaces, err := InitApi(database.NewMemoryStore(), DefaultConfig(), "xoxb-test", "xapp-test", false, slack.OptionAPIURL(fake.APIURL()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fake, workspaces
+}
+
+// testWorkspace is the fake Slack's workspace, connected by setupTestSlack.
+func testWorkspace(t *testing.T, workspaces *Workspaces) *Workspace {
+	ws, err := workspaces.Get("T1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ws
+}
+
+// lastWebhook is the latest reply to the response URL.
+func lastWebhook(fake *fakeslack.Server, responseURL string) slack.WebhookMessage {
+	messages := fake.Webhooks(responseURL)
+	if len(messages) == 0 {
+		return slack.WebhookMessage{}
+	}
+
+	return messages[len(messages)-1]
+}
+
+func createTestKudo(t *testing.T, ws *Workspace, from, to string, shared bool) *database.Kudo {
+	kudo := database.NewKudo("T1", from, to, "Thanks for the help!")
+	if shared {
+		kudo.SharedAt.SetValid(time.Now())
+	}
+
+	err := ws.Store.SaveKudo(kudo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return kudo
+}
+
+// prepareTestKudo leaves the kudo as it is, skipping the user lookups.
+func prepareTestKudo(ws *Workspace, kudo *database.Kudo) error {
+	return nil
+}
+
+func TestParseKudoBlockIDs(t *testing.T) {
+	var tests = []struct {
+		encoded string
+		want    []int
+		wantErr bool
+	}{
+		{"12", []int{12}, false},
+		{"12_13_14", []int{12, 13, 14}, false},
+		{"", nil, true},
+		{"12_abc", nil, true},
+		{"12-13", nil, true},
+	}
+
+	for _, tt := range tests {
+		testname := fmt.Sprintf("%q", tt.encoded)
+		t.Run(testname, func(t *testing.T) {
+			ans, err := ParseKudoBlockIDs(tt.encoded)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(ans, tt.want) {
+				t.Errorf("got %v, want %v", ans, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleTroutInteractionAuthorization(t *testing.T) {
+	fake, workspaces := setupTestSlack(t)
+	ws := testWorkspace(t, workspaces)
+
+	own := createTestKudo(t, ws, "UGIVER", "URECIPIENT", false)
+	someoneElses := createTestKudo(t, ws, "UOTHER", "URECIPIENT", false)
+	released := createTestKudo(t, ws, "UGIVER", "URECIPIENT", true)
+
+	var tests = []struct {
+		name      string
+		blockID   string
+		userID    string
+		wantErr   bool
+		wantReply string
+	}{
+		{"owner toggles own kudo", kudoBlockID([]*database.Kudo{own}), "UGIVER", false, ""},
+		{"forged block ID for someone else's kudo", kudoBlockID([]*database.Kudo{someoneElses}), "UGIVER", true, "only the person who gave a shout out"},
+		{"forged block ID mixing in someone else's kudo", kudoBlockID([]*database.Kudo{own, someoneElses}), "UGIVER", true, "only the person who gave a shout out"},
+		{"recipient can't change their kudo", kudoBlockID([]*database.Kudo{own}), "URECIPIENT", true, "only the person who gave a shout out"},
+		{"already released kudo", kudoBlockID([]*database.Kudo{released}), "UGIVER", true, "already been released"},
+		{"forged block ID for missing kudo", "kudo-99999", "UGIVER", true, "couldn't find that shout out"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Start every case from a public kudo
+			for _, kudo := range []*database.Kudo{own, someoneElses} {
+				kudo.IsPublic = true
+				err := ws.Store.SaveKudo(kudo)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			responseURL := fake.ResponseURL()
+			kudoIDs, err := ParseKudoBlockIDs(strings.TrimPrefix(tt.blockID, "kudo-"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			callback := slack.InteractionCallback{ResponseURL: responseURL}
+			callback.User.ID = tt.userID
+			err = HandleTroutInteraction(ws, &slack.BlockAction{BlockID: tt.blockID, Value: "private"}, callback, kudoIDs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+
+			reply := lastWebhook(fake, responseURL)
+			if tt.wantErr {
+				if !strings.Contains(reply.Text, tt.wantReply) {
+					t.Errorf("got reply %q, want it to contain %q", reply.Text, tt.wantReply)
+				}
+				if reply.ReplaceOriginal {
+					t.Errorf("error reply should not replace the original message")
+				}
+			} else if !reply.ReplaceOriginal {
+				t.Errorf("expected the original message to be replaced")
+			}
+
+			for _, kudo := range []*database.Kudo{own, someoneElses} {
+				stored, err := ws.Store.GetKudoByID("T1", int(kudo.ID))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				wantPublic := tt.wantErr || stored.ID != own.ID
+				if stored.IsPublic != wantPublic {
+					t.Errorf("kudo %d: got public %v, want %v", stored.ID, stored.IsPublic, wantPublic)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleTroutCommand(t *testing.T) {
+	var tests = []struct {
+		name       string
+		text       string
+		wantTo     []string
+		wantNotice string
+		wantModal  bool
+	}{
+		{"one recipient", "<@U2|two> thanks for the review", []string{"U2"}, "", false},
+		{"several recipients", "<@U2|two> <@U3|three> great pairing", []string{"U2", "U3"}, "", false},
+		{"self dropped from the crew", "<@UGIVER|me> <@U2|two> we did it", []string{"U2"}, "", false},
+		{"self only", "<@UGIVER|me> go me", nil, "I don't do self shout-outs", false},
+		{"nobody tagged", "thanks everyone", nil, "who's this about?", false},
+		{"no text opens the composer", "", nil, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, workspaces := setupTestSlack(t)
+			ws := testWorkspace(t, workspaces)
+
+			payload, err := HandleTroutCommand(ws, slack.SlashCommand{
+				UserID:    "UGIVER",
+				ChannelID: "C1",
+				TriggerID: "trigger",
+				Text:      tt.text,
+			}, prepareTestKudo)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			kudos, err := ws.Store.GetPendingKudosFrom("T1", "UGIVER", 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(kudos) != len(tt.wantTo) {
+				t.Fatalf("got %d kudos, want %d", len(kudos), len(tt.wantTo))
+			}
+			for i, kudo := range kudos {
+				if kudo.ToUserID != tt.wantTo[i] {
+					t.Errorf("kudo %d: got recipient %s, want %s", i, kudo.ToUserID, tt.wantTo[i])
+				}
+			}
+
+			if len(kudos) > 0 {
+				blocks, ok := payload.(map[string]interface{})["blocks"].([]slack.Block)
+				if !ok {
+					t.Fatalf("expected blocks in the response, got %v", payload)
+				}
+				if got, want := blocks[len(blocks)-1].(*slack.ActionBlock).BlockID, kudoBlockID(kudos); got != want {
+					t.Errorf("got block ID %s, want %s", got, want)
+				}
+			}
+
+			notices := fake.Calls("chat.postEphemeral")
+			if tt.wantNotice == "" && len(notices) != 0 {
+				t.Errorf("got unexpected notice %q", notices[0].Values.Get("text"))
+			}
+			if tt.wantNotice != "" && (len(notices) != 1 || !strings.Contains(notices[0].Values.Get("text"), tt.wantNotice)) {
+				t.Errorf("got notices %v, want one containing %q", notices, tt.wantNotice)
+			}
+
+			if opened := len(fake.Calls("views.open")) > 0; opened != tt.wantModal {
+				t.Errorf("got modal opened %v, want %v", opened, tt.wantModal)
+			}
+		})
+	}
+}
+
+func TestSaveKudoWithUser(t *testing.T) {
+	fake, workspaces := setupTestSlack(t)
+	ws := testWorkspace(t, workspaces)
+	fake.AddUser(slack.User{ID: "UGIVER", Profile: slack.UserProfile{DisplayName: "giver"}})
+	fake.AddUser(slack.User{ID: "U2", Profile: slack.UserProfile{DisplayName: "nemo"}})
+	fake.AddUser(slack.User{ID: "U3", Profile: slack.UserProfile{DisplayName: "dory"}})
+
+	kudo := database.NewKudo("T1", "UGIVER", "U2", "<@U2> and <@U3> fixed <https://example.com/1|the bug> in <#C1|general> for <!subteam^S1|@devs>")
+	err := ws.saveKudoWithUser(kudo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := ws.Store.GetKudoByID("T1", int(kudo.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "nemo and dory fixed the bug (https://example.com/1) in #general for @devs"
+	if stored.Message != want {
+		t.Errorf("got message %q, want %q", stored.Message, want)
+	}
+	if len(stored.Entities) != 5 || stored.Entities[1].ID != "U3" || stored.Entities[4].Type != parser.EntityUserGroup {
+		t.Errorf("got entities %+v", stored.Entities)
+	}
+}
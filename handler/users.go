@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/zerodahero/trout/database"
+
+	"github.com/slack-go/slack"
+)
+
+// users.info is a Tier 4 method, good for 100+ calls a minute
+var userSyncInterval = 700 * time.Millisecond
+
+// userSyncs are the workspaces refreshing names. Each allows one sync at a
+// time, since they're slow and share the workspace's rate limit.
+var userSyncs = struct {
+	sync.Mutex
+	running map[string]bool
+}{running: map[string]bool{}}
+
+// startUserSync marks the workspace as syncing, unless it already is.
+func startUserSync(teamID string) bool {
+	userSyncs.Lock()
+	defer userSyncs.Unlock()
+
+	if userSyncs.running[teamID] {
+		return false
+	}
+	userSyncs.running[teamID] = true
+
+	return true
+}
+
+func finishUserSync(teamID string) {
+	userSyncs.Lock()
+	defer userSyncs.Unlock()
+
+	delete(userSyncs.running, teamID)
+}
+
+// HandleUserChange keeps stored names up to date when someone edits their
+// profile. People trout hasn't seen yet are left for when they are.
+func HandleUserChange(ws *Workspace, ev *slack.UserChangeEvent) error {
+	user, err := ws.Store.GetUser(ws.TeamID, ev.User.ID)
+	if err != nil || user == nil {
+		return err
+	}
+
+	user.UpdateFromSlackUser(&ev.User)
+	err = ws.Store.SaveUser(user)
+	if err != nil {
+		return fmt.Errorf("failed to update user: %v", err)
+	}
+
+	return nil
+}
+
+func handleSyncUsersCommand(ws *Workspace, cmd slack.SlashCommand) (interface{}, error) {
+	if !startUserSync(ws.TeamID) {
+		return commandText("Already refreshing names, hang tight."), nil
+	}
+
+	users, err := ws.Store.GetUsers(ws.TeamID)
+	if err != nil {
+		finishUserSync(ws.TeamID)
+		return nil, err
+	}
+
+	go func() {
+		defer finishUserSync(ws.TeamID)
+
+		synced, err := syncUsers(ws, users)
+		message := fmt.Sprintf("Refreshed %d of %d names from Slack.", synced, len(users))
+		if err != nil {
+			message = fmt.Sprintf("Refreshing names stopped after %d of %d: %v", synced, len(users), err)
+		}
+
+		err = notifyUser(ws, cmd.ChannelID, cmd.UserID, message)
+		if err != nil {
+			fmt.Printf("failed posting message: %v\n", err)
+		}
+	}()
+
+	return commandText(fmt.Sprintf("Refreshing %d names from Slack, I'll let you know when it's done.", len(users))), nil
+}
+
+// syncUsers fetches everyone again from users.info, staying under the rate
+// limit. Users Slack can't find any more are skipped, only a failure to store
+// one stops the sync.
+func syncUsers(ws *Workspace, users []*database.User) (int, error) {
+	limiter := time.NewTicker(userSyncInterval)
+	defer limiter.Stop()
+
+	synced := 0
+	for i, user := range users {
+		if i > 0 {
+			<-limiter.C
+		}
+
+		slackUser, err := fetchUserInfo(ws, user.SlackID)
+		if err != nil {
+			fmt.Printf("Failed to refresh user %s: %v\n", user.SlackID, err)
+			continue
+		}
+
+		user.UpdateFromSlackUser(slackUser)
+		err = ws.Store.SaveUser(user)
+		if err != nil {
+			return synced, fmt.Errorf("failed to save user: %v", err)
+		}
+		synced++
+	}
+
+	return synced, nil
+}
+
+// maxRateLimitRetries is how often a rate limited lookup is retried before
+// giving up on the user.
+const maxRateLimitRetries = 3
+
+// fetchUserInfo waits out Slack's rate limit when it's hit anyway.
+func fetchUserInfo(ws *Workspace, userID string) (*slack.User, error) {
+	for attempt := 0; ; attempt++ {
+		user, err := ws.API.GetUserInfo(userID)
+
+		var rateLimited *slack.RateLimitedError
+		if errors.As(err, &rateLimited) && attempt < maxRateLimitRetries {
+			time.Sleep(rateLimited.RetryAfter)
+			continue
+		}
+
+		return user, err
+	}
+}
@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zerodahero/trout/database"
+
+	"github.com/slack-go/slack"
+)
+
+func renamedUser(userID, name string) slack.User {
+	return slack.User{ID: userID, TeamID: "T1", Profile: slack.UserProfile{DisplayName: name}}
+}
+
+func TestHandleUserChange(t *testing.T) {
+	var tests = []struct {
+		name     string
+		stored   bool
+		wantName string
+	}{
+		{"known user renamed", true, "trout"},
+		{"unknown user ignored", false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, workspaces := setupTestSlack(t)
+			ws := testWorkspace(t, workspaces)
+
+			if tt.stored {
+				err := ws.Store.CreateUser(&database.User{SlackID: "U2", TeamID: "T1", DisplayName: "fish"})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			event := callbackEvent("user_change", &slack.UserChangeEvent{Type: "user_change", User: renamedUser("U2", "trout")})
+			err := NewTroutRouter(workspaces).HandleEvent(event)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			user, err := ws.Store.GetUser("T1", "U2")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.stored {
+				if user != nil {
+					t.Errorf("expected the user not to be stored, got %+v", user)
+				}
+				return
+			}
+			if user.DisplayName != tt.wantName {
+				t.Errorf("got name %s, want %s", user.DisplayName, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestSyncUsers(t *testing.T) {
+	fake, workspaces := setupTestSlack(t)
+	ws := testWorkspace(t, workspaces)
+
+	interval := userSyncInterval
+	userSyncInterval = time.Millisecond
+	t.Cleanup(func() {
+		userSyncInterval = interval
+	})
+
+	for _, userID := range []string{"U2", "U3", "UGONE"} {
+		err := ws.Store.CreateUser(&database.User{SlackID: userID, TeamID: "T1", DisplayName: "fish"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	fake.AddUser(renamedUser("U2", "trout"))
+	fake.AddUser(renamedUser("U3", "salmon"))
+
+	users, err := ws.Store.GetUsers("T1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	synced, err := syncUsers(ws, users)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if synced != 2 {
+		t.Errorf("got %d synced, want 2", synced)
+	}
+
+	for userID, want := range map[string]string{"U2": "trout", "U3": "salmon", "UGONE": "fish"} {
+		user, err := ws.Store.GetUser("T1", userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.DisplayName != want {
+			t.Errorf("%s: got name %s, want %s", userID, user.DisplayName, want)
+		}
+	}
+}
+
+func TestSyncUsersCommand(t *testing.T) {
+	var tests = []struct {
+		name   string
+		userID string
+		want   string
+	}{
+		{"admin", "UADMIN", "Refreshing 1 names"},
+		{"not an admin", "UGIVER", "only workspace admins"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, workspaces := setupTestSlack(t)
+			ws := testWorkspace(t, workspaces)
+			fake.AddUser(slack.User{ID: "UADMIN", IsAdmin: true})
+			fake.AddUser(slack.User{ID: "UGIVER"})
+
+			err := ws.Store.CreateUser(&database.User{SlackID: "UGIVER", TeamID: "T1", DisplayName: "fish"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			payload, err := HandleShoutTroutCommand(ws, slack.SlashCommand{Command: "/shout-trout", Text: "sync-users", TeamID: "T1", UserID: tt.userID, ChannelID: "C1"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			text, _ := payload.(map[string]interface{})["text"].(string)
+			if !strings.Contains(text, tt.want) {
+				t.Errorf("got reply %q, want it to contain %q", text, tt.want)
+			}
+
+			waitForUserSync(t, "T1")
+		})
+	}
+}
+
+func TestSyncUsersPerWorkspace(t *testing.T) {
+	fake, workspaces := setupTestSlack(t)
+	ws := testWorkspace(t, workspaces)
+	fake.AddUser(slack.User{ID: "UADMIN", IsAdmin: true})
+	other := *ws
+	other.TeamID = "T2"
+
+	if !startUserSync("T1") {
+		t.Fatal("expected nothing syncing yet")
+	}
+	defer finishUserSync("T1")
+
+	var tests = []struct {
+		ws   *Workspace
+		want string
+	}{
+		{ws, "Already refreshing names"},
+		{&other, "Refreshing 0 names"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ws.TeamID, func(t *testing.T) {
+			payload, err := handleSyncUsersCommand(tt.ws, slack.SlashCommand{TeamID: tt.ws.TeamID, UserID: "UADMIN", ChannelID: "C1"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			text, _ := payload.(map[string]interface{})["text"].(string)
+			if !strings.Contains(text, tt.want) {
+				t.Errorf("got reply %q, want it to contain %q", text, tt.want)
+			}
+		})
+	}
+
+	waitForUserSync(t, "T2")
+}
+
+// waitForUserSync waits for the workspace's sync to finish, before the fake
+// goes away.
+func waitForUserSync(t *testing.T, teamID string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !startUserSync(teamID) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the sync in %s to finish", teamID)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	finishUserSync(teamID)
+}
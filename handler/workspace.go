@@ -0,0 +1,211 @@
+package handler
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/zerodahero/trout/database"
+	"github.com/zerodahero/trout/parser"
+
+	"github.com/slack-go/slack"
+)
+
+// Workspace is one Slack workspace the bot is installed in, with the client
+// holding its bot token, the store its shout outs are kept in and how trout
+// is set up.
+type Workspace struct {
+	TeamID    string
+	BotUserID string
+	API       SlackAPI
+	Store     database.Store
+	Config    *Config
+}
+
+// Workspaces are all the workspaces the bot is installed in, sharing one
+// store and config. A client is cached per workspace, so installations are
+// only loaded the first time a workspace is heard from.
+type Workspaces struct {
+	store  database.Store
+	config *Config
+
+	mu    sync.Mutex
+	cache map[string]*Workspace
+
+	// The workspace the bot token from the environment belongs to, which has
+	// no installation to load it from again
+	botTeamID string
+	botToken  string
+}
+
+func NewWorkspaces(store database.Store, config *Config) *Workspaces {
+	return &Workspaces{store: store, config: config, cache: map[string]*Workspace{}}
+}
+
+// Get finds the workspace by team ID, from the bot token in the environment
+// or an OAuth installation.
+func (w *Workspaces) Get(teamID string) (*Workspace, error) {
+	w.mu.Lock()
+	ws, ok := w.cache[teamID]
+	botTeamID, botToken := w.botTeamID, w.botToken
+	w.mu.Unlock()
+	if ok {
+		return ws, nil
+	}
+
+	installation, err := w.store.GetInstallation(teamID)
+	if err != nil {
+		return nil, err
+	}
+	if installation == nil {
+		// Once forgotten, e.g. after an uninstall, the bot token's workspace
+		// connects again. That only works if the token still does.
+		if teamID != "" && teamID == botTeamID {
+			return w.connect(botToken)
+		}
+		return nil, fmt.Errorf("trout isn't installed in workspace %q", teamID)
+	}
+
+	ws = &Workspace{
+		TeamID:    installation.TeamID,
+		BotUserID: installation.BotUserID,
+		API:       newSlackClient(installation.BotToken),
+		Store:     w.store,
+		Config:    w.config,
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.cache[teamID] = ws
+
+	return ws, nil
+}
+
+// connect finds out which workspace the environment's bot token belongs to,
+// and keeps it.
+func (w *Workspaces) connect(botToken string) (*Workspace, error) {
+	api := newSlackClient(botToken)
+
+	auth, err := api.AuthTest()
+	if err != nil {
+		return nil, err
+	}
+
+	ws := &Workspace{TeamID: auth.TeamID, BotUserID: auth.UserID, API: api, Store: w.store, Config: w.config}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.cache[ws.TeamID] = ws
+	w.botTeamID, w.botToken = ws.TeamID, botToken
+
+	return ws, nil
+}
+
+func newSlackClient(botToken string) SlackAPI {
+	return &slackClient{slack.New(botToken, apiOptions...)}
+}
+
+// forget drops the cached client, e.g. after a reinstall changed the token or
+// the app was uninstalled.
+func (w *Workspaces) forget(teamID string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	delete(w.cache, teamID)
+}
+
+// uninstall removes the installation, the workspace's token no longer works.
+func (w *Workspaces) uninstall(teamID string) error {
+	w.forget(teamID)
+
+	installation, err := w.store.GetInstallation(teamID)
+	if err != nil || installation == nil {
+		return err
+	}
+
+	fmt.Printf("Uninstalled from workspace %s\n", teamID)
+
+	return w.store.DeleteInstallation(installation)
+}
+
+// saveKudoWithUser prepares the kudo and saves it.
+func (ws *Workspace) saveKudoWithUser(kudo *database.Kudo) error {
+	err := ws.prepareKudo(kudo)
+	if err != nil {
+		return err
+	}
+
+	err = ws.Store.SaveKudo(kudo)
+	if err != nil {
+		return fmt.Errorf("failed to save kudo: %w", err)
+	}
+
+	return nil
+}
+
+// prepareKudo makes sure everyone involved is stored, and swaps mentions in
+// the message for names, so the kudo is ready to save.
+func (ws *Workspace) prepareKudo(kudo *database.Kudo) error {
+	_, err := ws.getOrFetchUser(kudo.ToUserID)
+	if err != nil {
+		return fmt.Errorf("failed to get user info: %v", err)
+	}
+
+	// Get the "from" user as well to make sure they're in the DB
+	_, err = ws.getOrFetchUser(kudo.FromUserID)
+	if err != nil {
+		return fmt.Errorf("failed to get user info: %v", err)
+	}
+
+	for _, category := range ws.Config.categoriesFromText(kudo.Message) {
+		kudo.AddCategory(category)
+	}
+
+	// Releasing the message shouldn't ping anyone, so keep what was mentioned
+	// and store it as plain text
+	kudo.Entities = parser.ParseEntities(kudo.Message)
+	kudo.Message, err = ws.plainTextMessage(kudo.Message)
+
+	return err
+}
+
+// plainTextMessage renders the mrkdwn message as plain text, with everyone
+// mentioned going by their stored name. A shout out to several people
+// mentions all of them, not just this kudo's recipient.
+func (ws *Workspace) plainTextMessage(message string) (string, error) {
+	name, err := ws.entityNames(parser.ParseEntities(message))
+	if err != nil {
+		return "", err
+	}
+
+	return parser.RenderPlainText(message, name), nil
+}
+
+// entityNames looks up everyone among the entities, so they can be rendered
+// by their stored name.
+func (ws *Workspace) entityNames(entities []parser.Entity) (func(parser.Entity) string, error) {
+	names := map[string]string{}
+	for _, entity := range entities {
+		if entity.Type != parser.EntityUser {
+			continue
+		}
+
+		user, err := ws.getOrFetchUser(entity.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user info: %v", err)
+		}
+		names[entity.ID] = user.DisplayName
+	}
+
+	return func(entity parser.Entity) string {
+		if entity.Type == parser.EntityUser {
+			return names[entity.ID]
+		}
+		return ""
+	}, nil
+}
+
+func (ws *Workspace) getOrFetchUser(userID string) (*database.User, error) {
+	return database.GetOrFetchUser(ws.Store, ws.TeamID, userID, ws.Config.UserTTL, ws.API.GetUserInfo)
+}
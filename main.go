@@ -3,23 +3,40 @@ package main
 import (
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/zerodahero/trout/database"
 	"github.com/zerodahero/trout/handler"
-	"github.com/zerodahero/trout/parser"
 
 	"github.com/joho/godotenv"
-	"github.com/slack-go/slack"
-	"github.com/slack-go/slack/slackevents"
-	"github.com/slack-go/slack/socketmode"
 )
 
 var debug bool
 
-func init() {
+// How Slack reaches the bot, set with SLACK_MODE.
+const (
+	modeSocket = "socket"
+	modeHTTP   = "http"
+)
+
+var mode string
+
+// HTTP mode settings
+var httpAddr string
+var signingSecret string
+
+// oauth is set when the app can be installed into more workspaces.
+var oauth *handler.OAuthConfig
+
+// workspaces are the ones the bot is installed in, along with the store.
+var workspaces *handler.Workspaces
+
+// configure reads settings from the environment and connects everything up.
+func configure() {
 	err := godotenv.Load(".env")
 
 	if err != nil {
@@ -33,168 +50,180 @@ func init() {
 		debug = false
 	}
 
-	appToken := os.Getenv("SLACK_APP_TOKEN")
-	if appToken == "" {
-		fmt.Fprintf(os.Stderr, "SLACK_APP_TOKEN must be set.\n")
+	mode = os.Getenv("SLACK_MODE")
+	if mode == "" {
+		mode = modeSocket
+	}
+
+	var appToken string
+	switch mode {
+	case modeSocket:
+		appToken = os.Getenv("SLACK_APP_TOKEN")
+		if appToken == "" {
+			fmt.Fprintf(os.Stderr, "SLACK_APP_TOKEN must be set.\n")
+			os.Exit(1)
+		}
+
+		if !strings.HasPrefix(appToken, "xapp-") {
+			fmt.Fprintf(os.Stderr, "SLACK_APP_TOKEN must have the prefix \"xapp-\".")
+		}
+	case modeHTTP:
+		signingSecret = os.Getenv("SLACK_SIGNING_SECRET")
+		if signingSecret == "" {
+			fmt.Fprintf(os.Stderr, "SLACK_SIGNING_SECRET must be set for HTTP mode.\n")
+			os.Exit(1)
+		}
+
+	default:
+		fmt.Fprintf(os.Stderr, "SLACK_MODE must be %q or %q, got %q.\n", modeSocket, modeHTTP, mode)
 		os.Exit(1)
 	}
 
-	if !strings.HasPrefix(appToken, "xapp-") {
-		fmt.Fprintf(os.Stderr, "SLACK_APP_TOKEN must have the prefix \"xapp-\".")
+	httpAddr = os.Getenv("HTTP_ADDR")
+	if httpAddr == "" {
+		httpAddr = ":3000"
+	}
+
+	clientID := os.Getenv("SLACK_CLIENT_ID")
+	if clientID != "" {
+		oauth = &handler.OAuthConfig{
+			ClientID:     clientID,
+			ClientSecret: os.Getenv("SLACK_CLIENT_SECRET"),
+			RedirectURL:  os.Getenv("SLACK_REDIRECT_URL"),
+		}
+		if oauth.ClientSecret == "" {
+			fmt.Fprintf(os.Stderr, "SLACK_CLIENT_SECRET must be set along with SLACK_CLIENT_ID.\n")
+			os.Exit(1)
+		}
 	}
 
+	// Without OAuth there's no other way in
 	botToken := os.Getenv("SLACK_BOT_TOKEN")
-	if botToken == "" {
-		fmt.Fprintf(os.Stderr, "SLACK_BOT_TOKEN must be set.\n")
+	if botToken == "" && oauth == nil {
+		fmt.Fprintf(os.Stderr, "SLACK_BOT_TOKEN or SLACK_CLIENT_ID must be set.\n")
 		os.Exit(1)
 	}
 
-	if !strings.HasPrefix(botToken, "xoxb-") {
+	if botToken != "" && !strings.HasPrefix(botToken, "xoxb-") {
 		fmt.Fprintf(os.Stderr, "SLACK_BOT_TOKEN must have the prefix \"xoxb-\".")
 	}
 
-	err = database.InitDB("./trout.db")
+	config := handler.DefaultConfig()
+	config.ReleasePassword = os.Getenv("SHOUT_TROUT_PASSWORD")
+	config.Categories = parseList(os.Getenv("TROUT_CATEGORIES"))
+	config.Ponds = parseList(os.Getenv("TROUT_PONDS"))
+
+	// Unset keeps the default :fish:, set but empty turns reactions off
+	reactions, ok := os.LookupEnv("TROUT_REACTIONS")
+	if ok {
+		config.Reactions = parseList(reactions)
+	}
+
+	config.GiverQuotas, err = handler.ParseQuotas(os.Getenv("TROUT_GIVING_QUOTA"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "TROUT_GIVING_QUOTA: %v.\n", err)
+		os.Exit(1)
+	}
+	config.PairQuotas, err = handler.ParseQuotas(os.Getenv("TROUT_PAIR_QUOTA"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "TROUT_PAIR_QUOTA: %v.\n", err)
+		os.Exit(1)
+	}
+
+	config.PointsPerKudo, err = parseCount(os.Getenv("TROUT_POINTS_PER_KUDO"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "TROUT_POINTS_PER_KUDO: %v.\n", err)
+		os.Exit(1)
+	}
+	config.PointsAllowance, err = parseCount(os.Getenv("TROUT_POINTS_ALLOWANCE"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "TROUT_POINTS_ALLOWANCE: %v.\n", err)
+		os.Exit(1)
+	}
+	config.RewardsChannelID = os.Getenv("TROUT_REWARDS_CHANNEL")
+
+	userTTLString := os.Getenv("TROUT_USER_TTL")
+	if userTTLString != "" {
+		config.UserTTL, err = time.ParseDuration(userTTLString)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "TROUT_USER_TTL must be a duration like \"24h\", got %q.\n", userTTLString)
+			os.Exit(1)
+		}
+	}
+
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		databaseURL = "./trout.db"
+	}
+
+	store, err := database.Open(databaseURL)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = handler.InitApi(botToken, appToken, debug)
+	workspaces, err = handler.InitApi(store, config, botToken, appToken, debug)
 	if err != nil {
 		log.Fatal(err)
 	}
 }
 
 func main() {
+	configure()
 
-	client := handler.NewClient(debug)
+	router := handler.NewTroutRouter(workspaces)
 
-	go func() {
-		for evt := range client.Events {
-			switch evt.Type {
-			case socketmode.EventTypeConnecting:
-				fmt.Println("Connecting to Slack with Socket Mode...")
-			case socketmode.EventTypeConnectionError:
-				fmt.Println("Connection failed. Retrying later...")
-			case socketmode.EventTypeConnected:
-				fmt.Println("Connected to Slack with Socket Mode.")
-			case socketmode.EventTypeEventsAPI:
-				eventsAPIEvent, ok := evt.Data.(slackevents.EventsAPIEvent)
-				if !ok {
-					fmt.Printf("Ignored %+v\n", evt)
-
-					continue
-				}
-
-				fmt.Printf("Event received: %+v\n", eventsAPIEvent)
-
-				client.Ack(*evt.Request)
-
-				switch eventsAPIEvent.Type {
-				case slackevents.CallbackEvent:
-					innerEvent := eventsAPIEvent.InnerEvent
-					switch ev := innerEvent.Data.(type) {
-					case *slackevents.AppMentionEvent:
-						handler.HandleMention(ev, saveKudoWithUser)
-					case *slackevents.MemberJoinedChannelEvent:
-						fmt.Printf("user %q joined to channel %q", ev.User, ev.Channel)
-					}
-				default:
-					client.Debugf("unsupported Events API event received")
-				}
-			case socketmode.EventTypeInteractive:
-				callback, ok := evt.Data.(slack.InteractionCallback)
-				if !ok {
-					fmt.Printf("Ignored %+v\n", evt)
-
-					continue
-				}
-
-				fmt.Printf("Interaction received: %+v\n", callback)
-
-				var payload interface{}
-
-				switch callback.Type {
-				case slack.InteractionTypeBlockActions:
-					// See https://api.slack.com/apis/connections/socket-implement#button
-					for _, a := range callback.ActionCallback.BlockActions {
-						var err error
-						actionType := strings.Split(a.BlockID, "-")
-						switch actionType[0] {
-						case "kudo":
-							kudoID, _ := strconv.Atoi(actionType[1])
-							err = handler.HandleTroutInteraction(a, callback, kudoID)
-						case "shouttrout":
-							attempt, _ := strconv.Atoi(actionType[1])
-							err = handler.HandleShoutTroutInteraction(a, callback, attempt)
-						}
-						if err != nil {
-							fmt.Printf("Error handling interaction: %v", err)
-						}
-					}
-					client.Debugf("button clicked!")
-				case slack.InteractionTypeShortcut:
-				case slack.InteractionTypeViewSubmission:
-					// See https://api.slack.com/apis/connections/socket-implement#modal
-				case slack.InteractionTypeDialogSubmission:
-				default:
-
-				}
-
-				client.Ack(*evt.Request, payload)
-			case socketmode.EventTypeSlashCommand:
-				cmd, ok := evt.Data.(slack.SlashCommand)
-				if !ok {
-					fmt.Printf("Ignored %+v\n", evt)
-
-					continue
-				}
-
-				client.Debugf("Slash command received: %+v", cmd)
-
-				var payload interface{}
-				var err error
-
-				switch cmd.Command {
-				case "/trout":
-					payload, err = handler.HandleTroutCommand(cmd, saveKudoWithUser)
-				case "/shout-trout":
-					payload, err = handler.HandleShoutTroutCommand(cmd)
-				default:
-					fmt.Fprintf(os.Stderr, "Unexpected slash command received: %s\n", cmd.Command)
-				}
-
-				if err != nil {
-					fmt.Printf("Error handling slash command: %v", err)
-				}
-
-				client.Ack(*evt.Request, payload)
-			default:
-				fmt.Fprintf(os.Stderr, "Unexpected event type received: %s\n", evt.Type)
-			}
-		}
-	}()
+	go handler.ResumeInterruptedReleases(workspaces)
+	go handler.RunScheduler(workspaces, time.Minute)
+
+	mux := http.NewServeMux()
+	if mode == modeHTTP {
+		mux = handler.NewHTTPHandler(router, signingSecret)
+	}
+	if oauth != nil {
+		handler.HandleOAuth(mux, workspaces, *oauth)
+	}
+
+	if mode == modeHTTP {
+		log.Fatal(handler.RunHTTP(httpAddr, mux))
+	}
+
+	// Socket mode only needs HTTP for installs
+	if oauth != nil {
+		go func() {
+			log.Fatal(handler.RunHTTP(httpAddr, mux))
+		}()
+	}
+
+	client := handler.NewClient(debug)
+	go handler.RunSocketMode(client, router)
 
 	client.Run()
 }
 
-func saveKudoWithUser(kudo *database.Kudo) error {
-	user, err := database.GetOrFetchUser(kudo.ToUserID, handler.GetUserInfo)
-	if err != nil {
-		return fmt.Errorf("failed to get user info: %v", err)
+// parseList splits a comma separated setting, ignoring empty entries.
+func parseList(value string) []string {
+	list := []string{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			list = append(list, item)
+		}
 	}
 
-	// Get the "from" user as well to make sure they're in the DB
-	_, err = database.GetOrFetchUser(kudo.FromUserID, handler.GetUserInfo)
-	if err != nil {
-		return fmt.Errorf("failed to get user info: %v", err)
-	}
+	return list
+}
 
-	kudo.Message = parser.ReplaceUserInText(kudo.Message, user.SlackID, user.DisplayName)
+// parseCount reads a whole number that can't be negative, empty is 0.
+func parseCount(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
 
-	err = kudo.Save()
-	if err != nil {
-		return fmt.Errorf("failed to save kudo: %v", err)
+	count, err := strconv.Atoi(value)
+	if err != nil || count < 0 {
+		return 0, fmt.Errorf("should be a whole number, 0 or more, got %q", value)
 	}
 
-	return nil
+	return count, nil
 }
@@ -3,11 +3,38 @@ package parser
 import (
 	"errors"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
 var whitespaceRegex = regexp.MustCompile(`\s{2,}`)
 var userRegex = regexp.MustCompile(`<@([[:alnum:]]+)(\|[^>]+)?>`)
+var userGroupRegex = regexp.MustCompile(`<!subteam\^([[:alnum:]]+)(\|[^>]+)?>`)
+var channelRegex = regexp.MustCompile(`<#([[:alnum:]]+)(\|[^>]*)?>`)
+var pondRegex = regexp.MustCompile(`(?i)(^|\s)pond:([a-z0-9_-]+)`)
+var pointsRegex = regexp.MustCompile(`(^|\s)\+(\d{1,6})\b`)
+var hashtagRegex = regexp.MustCompile(`(^|\s)#([\p{L}\p{N}_-]+)`)
+var entityRegex = regexp.MustCompile(`<([^<>]+)>`)
+
+// Kinds of Entity, everything Slack wraps in angle brackets in mrkdwn.
+const (
+	EntityUser      = "user"
+	EntityChannel   = "channel"
+	EntityUserGroup = "usergroup"
+	EntityBroadcast = "broadcast"
+	EntityDate      = "date"
+	EntityLink      = "link"
+)
+
+// Entity is a mention, link or date from a mrkdwn message. ID is the user,
+// channel or group ID, the URL for links, the unix time for dates and here,
+// channel or everyone for broadcasts. Label is whatever text Slack sent
+// along with it.
+type Entity struct {
+	Type  string `json:"type"`
+	ID    string `json:"id"`
+	Label string `json:"label,omitempty"`
+}
 
 func GetMentionCount(text string) int {
 	return strings.Count(text, "<@")
@@ -22,6 +49,186 @@ func ParseRecipientFromText(text string) (string, error) {
 	return to[1], nil
 }
 
+func ParseRecipientsFromText(text string) ([]string, error) {
+	matches := userRegex.FindAllStringSubmatch(text, -1)
+	if matches == nil {
+		return nil, errors.New("unable to find a user mention")
+	}
+
+	seen := map[string]bool{}
+	recipients := []string{}
+	for _, match := range matches {
+		if seen[match[1]] {
+			continue
+		}
+		seen[match[1]] = true
+		recipients = append(recipients, match[1])
+	}
+
+	return recipients, nil
+}
+
+func ParseUserGroupsFromText(text string) []string {
+	groups := []string{}
+	for _, match := range userGroupRegex.FindAllStringSubmatch(text, -1) {
+		groups = append(groups, match[1])
+	}
+
+	return groups
+}
+
+func ParseChannelsFromText(text string) []string {
+	channels := []string{}
+	for _, match := range channelRegex.FindAllStringSubmatch(text, -1) {
+		channels = append(channels, match[1])
+	}
+
+	return channels
+}
+
+// ParseHashtagsFromText lists the "#tags" in the text, lowercased and without
+// repeats. Channel links, "<#C123>", aren't tags.
+func ParseHashtagsFromText(text string) []string {
+	seen := map[string]bool{}
+	tags := []string{}
+	for _, match := range hashtagRegex.FindAllStringSubmatch(text, -1) {
+		tag := strings.ToLower(match[2])
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+
+	return tags
+}
+
+// ParsePondFromText finds a "pond:name" in the text, returning the lowercased
+// pond name and the text without it.
+func ParsePondFromText(text string) (string, string) {
+	match := pondRegex.FindStringSubmatch(text)
+	if match == nil {
+		return "", text
+	}
+
+	text = strings.TrimSpace(pondRegex.ReplaceAllString(text, ""))
+
+	return strings.ToLower(match[2]), text
+}
+
+// ParsePointsFromText finds the points a giver chose with a "+5" in the text,
+// returning them and the text without it. Only the first counts.
+func ParsePointsFromText(text string) (int, string) {
+	loc := pointsRegex.FindStringSubmatchIndex(text)
+	if loc == nil {
+		return 0, text
+	}
+
+	points, err := strconv.Atoi(text[loc[4]:loc[5]])
+	if err != nil {
+		return 0, text
+	}
+
+	return points, strings.TrimSpace(text[:loc[0]] + text[loc[1]:])
+}
+
+// ParseEntities lists the entities in the text in the order they appear.
+// Anything in angle brackets that isn't one, like an unknown "<!command>", is
+// left out.
+func ParseEntities(text string) []Entity {
+	entities := []Entity{}
+	for _, match := range entityRegex.FindAllStringSubmatch(text, -1) {
+		entity, ok := parseEntity(match[1])
+		if ok {
+			entities = append(entities, entity)
+		}
+	}
+
+	return entities
+}
+
+func parseEntity(token string) (Entity, bool) {
+	id, label := token, ""
+	if i := strings.Index(token, "|"); i >= 0 {
+		id, label = token[:i], token[i+1:]
+	}
+
+	switch {
+	case strings.HasPrefix(id, "@"):
+		return Entity{Type: EntityUser, ID: id[1:], Label: label}, true
+	case strings.HasPrefix(id, "#"):
+		return Entity{Type: EntityChannel, ID: id[1:], Label: label}, true
+	case strings.HasPrefix(id, "!subteam^"):
+		return Entity{Type: EntityUserGroup, ID: strings.TrimPrefix(id, "!subteam^"), Label: label}, true
+	case id == "!here" || id == "!channel" || id == "!everyone":
+		return Entity{Type: EntityBroadcast, ID: id[1:], Label: label}, true
+	case strings.HasPrefix(id, "!date^"):
+		// <!date^1392734382^{date_short}^optional_link|fallback text>
+		parts := strings.Split(id, "^")
+		return Entity{Type: EntityDate, ID: parts[1], Label: label}, true
+	case strings.HasPrefix(id, "!"):
+		return Entity{}, false
+	}
+
+	return Entity{Type: EntityLink, ID: id, Label: label}, true
+}
+
+// RenderPlainText swaps every entity in the text for readable text, so the
+// result pings nobody. name can supply the text for an entity, e.g. a stored
+// display name, returning "" falls back to the label Slack sent, or the ID.
+func RenderPlainText(text string, name func(Entity) string) string {
+	return entityRegex.ReplaceAllStringFunc(text, func(token string) string {
+		entity, ok := parseEntity(token[1 : len(token)-1])
+		if !ok {
+			return token
+		}
+
+		return entity.PlainText(name)
+	})
+}
+
+// PlainText is how the entity reads once RenderPlainText has swapped it for
+// text, going by name the same way.
+func (e Entity) PlainText(name func(Entity) string) string {
+	if name != nil {
+		if rendered := name(e); rendered != "" {
+			return rendered
+		}
+	}
+
+	return renderEntity(e)
+}
+
+func renderEntity(entity Entity) string {
+	switch entity.Type {
+	case EntityUser, EntityUserGroup:
+		return "@" + strings.TrimPrefix(firstNonEmpty(entity.Label, entity.ID), "@")
+	case EntityChannel:
+		return "#" + firstNonEmpty(entity.Label, entity.ID)
+	case EntityBroadcast:
+		return "@" + entity.ID
+	case EntityDate:
+		return firstNonEmpty(entity.Label, entity.ID)
+	}
+
+	// Links Slack spotted itself are labelled with their own URL
+	if entity.Label == "" || strings.Contains(entity.ID, entity.Label) {
+		return firstNonEmpty(entity.Label, entity.ID)
+	}
+
+	return entity.Label + " (" + entity.ID + ")"
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
+
 func ReplaceUserInText(text, userID, name string) string {
 	userIDRegex := regexp.MustCompile(`<@` + userID + `(\|[^>]+)?>`)
 	return userIDRegex.ReplaceAllString(text, name)
@@ -37,3 +244,11 @@ func RemoveWhitespace(text string) string {
 func WrapUserIdForMention(userID string) string {
 	return `<@` + userID + `>`
 }
+
+func WrapUserGroupIdForMention(groupID string) string {
+	return `<!subteam^` + groupID + `>`
+}
+
+func WrapChannelIdForMention(channelID string) string {
+	return `<#` + channelID + `>`
+}
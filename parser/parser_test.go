@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"reflect"
 	"testing"
 )
 
@@ -48,6 +49,49 @@ func TestParseRecipientFromText(t *testing.T) {
 	}
 }
 
+func TestParseRecipientsFromText(t *testing.T) {
+	var tests = []struct {
+		text string
+		want []string
+	}{
+		{"<@ABCDE12345> Something.", []string{"ABCDE12345"}},
+		{"<@ABCDE12345> and <@EDCFA19238|suzy> Something.", []string{"ABCDE12345", "EDCFA19238"}},
+		{"<@ABCDE12345> Something <@ABCDE12345|jim_bob>.", []string{"ABCDE12345"}},
+		{"Nobody here.", nil},
+	}
+
+	for _, tt := range tests {
+		testname := fmt.Sprintf("%s", tt.text)
+		t.Run(testname, func(t *testing.T) {
+			ans, _ := ParseRecipientsFromText(tt.text)
+			if !reflect.DeepEqual(ans, tt.want) {
+				t.Errorf("got %v, want %v", ans, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseUserGroupsFromText(t *testing.T) {
+	var tests = []struct {
+		text string
+		want []string
+	}{
+		{"<!subteam^S0123ABCD|@fishers>", []string{"S0123ABCD"}},
+		{"<!subteam^S0123ABCD> and <!subteam^S9876ZYXW|@anglers>", []string{"S0123ABCD", "S9876ZYXW"}},
+		{"<@ABCDE12345> is not a group", []string{}},
+	}
+
+	for _, tt := range tests {
+		testname := fmt.Sprintf("%s", tt.text)
+		t.Run(testname, func(t *testing.T) {
+			ans := ParseUserGroupsFromText(tt.text)
+			if !reflect.DeepEqual(ans, tt.want) {
+				t.Errorf("got %v, want %v", ans, tt.want)
+			}
+		})
+	}
+}
+
 func TestReplacesUserInText(t *testing.T) {
 	var tests = []struct {
 		text   string
@@ -91,3 +135,188 @@ func TestWrapsUserIDForMention(t *testing.T) {
 		})
 	}
 }
+
+func TestWrapsUserGroupIDForMention(t *testing.T) {
+	var tests = []struct {
+		groupID string
+		want    string
+	}{
+		{"S0123ABCD", "<!subteam^S0123ABCD>"},
+	}
+
+	for _, tt := range tests {
+		testname := fmt.Sprintf("%s", tt.groupID)
+		t.Run(testname, func(t *testing.T) {
+			ans := WrapUserGroupIdForMention(tt.groupID)
+			if ans != tt.want {
+				t.Errorf("got %s, want %s", ans, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseChannelsFromText(t *testing.T) {
+	var tests = []struct {
+		text string
+		want []string
+	}{
+		{"<#C0123ABCD|general>", []string{"C0123ABCD"}},
+		{"from <#C0123ABCD> and <#C0456EFGH|random>", []string{"C0123ABCD", "C0456EFGH"}},
+		{"<@U0123ABCD> no channels", []string{}},
+	}
+
+	for _, tt := range tests {
+		testname := fmt.Sprintf("%s", tt.text)
+		t.Run(testname, func(t *testing.T) {
+			ans := ParseChannelsFromText(tt.text)
+			if !reflect.DeepEqual(ans, tt.want) {
+				t.Errorf("got %v, want %v", ans, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePondFromText(t *testing.T) {
+	var tests = []struct {
+		text     string
+		wantPond string
+		wantText string
+	}{
+		{"<@U1> thanks for the fix pond:platform", "platform", "<@U1> thanks for the fix"},
+		{"pond:Data-Eng <@U1> great query", "data-eng", "<@U1> great query"},
+		{"<@U1> thanks pond:web for the review", "web", "<@U1> thanks for the review"},
+		{"<@U1> loved lilypond:ly", "", "<@U1> loved lilypond:ly"},
+		{"<@U1> no pond here", "", "<@U1> no pond here"},
+	}
+
+	for _, tt := range tests {
+		testname := fmt.Sprintf("%s", tt.text)
+		t.Run(testname, func(t *testing.T) {
+			pond, text := ParsePondFromText(tt.text)
+			if pond != tt.wantPond || text != tt.wantText {
+				t.Errorf("got %q %q, want %q %q", pond, text, tt.wantPond, tt.wantText)
+			}
+		})
+	}
+}
+
+func TestParsePointsFromText(t *testing.T) {
+	var tests = []struct {
+		text       string
+		wantPoints int
+		wantText   string
+	}{
+		{"<@U1> thanks for the fix +5", 5, "<@U1> thanks for the fix"},
+		{"+10 <@U1> great query", 10, "<@U1> great query"},
+		{"<@U1> thanks +3 for the review", 3, "<@U1> thanks for the review"},
+		{"<@U1> +2 and +4 more", 2, "<@U1> and +4 more"},
+		{"<@U1> nice +5!", 5, "<@U1> nice!"},
+		{"<@U1> went from 1+1 to +5k", 0, "<@U1> went from 1+1 to +5k"},
+		{"<@U1> no points here", 0, "<@U1> no points here"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			points, text := ParsePointsFromText(tt.text)
+			if points != tt.wantPoints || text != tt.wantText {
+				t.Errorf("got %d %q, want %d %q", points, text, tt.wantPoints, tt.wantText)
+			}
+		})
+	}
+}
+
+func TestParseEntities(t *testing.T) {
+	var tests = []struct {
+		text string
+		want []Entity
+	}{
+		{"No entities here.", []Entity{}},
+		{"<@ABCDE12345> Something.", []Entity{{Type: EntityUser, ID: "ABCDE12345"}}},
+		{"<@ABCDE12345|jim_bob> Something.", []Entity{{Type: EntityUser, ID: "ABCDE12345", Label: "jim_bob"}}},
+		{"Over in <#C012AB3CD|general>.", []Entity{{Type: EntityChannel, ID: "C012AB3CD", Label: "general"}}},
+		{"Over in <#C012AB3CD>.", []Entity{{Type: EntityChannel, ID: "C012AB3CD"}}},
+		{"Thanks <!subteam^SAZ94GDB8|@devs>!", []Entity{{Type: EntityUserGroup, ID: "SAZ94GDB8", Label: "@devs"}}},
+		{"Hey <!here>", []Entity{{Type: EntityBroadcast, ID: "here"}}},
+		{"Hey <!channel|@channel> and <!everyone>", []Entity{{Type: EntityBroadcast, ID: "channel", Label: "@channel"}, {Type: EntityBroadcast, ID: "everyone"}}},
+		{"Since <!date^1392734382^{date_short}|Feb 18, 2014>", []Entity{{Type: EntityDate, ID: "1392734382", Label: "Feb 18, 2014"}}},
+		{"See <https://example.com/docs|the docs>", []Entity{{Type: EntityLink, ID: "https://example.com/docs", Label: "the docs"}}},
+		{"See <https://example.com>", []Entity{{Type: EntityLink, ID: "https://example.com"}}},
+		{"Mail <mailto:fish@example.com|fish@example.com>", []Entity{{Type: EntityLink, ID: "mailto:fish@example.com", Label: "fish@example.com"}}},
+		{"Unknown <!foo> is skipped", []Entity{}},
+		{"<@U1> and <#C1|fish> at <https://example.com|x>", []Entity{
+			{Type: EntityUser, ID: "U1"},
+			{Type: EntityChannel, ID: "C1", Label: "fish"},
+			{Type: EntityLink, ID: "https://example.com", Label: "x"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			ans := ParseEntities(tt.text)
+			if !reflect.DeepEqual(ans, tt.want) {
+				t.Errorf("got %+v, want %+v", ans, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderPlainText(t *testing.T) {
+	names := func(entity Entity) string {
+		if entity.Type == EntityUser && entity.ID == "U1" {
+			return "nemo"
+		}
+		return ""
+	}
+
+	var tests = []struct {
+		text string
+		want string
+	}{
+		{"Nothing to see.", "Nothing to see."},
+		{"Thanks <@U1>!", "Thanks nemo!"},
+		{"Thanks <@U2|dory>!", "Thanks @dory!"},
+		{"Thanks <@U2>!", "Thanks @U2!"},
+		{"Over in <#C1|general>.", "Over in #general."},
+		{"Over in <#C1>.", "Over in #C1."},
+		{"Thanks <!subteam^S1|@devs>!", "Thanks @devs!"},
+		{"Thanks <!subteam^S1>!", "Thanks @S1!"},
+		{"Hey <!here> and <!channel>", "Hey @here and @channel"},
+		{"Since <!date^1392734382^{date_short}|Feb 18, 2014>", "Since Feb 18, 2014"},
+		{"See <https://example.com/docs|the docs>", "See the docs (https://example.com/docs)"},
+		{"See <https://example.com|example.com>", "See example.com"},
+		{"See <https://example.com>", "See https://example.com"},
+		{"Unknown <!foo> is kept", "Unknown <!foo> is kept"},
+		{"1 &lt; 2", "1 &lt; 2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			ans := RenderPlainText(tt.text, names)
+			if ans != tt.want {
+				t.Errorf("got %q, want %q", ans, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseHashtagsFromText(t *testing.T) {
+	var tests = []struct {
+		text string
+		want []string
+	}{
+		{"No tags here.", []string{}},
+		{"#Teamwork from the start", []string{"teamwork"}},
+		{"Great job #ownership #customer-first #ownership", []string{"ownership", "customer-first"}},
+		{"Over in <#C123|general> #curiosity", []string{"curiosity"}},
+		{"Issue#42 isn't a tag", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			ans := ParseHashtagsFromText(tt.text)
+			if !reflect.DeepEqual(ans, tt.want) {
+				t.Errorf("got %v, want %v", ans, tt.want)
+			}
+		})
+	}
+}